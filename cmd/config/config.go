@@ -0,0 +1,79 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/ocm-container/pkg/schema"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ConfigCmd represents the config command
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and validate the ocm-container configuration",
+	Long:  `Inspect and validate the ocm-container configuration file`,
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema for the config file",
+	Long: `Prints the JSON Schema for the ocm-container config file, generated
+from the root options and the configuration of every feature.
+
+The output can be saved and referenced by editors to validate and
+autocomplete ocm-container.yaml, eg: for the YAML language server:
+
+  # yaml-language-server: $schema=/path/to/ocm-container.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: printSchema,
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the config file against the schema",
+	Long: `Validates the config file against the generated schema, reporting
+unknown keys and invalid values. Exits non-zero if any problems are found.`,
+	Args: cobra.NoArgs,
+	RunE: validate,
+}
+
+func printSchema(cmd *cobra.Command, args []string) error {
+	out, err := json.MarshalIndent(schema.Generate(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func validate(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return fmt.Errorf("no config file found")
+	}
+
+	problems, err := schema.Generate().ValidateFile(file)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range problems {
+		fmt.Println(p.String())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in %s", len(problems), file)
+	}
+
+	fmt.Printf("%s is valid\n", file)
+	return nil
+}
+
+func init() {
+	ConfigCmd.AddCommand(schemaCmd)
+	ConfigCmd.AddCommand(validateCmd)
+}
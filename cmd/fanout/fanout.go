@@ -0,0 +1,192 @@
+package fanout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/clusterstatus"
+	"github.com/openshift/ocm-container/pkg/fanout"
+	"github.com/openshift/ocm-container/pkg/multicluster"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/openshift/ocm-container/pkg/profiles"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Command is the command to run against each cluster, passed after --.
+// It is set by the root command, which splits it from the arguments.
+var Command []string
+
+var (
+	searchFlag      string
+	clusterIDFlag   string
+	concurrencyFlag int
+	outputFlag      string
+	resultsDirFlag  string
+)
+
+// passThroughFlags are the fanout command's flags which are passed on
+// to each cluster's ocm-container, including every login flag
+var passThroughFlags = append([]string{"ocm-url", profiles.FlagName, clusterstatus.ForceFlag}, ocm.LoginFlags...)
+
+// FanoutCmd represents the fanout command
+var FanoutCmd = &cobra.Command{
+	Use:   "fanout",
+	Short: "Run a command against many clusters",
+	Long: `Runs a command against each cluster matching an OCM search, or
+listed with --cluster-id. Each cluster gets its own ocm-container, logged
+into the cluster, with at most --concurrency running at once. The output
+and exit code of the command on each cluster are collected into a summary
+table, JSON lines, or a directory of result files.
+
+The containers use your config file, as ocm-container would for a single
+cluster.`,
+	Example: `ocm-container fanout --search "product.id='rosa' and state='ready'" -- oc get co
+ocm-container fanout --cluster-id a,b,c --output jsonl -- oc get nodes
+ocm-container fanout --search "region.id='us-east-1'" --output dir --results-dir ./results -- oc adm top nodes`,
+	Args: cobra.NoArgs,
+	RunE: run,
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	for _, flag := range passThroughFlags {
+		f := cmd.Flags().Lookup(flag)
+		if f.Changed {
+			err := viper.BindPFlag(flag, f)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !slices.Contains(fanout.Outputs, outputFlag) {
+		return fmt.Errorf("invalid --output %q: must be one of %s", outputFlag, strings.Join(fanout.Outputs, ", "))
+	}
+	if searchFlag == "" && clusterIDFlag == "" {
+		return errors.New("either --search or --cluster-id is required")
+	}
+
+	runner, err := fanout.NewRunner(childArgs(cmd), Command, concurrencyFlag)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	targets, err := findTargets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return errors.New("no clusters found")
+	}
+
+	resultsDir := resultsDirFlag
+	if outputFlag == fanout.OutputDir && resultsDir == "" {
+		resultsDir = "fanout-" + time.Now().Format("20060102-150405")
+	}
+
+	fmt.Fprintf(os.Stderr, "Running %s against %d clusters...\n", strings.Join(Command, " "), len(targets))
+	results := runner.Run(targets)
+
+	switch outputFlag {
+	case fanout.OutputJSONL:
+		err = fanout.WriteJSONL(os.Stdout, results)
+	case fanout.OutputDir:
+		err = fanout.WriteDir(resultsDir, results)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Results written to %s\n", resultsDir)
+		}
+	default:
+		err = fanout.WriteTable(os.Stdout, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed := fanout.Failed(results); failed > 0 {
+		return fmt.Errorf("the command failed on %d of %d clusters", failed, len(results))
+	}
+	return nil
+}
+
+// findTargets logs in to OCM once, and finds the clusters matching the
+// search and those passed with --cluster-id
+func findTargets() ([]fanout.Target, error) {
+	err := profiles.ApplyNamed(profiles.Names(viper.GetString(profiles.FlagName)))
+	if err != nil {
+		return nil, err
+	}
+
+	ocmConfig, err := ocm.New()
+	if err != nil {
+		return nil, fmt.Errorf("error creating connection to ocm: %v", err)
+	}
+	defer ocmConfig.Cleanup()
+	conn := ocm.GetClient()
+
+	targets := []fanout.Target{}
+	add := func(id, name string) {
+		if !slices.ContainsFunc(targets, func(t fanout.Target) bool { return t.ID == id }) {
+			targets = append(targets, fanout.Target{ID: id, Name: name})
+		}
+	}
+
+	if searchFlag != "" {
+		clusters, err := ocm.SearchClusters(conn, searchFlag)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range clusters {
+			add(c.ID(), c.Name())
+		}
+	}
+
+	keys, err := multicluster.ParseClusters(clusterIDFlag, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		c, err := ocm.GetCluster(conn, key)
+		if err != nil {
+			return nil, fmt.Errorf("%v - using ocm-url %s", err, conn.URL())
+		}
+		add(c.ID(), c.Name())
+	}
+	return targets, nil
+}
+
+// childArgs returns the flags passed to each cluster's ocm-container:
+// the global flags, eg: --config, and the pass-through flags, that were
+// set
+func childArgs(cmd *cobra.Command) []string {
+	args := []string{}
+	add := func(f *pflag.Flag) {
+		if f.Changed {
+			args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+		}
+	}
+	cmd.InheritedFlags().VisitAll(add)
+	for _, flag := range passThroughFlags {
+		add(cmd.Flags().Lookup(flag))
+	}
+	return args
+}
+
+func init() {
+	FanoutCmd.Flags().StringVar(&searchFlag, "search", "", "OCM search query selecting the clusters, eg: \"product.id='rosa' and state='ready'\"")
+	FanoutCmd.Flags().StringVarP(&clusterIDFlag, "cluster-id", "C", "", "Comma-separated list of clusters to run the command against, as well as any matching --search")
+	FanoutCmd.Flags().IntVarP(&concurrencyFlag, "concurrency", "j", fanout.DefaultConcurrency, "Number of containers to run at once")
+	FanoutCmd.Flags().StringVarP(&outputFlag, "output", "o", fanout.OutputTable, fmt.Sprintf("Output format (%s)", strings.Join(fanout.Outputs, ", ")))
+	FanoutCmd.Flags().StringVar(&resultsDirFlag, "results-dir", "", "Directory to write results to with --output dir; defaults to ./fanout-TIMESTAMP")
+	FanoutCmd.Flags().String("ocm-url", "", "OCM environment to use; defaults to the configured ocm-url")
+	FanoutCmd.Flags().String(profiles.FlagName, "", "Comma-separated list of config profiles to apply on top of the config file")
+	FanoutCmd.Flags().String(ocm.TokenFileFlag, "", "Log into OCM with the offline or access token in this file, eg: in CI")
+	FanoutCmd.Flags().String(ocm.LoginMethodFlag, ocm.LoginBrowser, fmt.Sprintf("How to log into OCM when not logged in (%s); device prints a code to enter in a browser on any machine", strings.Join(ocm.LoginMethods, ", ")))
+	FanoutCmd.Flags().Bool(ocm.NoBrowserFlag, false, "Fail instead of opening a browser to log into OCM")
+	FanoutCmd.Flags().Bool(clusterstatus.ForceFlag, false, "Run the command even on clusters the cluster guardrails would refuse or ask for confirmation")
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/image"
+	"github.com/openshift/ocm-container/pkg/multicluster"
 	"github.com/openshift/ocm-container/pkg/ocm"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -39,7 +41,10 @@ var (
 	// here. For example, `--pull` maps to `.imagePullPolicy`
 	// in the config file.
 	flagConfigOverrides = map[string]string{
-		"pull": "imagePullPolicy",
+		"pull":               "imagePullPolicy",
+		"image-tag":          "imageTag",
+		"background-pull":    "imageBackgroundPull",
+		"multi-cluster-mode": "multiClusterMode",
 	}
 )
 
@@ -128,7 +133,18 @@ var standardFlags = []cliFlag{
 		name:      "cluster-id",
 		flagType:  "string",
 		shorthand: "C",
-		helpMsg:   "Optional cluster ID to log into on launch",
+		helpMsg:   "Optional cluster ID to log into on launch; a comma-separated list opens a session for each cluster in tmux, which must be installed on the host",
+	},
+	{
+		name:     "clusters-from-file",
+		flagType: "string",
+		helpMsg:  "File listing clusters, one per line, to open a session in tmux on the host for each of",
+	},
+	{
+		name:     "multi-cluster-mode",
+		flagType: "string",
+		value:    multicluster.ModePanes,
+		helpMsg:  fmt.Sprintf("How sessions for multiple clusters are opened in tmux on the host (%s)", strings.Join(multicluster.Modes, ", ")),
 	},
 	{
 		name:     "engine",
@@ -139,7 +155,24 @@ var standardFlags = []cliFlag{
 		name:     "ocm-url",
 		flagType: "string",
 		value:    "prod",
-		helpMsg:  fmt.Sprintf("OCM Environment (%s)", strings.Join(ocm.SupportedUrls, ", ")),
+		helpMsg:  fmt.Sprintf("OCM Environment (%s), an alias from %s, or an https URL", strings.Join(ocm.SupportedUrls, ", "), ocm.EnvironmentsConfigKey),
+	},
+	{
+		name:     ocm.TokenFileFlag,
+		flagType: "string",
+		helpMsg:  "Log into OCM with the offline or access token in this file, eg: in CI; service account credentials are only read from $" + ocm.ClientIDEnv + " and $" + ocm.ClientSecretEnv,
+	},
+	{
+		name:     ocm.LoginMethodFlag,
+		flagType: "string",
+		value:    ocm.LoginBrowser,
+		helpMsg:  fmt.Sprintf("How to log into OCM when not logged in (%s); device prints a code to enter in a browser on any machine", strings.Join(ocm.LoginMethods, ", ")),
+	},
+	{
+		name:     ocm.NoBrowserFlag,
+		flagType: "bool",
+		value:    "false",
+		helpMsg:  "Fail instead of opening a browser to log into OCM",
 	},
 	{
 		name:     "headless",
@@ -164,6 +197,22 @@ var standardFlags = []cliFlag{
 		value:     "ocm-container",
 		helpMsg:   "Sets the image name to use",
 	},
+	{
+		name:     "image-tag",
+		flagType: "string",
+		helpMsg:  fmt.Sprintf("Image tag or channel to use (%s, %s, %s for the image matching this release, or any tag)", image.ChannelLatest, image.ChannelStable, image.ChannelRelease),
+	},
+	{
+		name:     "background-pull",
+		flagType: "bool",
+		value:    "false",
+		helpMsg:  "Pull a newer image, if there is one, in the background once the container starts, for the next session",
+	},
+	{
+		name:     "variant",
+		flagType: "string",
+		helpMsg:  fmt.Sprintf("Image variant to use (one of %v); features needing tools the variant does not include are skipped", image.Variants),
+	},
 	{
 		name:     "publish-all-ports",
 		flagType: "bool",
@@ -171,12 +220,43 @@ var standardFlags = []cliFlag{
 		helpMsg:  "Publishes all defined ports to all interfaces. Equivalent of `--publish-all`",
 		hidden:   true,
 	},
+	{
+		name:     "profile",
+		flagType: "string",
+		helpMsg:  "Comma-separated list of config profiles to apply on top of the config file",
+	},
 	{
 		name:     "no-login",
 		flagType: "bool",
 		value:    "false",
 		helpMsg:  "Skips automatic cluster login when provided with a cluster id",
 	},
+	{
+		name:     "no-cluster-banner",
+		flagType: "bool",
+		value:    "false",
+		helpMsg:  "Skips the cluster status banner shown before logging in",
+	},
+	{
+		name:     "force",
+		flagType: "bool",
+		value:    "false",
+		helpMsg:  "Logs into the cluster even if the cluster guardrails would refuse or ask for confirmation",
+	},
+}
+
+// bindPersistentFlags binds the persistent flags to viper, so every
+// subcommand honors eg: --dry-run and --log-level, not only the root
+// command, which binds all its flags in checkFlags
+func bindPersistentFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Root().PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		bindErr := viper.BindPFlag(f.Name, f)
+		if bindErr != nil && err == nil {
+			err = fmt.Errorf("error binding flag %s: %v", f.Name, bindErr)
+		}
+	})
+	return err
 }
 
 // checkFlags looks up the required flags for the given cobra.Command,
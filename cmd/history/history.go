@@ -0,0 +1,231 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	persistenthistories "github.com/openshift/ocm-container/pkg/features/persistent-histories"
+	"github.com/openshift/ocm-container/pkg/percluster"
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+var (
+	clusterFlag    string
+	sinceFlag      string
+	ignoreCaseFlag bool
+)
+
+// HistoryCmd represents the history command
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Search the per-cluster persistent histories",
+	Long: `Search and display the per-cluster shell histories saved by the
+persistent-histories feature, without starting a container.
+
+Cluster names are read from the saved histories, or looked up in OCM
+if you are logged in.`,
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search PATTERN",
+	Short: "Search the histories of all clusters for a pattern",
+	Long: `Search the histories of all clusters for commands matching PATTERN,
+a regular expression.`,
+	Example: `ocm-container history search 'oc adm must-gather'
+ocm-container history search --cluster my-cluster --since 7d 'delete pod'`,
+	Args: cobra.ExactArgs(1),
+	RunE: search,
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show CLUSTER",
+	Short: "Show the history of a cluster",
+	Long:  `Show the saved history of a cluster, by name, ID or external ID.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  show,
+}
+
+// lookup finds saved histories by cluster name, ID or external ID
+type lookup struct {
+	*percluster.Resolver
+	store *persistenthistories.Store
+}
+
+func newLookup() (*lookup, error) {
+	store, err := persistenthistories.NewStore()
+	if err != nil {
+		return nil, err
+	}
+	return &lookup{Resolver: percluster.NewResolver(store), store: store}, nil
+}
+
+// entries returns the history entries of a cluster since the given time.
+// Entries without a timestamp are included if the history file was
+// modified since then.
+func (l *lookup) entries(id string, since time.Time) ([]persistenthistories.HistoryEntry, error) {
+	entries, err := l.store.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	if since.IsZero() {
+		return entries, nil
+	}
+
+	modified, err := l.store.Modified(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []persistenthistories.HistoryEntry{}
+	for _, e := range entries {
+		t := e.Time
+		if t.IsZero() {
+			t = modified
+		}
+		if !t.Before(since) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+func search(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	pattern := args[0]
+	if ignoreCaseFlag {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	since, err := parseSince(sinceFlag, time.Now())
+	if err != nil {
+		return err
+	}
+
+	l, err := newLookup()
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+	if clusterFlag != "" {
+		id, err := l.ClusterID(clusterFlag)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	} else {
+		ids, err = l.store.Clusters()
+		if err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	matches := 0
+	for _, id := range ids {
+		entries, err := l.entries(id, since)
+		if err != nil {
+			log.Warnf("unable to read history for cluster %s: %v", id, err)
+			continue
+		}
+		for _, e := range entries {
+			if !re.MatchString(e.Command) {
+				continue
+			}
+			if matches == 0 {
+				fmt.Fprintln(w, "CLUSTER\tTIME\tCOMMAND")
+			}
+			matches++
+			fmt.Fprintf(w, "%s\t%s\t%s\n", l.Name(id), formatTime(e.Time), e.Command)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if matches == 0 {
+		fmt.Fprintln(os.Stderr, "No matching commands found")
+	}
+	return nil
+}
+
+func show(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	since, err := parseSince(sinceFlag, time.Now())
+	if err != nil {
+		return err
+	}
+
+	l, err := newLookup()
+	if err != nil {
+		return err
+	}
+
+	id, err := l.ClusterID(args[0])
+	if err != nil {
+		return err
+	}
+
+	entries, err := l.entries(id, since)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "History for cluster %s (%s):\n", l.Name(id), id)
+	return printEntries(os.Stdout, entries)
+}
+
+func printEntries(out io.Writer, entries []persistenthistories.HistoryEntry) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\n", formatTime(e.Time), e.Command)
+	}
+	return w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(timeFormat)
+}
+
+// parseSince parses a duration such as 12h, 7d or 2w into the time
+// that long before now. An empty string returns the zero time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	d, err := utils.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since value: %v", err)
+	}
+	return now.Add(-d), nil
+}
+
+func init() {
+	searchCmd.Flags().StringVar(&clusterFlag, "cluster", "", "Only search the history of this cluster (name, ID or external ID)")
+	searchCmd.Flags().StringVar(&sinceFlag, "since", "", "Only include commands run within this long, eg: 12h, 7d, 2w")
+	searchCmd.Flags().BoolVarP(&ignoreCaseFlag, "ignore-case", "i", false, "Match the pattern case-insensitively")
+
+	showCmd.Flags().StringVar(&sinceFlag, "since", "", "Only include commands run within this long, eg: 12h, 7d, 2w")
+
+	HistoryCmd.AddCommand(searchCmd)
+	HistoryCmd.AddCommand(showCmd)
+}
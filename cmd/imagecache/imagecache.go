@@ -0,0 +1,188 @@
+package imagecache
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/engine"
+	imagecache "github.com/openshift/ocm-container/pkg/features/image-cache"
+	"github.com/openshift/ocm-container/pkg/ocmcontainer"
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultPullPolicy = "missing"
+
+var (
+	maxSizeFlag string
+	maxAgeFlag  string
+	allFlag     bool
+)
+
+// ImageCacheCmd represents the image-cache command
+var ImageCacheCmd = &cobra.Command{
+	Use:   "image-cache",
+	Short: "Manage the persistent container image cache",
+	Long: `Show the disk usage of the image cache used by the image-cache
+feature, and remove images from it.
+
+The cached images can only be read by the podman inside the ocm-container
+image, so these commands start a short-lived container from the image
+sessions use, with the configured engine and the cache mounted. They fail if an ocm-container
+session is using the cache.`,
+}
+
+var duCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show the disk usage of the image cache",
+	Args:  cobra.NoArgs,
+	RunE:  du,
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove images from the image cache",
+	Long: `Removes dangling images from the image cache, then any images over
+the max_age and max_size limits in the image_cache config. The limits can
+be overridden with --max-age and --max-size.`,
+	Example: `ocm-container image-cache prune
+ocm-container image-cache prune --max-size 10G --max-age 30d
+ocm-container image-cache prune --all`,
+	Args: cobra.NoArgs,
+	RunE: prune,
+}
+
+// withCache locks the image cache and runs fn with a Runner that runs
+// commands in a container with the cache mounted
+func withCache(fn func(*imagecache.Cache, imagecache.Runner) error) error {
+	cache, err := imagecache.NewCache()
+	if err != nil {
+		return err
+	}
+	err = cache.Lock()
+	if err != nil {
+		return err
+	}
+	defer cache.Unlock()
+
+	// Use the same image as sessions, with the channel, digest pin,
+	// variant and verification applied
+	ref, err := ocmcontainer.ResolveImage()
+	if err != nil {
+		return err
+	}
+
+	pullPolicy := viper.GetString("imagePullPolicy")
+	if pullPolicy == "" {
+		pullPolicy = defaultPullPolicy
+	}
+	e, err := engine.New(viper.GetString("engine"), pullPolicy, viper.GetBool("dry-run"))
+	if err != nil {
+		return err
+	}
+
+	caps, _, err := e.Capabilities()
+	if err != nil {
+		log.Debugf("unable to detect the capabilities of the container engine: %v", err)
+	}
+
+	c, err := e.Create(engine.ContainerRef{
+		Image: ref.String(),
+		Volumes: caps.RelabelMounts([]engine.VolumeMount{{
+			Source:       cache.Dir,
+			Destination:  imagecache.StorageMountPath,
+			MountOptions: "rw",
+		}}),
+		Privileged:      true,
+		RemoveAfterExit: true,
+		Entrypoint:      "sleep",
+		Command:         "infinity",
+	})
+	if err != nil {
+		return fmt.Errorf("error creating image cache container: %v", err)
+	}
+	defer func() {
+		err := e.Stop(c, 0)
+		if err != nil {
+			log.Debugf("error stopping image cache container: %v", err)
+		}
+	}()
+
+	err = e.Start(c, false)
+	if err != nil {
+		return fmt.Errorf("error starting image cache container: %v", err)
+	}
+
+	return fn(cache, func(args []string) (string, error) {
+		return e.Exec(c, args)
+	})
+}
+
+func du(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	return withCache(func(cache *imagecache.Cache, run imagecache.Runner) error {
+		out, err := imagecache.Usage(run)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Image cache in "+cache.Dir+":")
+		fmt.Print(out)
+		return nil
+	})
+}
+
+func prune(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	return withCache(func(cache *imagecache.Cache, run imagecache.Runner) error {
+		if allFlag {
+			out, err := run([]string{"podman", "image", "prune", "--all", "--force"})
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		}
+
+		limits := cache.Limits
+		if maxSizeFlag != "" {
+			size, err := utils.ParseSize(maxSizeFlag)
+			if err != nil {
+				return fmt.Errorf("invalid --max-size value: %v", err)
+			}
+			limits.MaxSize = size
+		}
+		if maxAgeFlag != "" {
+			age, err := utils.ParseDuration(maxAgeFlag)
+			if err != nil {
+				return fmt.Errorf("invalid --max-age value: %v", err)
+			}
+			limits.MaxAge = age
+		}
+
+		removed, err := imagecache.Collect(run, limits, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, r := range removed {
+			fmt.Println("Removed " + r)
+		}
+		if len(removed) == 0 {
+			fmt.Fprintln(os.Stderr, "No images over the limits")
+		}
+		return nil
+	})
+}
+
+func init() {
+	pruneCmd.Flags().StringVar(&maxSizeFlag, "max-size", "", "Remove the oldest images until the cache is smaller than this, eg: 10G")
+	pruneCmd.Flags().StringVar(&maxAgeFlag, "max-age", "", "Remove images built longer ago than this, eg: 30d, 8w")
+	pruneCmd.Flags().BoolVar(&allFlag, "all", false, "Remove all images not used by a container")
+
+	ImageCacheCmd.AddCommand(duCmd)
+	ImageCacheCmd.AddCommand(pruneCmd)
+}
@@ -0,0 +1,90 @@
+package pull
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/image"
+	"github.com/openshift/ocm-container/pkg/ocmcontainer"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultPullPolicy = "always"
+
+var policyFlag string
+
+// flagConfigKeys maps the pull command's flags to the config keys they
+// override, matching the flags of the same names on ocm-container
+var flagConfigKeys = map[string]string{
+	"image":     "image",
+	"image-tag": "imageTag",
+	"variant":   "variant",
+}
+
+// PullCmd represents the pull command
+var PullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Pull the ocm-container image",
+	Long: `Pulls the image ocm-container would run, with the configured tag,
+variant and signature verification applied, showing the container engine's
+progress. Run it ahead of time, eg: before an on-call shift, so sessions
+start without waiting for the image.`,
+	Example: `ocm-container pull
+ocm-container pull --image-tag stable --variant minimal
+ocm-container pull --policy newer`,
+	Args: cobra.NoArgs,
+	RunE: pull,
+}
+
+func pull(cmd *cobra.Command, args []string) error {
+	for flag, key := range flagConfigKeys {
+		err := viper.BindPFlag(key, cmd.Flags().Lookup(flag))
+		if err != nil {
+			return err
+		}
+	}
+	cmd.SilenceUsage = true
+
+	ref, err := ocmcontainer.ResolveImage()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(engine.SupportedPullImagePolicies, policyFlag) {
+		return fmt.Errorf("invalid --policy %q: must be one of %s", policyFlag, strings.Join(engine.SupportedPullImagePolicies, ", "))
+	}
+
+	// The configured imagePullPolicy is not used, since the point of the
+	// pull command is to pull the image
+	e, err := engine.New(viper.GetString("engine"), policyFlag, viper.GetBool("dry-run"))
+	if err != nil {
+		return err
+	}
+
+	err = e.Pull(ref.String())
+	if err != nil {
+		return err
+	}
+
+	digest, err := e.ImageDigest(ref.String())
+	if err != nil {
+		log.Debugf("unable to look up image digest: %v", err)
+	}
+	if digest == "" {
+		fmt.Println(ref.String())
+		return nil
+	}
+	fmt.Printf("%s (%s)\n", ref.String(), digest)
+	return nil
+}
+
+func init() {
+	PullCmd.Flags().StringP("image", "i", "", "The image to pull; defaults to the configured image")
+	PullCmd.Flags().String("image-tag", "", fmt.Sprintf("Image tag or channel to pull (%s, %s, %s or any tag)", image.ChannelLatest, image.ChannelStable, image.ChannelRelease))
+	PullCmd.Flags().String("variant", "", fmt.Sprintf("Image variant to pull (one of %v)", image.Variants))
+	PullCmd.Flags().StringVar(&policyFlag, "policy", defaultPullPolicy, fmt.Sprintf("Pull image policy (%s)", strings.Join(engine.SupportedPullImagePolicies, ", ")))
+}
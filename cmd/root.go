@@ -19,16 +19,27 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
+	configcmd "github.com/openshift/ocm-container/cmd/config"
+	fanoutcmd "github.com/openshift/ocm-container/cmd/fanout"
+	"github.com/openshift/ocm-container/cmd/history"
+	"github.com/openshift/ocm-container/cmd/imagecache"
+	"github.com/openshift/ocm-container/cmd/pull"
 	"github.com/openshift/ocm-container/cmd/version"
+	workspacecmd "github.com/openshift/ocm-container/cmd/workspace"
 	"github.com/openshift/ocm-container/pkg/features/registrar"
 	"github.com/openshift/ocm-container/pkg/log"
+	"github.com/openshift/ocm-container/pkg/multicluster"
 	"github.com/openshift/ocm-container/pkg/ocm"
 	"github.com/openshift/ocm-container/pkg/ocmcontainer"
+	"github.com/openshift/ocm-container/pkg/schema"
 	"github.com/openshift/ocm-container/pkg/subprocess"
 )
 
@@ -43,6 +54,7 @@ var errInContainer = errors.New("already running inside ocm-container; turtles a
 var vols []string
 var envs []string
 var execArgs []string
+var cobraArgs []string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -52,11 +64,19 @@ ocm-container [flags]  # opens a blank container without any cluster variables s
 ocm-container [flags] -- [command]			# execute a command in the container without logging into a cluster
 ocm-container --cluster-id CLUSTER_ID [flags]		# log into a cluster
 ocm-container --cluster-id CLUSTER_ID [flags] -- [command]	# execute a command inside the container after logging into a cluster
+ocm-container --cluster-id CLUSTER_A,CLUSTER_B [flags]	# open a session for each cluster in tmux
 `,
 	Short: "Launch an OCM container",
 	Long: `Launches a container with the OCM environment 
 and other Red Hat SRE tools`,
 	Args: cobra.NoArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		err := bindPersistentFlags(cmd)
+		if err != nil {
+			return err
+		}
+		return log.InitializeLogger()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		e := os.Getenv(ocmcManagedNameEnv)
@@ -84,9 +104,22 @@ and other Red Hat SRE tools`,
 			return err
 		}
 
+		warnConfigProblems()
+
 		// Append any volumes passed in as flags to the volumes slice from the config
 		viper.Set("vols", vols)
 
+		clusters, err := multicluster.ParseClusters(viper.GetString("cluster-id"), viper.GetString("clusters-from-file"))
+		if err != nil {
+			return err
+		}
+		if len(clusters) > 1 {
+			return launchClusters(cmd, clusters)
+		}
+		if len(clusters) == 1 {
+			viper.Set("cluster-id", clusters[0])
+		}
+
 		o, err := ocmcontainer.New(
 			cmd,
 			execArgs,
@@ -128,6 +161,12 @@ func Execute() {
 		if execErr, ok := err.(*subprocess.ExecErr); ok {
 			os.Exit(execErr.ExitErr.ExitCode())
 		}
+		// Exit with the status of a command run in the container, so
+		// scripts, and fanout, can tell how it failed
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -153,12 +192,12 @@ func splitArgs(args []string) ([]string, []string) {
 
 func init() {
 	cobra.OnInitialize(initConfig)
-	var cobraArgs []string
 
 	rootCmd.SetHelpTemplate(helpTemplate)
 
 	cobraArgs, execArgs = splitArgs(os.Args)
 	rootCmd.SetArgs(cobraArgs)
+	fanoutcmd.Command = execArgs
 
 	// Persistent flags available to subcommands; see flags.go
 	for _, f := range persistentFlags {
@@ -212,6 +251,66 @@ func init() {
 
 	// Register sub-commands
 	rootCmd.AddCommand(version.VersionCmd)
+	rootCmd.AddCommand(configcmd.ConfigCmd)
+	rootCmd.AddCommand(fanoutcmd.FanoutCmd)
+	rootCmd.AddCommand(history.HistoryCmd)
+	rootCmd.AddCommand(imagecache.ImageCacheCmd)
+	rootCmd.AddCommand(pull.PullCmd)
+	rootCmd.AddCommand(workspacecmd.WorkspaceCmd)
+}
+
+// launchClusters opens a session for each cluster in tmux, each running
+// ocm-container with the same flags for a single cluster
+func launchClusters(cmd *cobra.Command, clusters []string) error {
+	// Check the mode and tmux before logging in, to fail fast
+	mode := viper.GetString("multiClusterMode")
+	err := multicluster.ValidateMode(mode)
+	if err != nil {
+		return err
+	}
+
+	launcher, err := multicluster.NewLauncher(func(name string) bool {
+		var f *pflag.Flag
+		switch {
+		case strings.HasPrefix(name, "--"):
+			f = cmd.Flags().Lookup(strings.TrimPrefix(name, "--"))
+		case len(name) == 2:
+			f = cmd.Flags().ShorthandLookup(strings.TrimPrefix(name, "-"))
+		}
+		return f != nil && f.Value.Type() != "bool"
+	})
+	if err != nil {
+		return err
+	}
+
+	// Log in and check the clusters exist here, rather than in every
+	// session
+	err = ocmcontainer.LookUpClusters(clusters)
+	if err != nil {
+		return err
+	}
+	return launcher.Launch(mode, clusters, cobraArgs, execArgs)
+}
+
+// warnConfigProblems validates the config file against the generated
+// schema and warns about any problems, such as misspelled keys, which
+// would otherwise be silently ignored
+func warnConfigProblems() {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return
+	}
+	problems, err := schema.Generate().ValidateFile(file)
+	if err != nil {
+		logrus.Debugf("unable to validate config file: %v", err)
+		return
+	}
+	for _, p := range problems {
+		logrus.Warnf("config file %s: %s", file, p.String())
+	}
+	if len(problems) > 0 {
+		logrus.Warnf("run `%s config validate` for details", programName)
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
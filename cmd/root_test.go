@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	fanoutcmd "github.com/openshift/ocm-container/cmd/fanout"
+	"github.com/openshift/ocm-container/pkg/multicluster"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/spf13/viper"
 )
 
 func TestSplitArgs(t *testing.T) {
@@ -82,3 +90,81 @@ func TestSplitArgs(t *testing.T) {
 		})
 	}
 }
+
+// fakeEngine puts a fake podman on the PATH, which records the commands
+// it is run with, and returns the file they are recorded in
+func fakeEngine(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n"
+	err := os.WriteFile(filepath.Join(dir, "podman"), []byte(script), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	return calls
+}
+
+// executeDryRun runs ocm-container with the args and --dry-run, and
+// fails if the container engine was run
+func executeDryRun(t *testing.T, args ...string) {
+	t.Helper()
+	calls := fakeEngine(t)
+
+	rootCmd.SetArgs(append(args, "--dry-run", "--config", filepath.Join(t.TempDir(), "missing.yaml")))
+	t.Cleanup(func() { rootCmd.SetArgs(cobraArgs) })
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err == nil {
+		t.Errorf("the container engine was run in a dry run:\n%s", data)
+	} else if !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestPullDryRun(t *testing.T) {
+	executeDryRun(t, "pull")
+}
+
+func TestImageCacheDryRun(t *testing.T) {
+	executeDryRun(t, "image-cache", "du")
+	executeDryRun(t, "image-cache", "prune")
+}
+
+// fanout passes every login flag on to each cluster's ocm-container,
+// so each must be a flag of both commands
+func TestFanoutLoginFlags(t *testing.T) {
+	for _, name := range ocm.LoginFlags {
+		if rootCmd.Flags().Lookup(name) == nil {
+			t.Errorf("--%s is not a flag of ocm-container", name)
+		}
+		if fanoutcmd.FanoutCmd.Flags().Lookup(name) == nil {
+			t.Errorf("--%s is not a flag of fanout", name)
+		}
+	}
+}
+
+// The mode is checked before logging into OCM to look up the clusters
+func TestMultiClusterModeValidatedFirst(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OCM_CONFIG", filepath.Join(t.TempDir(), "ocm.json"))
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() {
+		rootCmd.SetArgs(cobraArgs)
+		_ = rootCmd.Flags().Set("cluster-id", "")
+		_ = rootCmd.Flags().Set("multi-cluster-mode", multicluster.ModePanes)
+	})
+
+	rootCmd.SetArgs([]string{"--cluster-id", "a,b", "--multi-cluster-mode", "windows", "--config", filepath.Join(t.TempDir(), "missing.yaml")})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "invalid multi-cluster mode") {
+		t.Errorf("expected an invalid multi-cluster mode error, got: %v", err)
+	}
+}
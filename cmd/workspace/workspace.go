@@ -0,0 +1,255 @@
+package workspace
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/features/workspace"
+	"github.com/openshift/ocm-container/pkg/percluster"
+	"github.com/openshift/ocm-container/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const timeFormat = "2006-01-02 15:04"
+
+var (
+	printFlag     bool
+	olderThanFlag string
+	yesFlag       bool
+)
+
+// WorkspaceCmd represents the workspace command
+var WorkspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Manage the per-cluster persistent workspaces",
+	Long: `List, open and prune the per-cluster workspaces saved by the
+workspace feature, without starting a container.
+
+Clusters may be given by name, ID or external ID. Names are read from
+the saved workspaces, or looked up in OCM if you are logged in.`,
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [CLUSTER]",
+	Short: "List workspaces, or the paths in a cluster's workspace",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  ls,
+}
+
+var openCmd = &cobra.Command{
+	Use:   "open CLUSTER [PATH]",
+	Short: "Open a cluster's workspace on the host",
+	Long: `Opens a cluster's workspace, or one of its paths, in the host's file
+manager. With --print, only prints the directory, eg:
+
+  cd $(ocm-container workspace open --print my-cluster workspace)`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: open,
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [CLUSTER]",
+	Short: "Remove a cluster's workspace, or all unused workspaces",
+	Example: `ocm-container workspace prune my-cluster
+ocm-container workspace prune --older-than 30d --yes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: prune,
+}
+
+func newResolver() (*workspace.Store, *percluster.Resolver, error) {
+	store, err := workspace.NewStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, percluster.NewResolver(store), nil
+}
+
+func ls(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	store, r, err := newResolver()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	if len(args) == 1 {
+		id, err := r.ClusterID(args[0])
+		if err != nil {
+			return err
+		}
+		paths, err := store.Paths(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Workspace for cluster %s (%s):\n", r.Name(id), id)
+		fmt.Fprintln(w, "PATH\tSIZE\tLAST MODIFIED\tHOST PATH")
+		for _, p := range paths {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name, utils.FormatSize(p.Size), p.Modified.Local().Format(timeFormat), p.HostPath)
+		}
+		return w.Flush()
+	}
+
+	ids, err := store.Clusters()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintln(os.Stderr, "No workspaces found in "+store.Dir)
+		return nil
+	}
+
+	fmt.Fprintln(w, "CLUSTER\tID\tSIZE\tLAST MODIFIED")
+	for _, id := range ids {
+		size, err := store.Size(id)
+		if err != nil {
+			return err
+		}
+		modified, err := store.Modified(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name(id), id, utils.FormatSize(size), modified.Local().Format(timeFormat))
+	}
+	return w.Flush()
+}
+
+func open(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	store, r, err := newResolver()
+	if err != nil {
+		return err
+	}
+
+	id, err := r.ClusterID(args[0])
+	if err != nil {
+		return err
+	}
+
+	dir := store.Path(id)
+	if len(args) == 2 {
+		paths, err := store.Paths(id)
+		if err != nil {
+			return err
+		}
+		dir = ""
+		for _, p := range paths {
+			if p.Name == args[1] {
+				dir = p.HostPath
+			}
+		}
+		if dir == "" {
+			return fmt.Errorf("cluster %s has no workspace path %s", args[0], args[1])
+		}
+	}
+
+	fmt.Println(dir)
+	if printFlag {
+		return nil
+	}
+
+	name := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		name = "open"
+	}
+	return exec.Command(name, dir).Start()
+}
+
+func prune(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && olderThanFlag == "" {
+		return fmt.Errorf("a cluster or --older-than is required")
+	}
+	cmd.SilenceUsage = true
+
+	store, r, err := newResolver()
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+	if len(args) == 1 {
+		id, err := r.ClusterID(args[0])
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	} else {
+		ids, err = store.Clusters()
+		if err != nil {
+			return err
+		}
+	}
+
+	var cutoff time.Time
+	if olderThanFlag != "" {
+		d, err := utils.ParseDuration(olderThanFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than value: %v", err)
+		}
+		cutoff = time.Now().Add(-d)
+	}
+
+	// One reader for every prompt, so answers piped in together are
+	// not lost to the buffer of an earlier prompt
+	in := bufio.NewReader(os.Stdin)
+	removed := 0
+	for _, id := range ids {
+		size, err := store.Size(id)
+		if err != nil {
+			return err
+		}
+		if !cutoff.IsZero() {
+			modified, err := store.Modified(id)
+			if err != nil {
+				return err
+			}
+			if !modified.Before(cutoff) {
+				continue
+			}
+		}
+
+		if !yesFlag && !confirm(in, fmt.Sprintf("Remove the workspace for cluster %s (%s, %s)?", r.Name(id), id, utils.FormatSize(size))) {
+			continue
+		}
+		err = store.Remove(id)
+		if err != nil {
+			return err
+		}
+		removed++
+		fmt.Printf("Removed the workspace for cluster %s (%s)\n", r.Name(id), id)
+	}
+
+	if removed == 0 {
+		fmt.Fprintln(os.Stderr, "No workspaces removed")
+	}
+	return nil
+}
+
+func confirm(in *bufio.Reader, prompt string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, err := in.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	openCmd.Flags().BoolVar(&printFlag, "print", false, "Only print the directory, without opening it")
+
+	pruneCmd.Flags().StringVar(&olderThanFlag, "older-than", "", "Only remove workspaces not modified within this long, eg: 30d, 8w")
+	pruneCmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Remove workspaces without asking for confirmation")
+
+	WorkspaceCmd.AddCommand(lsCmd)
+	WorkspaceCmd.AddCommand(openCmd)
+	WorkspaceCmd.AddCommand(pruneCmd)
+}
@@ -0,0 +1,16 @@
+package workspace
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestConfirmReadsEachAnswer(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("y\nn\nyes\n"))
+	for i, expected := range []bool{true, false, true, false} {
+		if got := confirm(in, "Remove?"); got != expected {
+			t.Errorf("answer %d: expected %v, got %v", i, expected, got)
+		}
+	}
+}
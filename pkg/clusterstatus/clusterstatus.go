@@ -0,0 +1,337 @@
+package clusterstatus
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	slv1 "github.com/openshift-online/ocm-sdk-go/servicelogs/v1"
+	"github.com/openshift/ocm-container/pkg/log"
+	"github.com/openshift/ocm-container/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The clusterstatus package looks up what someone logging into a cluster
+// should know first: its state, version, limited support reasons, recent
+// service logs, whether access protection is enabled and, for HyperShift
+// clusters, its management cluster. It is shown as a banner before the
+// container starts, and the guardrails decide whether to log in at all.
+
+const (
+	// BannerConfigKey is the config key of the banner's options
+	BannerConfigKey = "clusterBanner"
+	// NoBannerFlag disables the banner
+	NoBannerFlag = "no-cluster-banner"
+
+	dateFormat = "2006-01-02"
+
+	// maxSummaryLength is the longest service log summary shown
+	maxSummaryLength = 100
+)
+
+// BannerConfig is the config of the banner shown before logging in
+type BannerConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ServiceLogs is the number of recent service logs shown
+	ServiceLogs int `mapstructure:"serviceLogs"`
+	// ServiceLogsSince is how far back service logs are shown, eg: 7d
+	ServiceLogsSince string `mapstructure:"serviceLogsSince"`
+}
+
+// DefaultBannerConfig returns the banner config defaults
+func DefaultBannerConfig() BannerConfig {
+	return BannerConfig{
+		Enabled:          true,
+		ServiceLogs:      3,
+		ServiceLogsSince: "7d",
+	}
+}
+
+// ReadBannerConfig returns the banner config, with defaults for
+// anything not configured
+func ReadBannerConfig() (BannerConfig, error) {
+	cfg := DefaultBannerConfig()
+	err := viper.UnmarshalKey(BannerConfigKey, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid %s config: %v", BannerConfigKey, err)
+	}
+	if viper.GetBool(NoBannerFlag) {
+		cfg.Enabled = false
+	}
+	return cfg, nil
+}
+
+// Options returns the lookup options for the banner
+func (c BannerConfig) Options() (Options, error) {
+	opts := Options{ServiceLogs: c.ServiceLogs}
+	if c.ServiceLogsSince == "" {
+		return opts, nil
+	}
+	since, err := utils.ParseDuration(c.ServiceLogsSince)
+	if err != nil {
+		return opts, fmt.Errorf("invalid %s.serviceLogsSince: %v", BannerConfigKey, err)
+	}
+	opts.ServiceLogsSince = since
+	return opts, nil
+}
+
+// Options control what is looked up
+type Options struct {
+	// ServiceLogs is the number of recent service logs to look up
+	ServiceLogs int
+	// ServiceLogsSince is how far back to look for service logs
+	ServiceLogsSince time.Duration
+}
+
+// LimitedSupportReason is why a cluster is in limited support
+type LimitedSupportReason struct {
+	Summary string
+	Details string
+	Created time.Time
+}
+
+// ServiceLog is a service log sent for a cluster
+type ServiceLog struct {
+	Time         time.Time
+	Severity     string
+	Summary      string
+	InternalOnly bool
+}
+
+// Status is the status of a cluster
+type Status struct {
+	Cluster *cmv1.Cluster
+
+	LimitedSupportReasons []LimitedSupportReason
+	ServiceLogs           []ServiceLog
+	// ServiceLogsSince is how far back ServiceLogs were looked up
+	ServiceLogsSince time.Duration
+	// ManagementCluster is the HyperShift management cluster, if any
+	ManagementCluster string
+	// AccessProtection is true if access to the cluster must be
+	// approved by the customer
+	AccessProtection bool
+}
+
+// Fetch looks up a cluster's status. Anything the user does not have
+// permission to read is left out, rather than failing.
+func Fetch(conn *sdk.Connection, cluster *cmv1.Cluster, opts Options) *Status {
+	s := &Status{Cluster: cluster, ServiceLogsSince: opts.ServiceLogsSince}
+	clusterResource := conn.ClustersMgmt().V1().Clusters().Cluster(cluster.ID())
+
+	if cluster.Status().LimitedSupportReasonCount() > 0 {
+		resp, err := clusterResource.LimitedSupportReasons().List().Send()
+		if err != nil {
+			logrus.Debugf("unable to look up limited support reasons: %v", err)
+		} else {
+			for _, r := range resp.Items().Slice() {
+				s.LimitedSupportReasons = append(s.LimitedSupportReasons, LimitedSupportReason{
+					Summary: r.Summary(),
+					Details: r.Details(),
+					Created: r.CreationTimestamp(),
+				})
+			}
+		}
+	}
+
+	if opts.ServiceLogs > 0 {
+		search := fmt.Sprintf("cluster_id = '%s'", cluster.ID())
+		if opts.ServiceLogsSince > 0 {
+			since := time.Now().Add(-opts.ServiceLogsSince).UTC().Format(time.RFC3339)
+			search += fmt.Sprintf(" and timestamp >= '%s'", since)
+		}
+		resp, err := conn.ServiceLogs().V1().Clusters().ClusterLogs().List().
+			Search(search).
+			Order("timestamp desc").
+			Size(opts.ServiceLogs).
+			Send()
+		if err != nil {
+			logrus.Debugf("unable to look up service logs: %v", err)
+		} else {
+			for _, l := range resp.Items().Slice() {
+				s.ServiceLogs = append(s.ServiceLogs, ServiceLog{
+					Time:         l.Timestamp(),
+					Severity:     string(l.Severity()),
+					Summary:      l.Summary(),
+					InternalOnly: l.InternalOnly(),
+				})
+			}
+		}
+	}
+
+	resp, err := conn.AccessTransparency().V1().AccessProtection().Get().ClusterId(cluster.ID()).Send()
+	if err != nil {
+		logrus.Debugf("unable to look up access protection: %v", err)
+	} else {
+		s.AccessProtection = resp.Body().Enabled()
+	}
+
+	if cluster.Hypershift().Enabled() {
+		resp, err := clusterResource.Hypershift().Get().Send()
+		if err != nil {
+			logrus.Debugf("unable to look up the management cluster: %v", err)
+		} else {
+			s.ManagementCluster = resp.Body().ManagementCluster()
+		}
+	}
+	return s
+}
+
+// Ready returns true if the cluster is ready
+func (s *Status) Ready() bool {
+	return s.Cluster.State() == cmv1.ClusterStateReady
+}
+
+// Conditions returns what the user must not miss about the cluster
+func (s *Status) Conditions() []Condition {
+	conditions := []Condition{}
+	name := s.Cluster.Name()
+
+	if !s.Ready() {
+		condition := ConditionNotReady
+		switch s.Cluster.State() {
+		case cmv1.ClusterStateUninstalling:
+			condition = ConditionUninstalling
+		case cmv1.ClusterStateError:
+			condition = ConditionError
+		case cmv1.ClusterStateHibernating:
+			condition = ConditionHibernating
+		}
+		conditions = append(conditions, Condition{condition, fmt.Sprintf("cluster %s is %s, not ready", name, s.state())})
+	}
+
+	if n := len(s.LimitedSupportReasons); n > 0 {
+		summaries := []string{}
+		for _, r := range s.LimitedSupportReasons {
+			summaries = append(summaries, r.Summary)
+		}
+		conditions = append(conditions, Condition{ConditionLimitedSupport, fmt.Sprintf("cluster %s is in limited support: %s", name, strings.Join(summaries, "; "))})
+	} else if s.Cluster.Status().LimitedSupportReasonCount() > 0 {
+		// The reasons could not be read
+		conditions = append(conditions, Condition{ConditionLimitedSupport, fmt.Sprintf("cluster %s is in limited support", name)})
+	}
+
+	if s.AccessProtection {
+		conditions = append(conditions, Condition{ConditionAccessProtection, fmt.Sprintf("cluster %s has access protection enabled, so access must be approved by the customer", name)})
+	}
+	return conditions
+}
+
+// WriteBanner writes a summary of the cluster, colored like the log
+func (s *Status) WriteBanner(w io.Writer) error {
+	c := s.Cluster
+	info := log.LevelColor(logrus.InfoLevel)
+	warn := log.LevelColor(logrus.WarnLevel)
+	danger := log.LevelColor(logrus.ErrorLevel)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Cluster:\t%s (%s)\n", c.Name(), c.ID())
+
+	state := s.state()
+	switch c.State() {
+	case cmv1.ClusterStateReady:
+		state = info(state)
+	case cmv1.ClusterStateError, cmv1.ClusterStateUninstalling:
+		state = danger(state)
+	default:
+		state = warn(state)
+	}
+	fmt.Fprintf(tw, "State:\t%s\n", state)
+
+	if version := c.OpenshiftVersion(); version != "" {
+		if channel := c.Version().ChannelGroup(); channel != "" {
+			version += " (" + channel + ")"
+		}
+		fmt.Fprintf(tw, "Version:\t%s\n", version)
+	}
+
+	product := []string{}
+	for _, p := range []string{c.Product().ID(), c.CloudProvider().ID(), c.Region().ID()} {
+		if p != "" {
+			product = append(product, p)
+		}
+	}
+	if c.Hypershift().Enabled() {
+		product = append(product, "HCP")
+	}
+	if len(product) > 0 {
+		fmt.Fprintf(tw, "Product:\t%s\n", strings.Join(product, " "))
+	}
+	if s.ManagementCluster != "" {
+		fmt.Fprintf(tw, "Management cluster:\t%s\n", s.ManagementCluster)
+	}
+	if s.AccessProtection {
+		fmt.Fprintf(tw, "Access protection:\t%s\n", warn("enabled"))
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if len(s.LimitedSupportReasons) > 0 {
+		fmt.Fprintln(w, danger("Limited support:"))
+		for _, r := range s.LimitedSupportReasons {
+			fmt.Fprintf(w, "  %s %s\n", formatDate(r.Created), danger(r.Summary))
+		}
+	}
+
+	if len(s.ServiceLogs) > 0 {
+		header := "Recent service logs:"
+		if s.ServiceLogsSince > 0 {
+			header = fmt.Sprintf("Service logs in the last %s:", formatDuration(s.ServiceLogsSince))
+		}
+		fmt.Fprintln(w, header)
+		for _, l := range s.ServiceLogs {
+			severity := l.Severity
+			switch slv1.Severity(l.Severity) {
+			case slv1.SeverityCritical, slv1.SeverityFatal, slv1.SeverityError, slv1.SeverityMajor:
+				severity = danger(severity)
+			case slv1.SeverityWarning, slv1.SeverityImportant:
+				severity = warn(severity)
+			}
+			// Truncate by runes, so multi-byte characters are not split
+			summary := l.Summary
+			if runes := []rune(summary); len(runes) > maxSummaryLength {
+				summary = string(runes[:maxSummaryLength-3]) + "..."
+			}
+			if l.InternalOnly {
+				summary += " (internal)"
+			}
+			fmt.Fprintf(w, "  %s [%s] %s\n", formatDate(l.Time), severity, summary)
+		}
+	}
+	return nil
+}
+
+// state returns the cluster's state, with its description if it has one
+func (s *Status) state() string {
+	state := string(s.Cluster.State())
+	if state == "" {
+		state = string(cmv1.ClusterStateUnknown)
+	}
+	if desc := s.Cluster.Status().Description(); desc != "" && !s.Ready() {
+		state += ": " + desc
+	}
+	return state
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "          "
+	}
+	return t.Local().Format(dateFormat)
+}
+
+// formatDuration formats whole days as days, eg: 7d rather than 168h0m0s
+func formatDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,13 @@
+package clusterstatus
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestClusterstatus(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Clusterstatus Suite")
+}
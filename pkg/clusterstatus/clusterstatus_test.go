@@ -0,0 +1,196 @@
+package clusterstatus
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/spf13/viper"
+)
+
+func buildCluster(b *cmv1.ClusterBuilder) *cmv1.Cluster {
+	c, err := b.ID("abc123").Name("my-cluster").Build()
+	Expect(err).ToNot(HaveOccurred())
+	return c
+}
+
+var _ = Describe("Status", func() {
+	Describe("Conditions", func() {
+		It("has no conditions for a ready cluster", func() {
+			s := &Status{Cluster: buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateReady))}
+			Expect(s.Ready()).To(BeTrue())
+			Expect(s.Conditions()).To(BeEmpty())
+		})
+
+		DescribeTable("names the condition of a cluster which is not ready",
+			func(state cmv1.ClusterState, condition string) {
+				s := &Status{Cluster: buildCluster(cmv1.NewCluster().State(state))}
+				Expect(s.Ready()).To(BeFalse())
+				Expect(s.Conditions()).To(ConsistOf(Condition{condition, "cluster my-cluster is " + string(state) + ", not ready"}))
+			},
+			Entry("uninstalling", cmv1.ClusterStateUninstalling, ConditionUninstalling),
+			Entry("error", cmv1.ClusterStateError, ConditionError),
+			Entry("hibernating", cmv1.ClusterStateHibernating, ConditionHibernating),
+			Entry("installing", cmv1.ClusterStateInstalling, ConditionNotReady),
+		)
+
+		It("includes the state's description", func() {
+			s := &Status{Cluster: buildCluster(cmv1.NewCluster().
+				State(cmv1.ClusterStateHibernating).
+				Status(cmv1.NewClusterStatus().Description("hibernating since yesterday")))}
+			Expect(s.Conditions()).To(ConsistOf(Condition{ConditionHibernating, "cluster my-cluster is hibernating: hibernating since yesterday, not ready"}))
+		})
+
+		It("includes limited support with the reasons", func() {
+			s := &Status{
+				Cluster: buildCluster(cmv1.NewCluster().
+					State(cmv1.ClusterStateReady).
+					Status(cmv1.NewClusterStatus().LimitedSupportReasonCount(2))),
+				LimitedSupportReasons: []LimitedSupportReason{{Summary: "Cluster is out of support"}, {Summary: "Missing IAM role"}},
+			}
+			Expect(s.Conditions()).To(ConsistOf(Condition{ConditionLimitedSupport, "cluster my-cluster is in limited support: Cluster is out of support; Missing IAM role"}))
+		})
+
+		It("includes limited support when the reasons could not be read", func() {
+			s := &Status{Cluster: buildCluster(cmv1.NewCluster().
+				State(cmv1.ClusterStateReady).
+				Status(cmv1.NewClusterStatus().LimitedSupportReasonCount(1)))}
+			Expect(s.Conditions()).To(ConsistOf(Condition{ConditionLimitedSupport, "cluster my-cluster is in limited support"}))
+		})
+
+		It("includes access protection", func() {
+			s := &Status{
+				Cluster:          buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateReady)),
+				AccessProtection: true,
+			}
+			Expect(s.Conditions()).To(HaveExactElements(HaveField("Name", ConditionAccessProtection)))
+		})
+	})
+
+	Describe("WriteBanner", func() {
+		It("summarizes the cluster", func() {
+			created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			s := &Status{
+				Cluster: buildCluster(cmv1.NewCluster().
+					State(cmv1.ClusterStateReady).
+					OpenshiftVersion("4.16.3").
+					Version(cmv1.NewVersion().ChannelGroup("stable")).
+					Product(cmv1.NewProduct().ID("rosa")).
+					CloudProvider(cmv1.NewCloudProvider().ID("aws")).
+					Region(cmv1.NewCloudRegion().ID("us-east-1")).
+					Hypershift(cmv1.NewHypershift().Enabled(true))),
+				ManagementCluster: "hs-mc-1",
+				AccessProtection:  true,
+				LimitedSupportReasons: []LimitedSupportReason{
+					{Summary: "Missing IAM role", Created: created},
+				},
+				ServiceLogsSince: 7 * 24 * time.Hour,
+				ServiceLogs: []ServiceLog{
+					{Time: created, Severity: "Warning", Summary: "Upgrade scheduled", InternalOnly: true},
+				},
+			}
+
+			out := &bytes.Buffer{}
+			Expect(s.WriteBanner(out)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("my-cluster (abc123)"))
+			Expect(out.String()).To(MatchRegexp(`State:\s+ready\n`))
+			Expect(out.String()).To(MatchRegexp(`Version:\s+4\.16\.3 \(stable\)`))
+			Expect(out.String()).To(MatchRegexp(`Product:\s+rosa aws us-east-1 HCP`))
+			Expect(out.String()).To(MatchRegexp(`Management cluster:\s+hs-mc-1`))
+			Expect(out.String()).To(MatchRegexp(`Access protection:\s+enabled`))
+			Expect(out.String()).To(ContainSubstring("Limited support:"))
+			Expect(out.String()).To(ContainSubstring("Missing IAM role"))
+			Expect(out.String()).To(ContainSubstring("Service logs in the last 7d:"))
+			Expect(out.String()).To(ContainSubstring("[Warning] Upgrade scheduled (internal)"))
+		})
+
+		It("leaves out what is not known", func() {
+			s := &Status{Cluster: buildCluster(cmv1.NewCluster())}
+
+			out := &bytes.Buffer{}
+			Expect(s.WriteBanner(out)).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`State:\s+unknown\n`))
+			Expect(out.String()).ToNot(ContainSubstring("Version:"))
+			Expect(out.String()).ToNot(ContainSubstring("Product:"))
+			Expect(out.String()).ToNot(ContainSubstring("service logs"))
+		})
+
+		It("truncates long service log summaries", func() {
+			long := ""
+			for range maxSummaryLength {
+				long += "x"
+			}
+			s := &Status{
+				Cluster:     buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateReady)),
+				ServiceLogs: []ServiceLog{{Severity: "Info", Summary: long + "y"}},
+			}
+
+			out := &bytes.Buffer{}
+			Expect(s.WriteBanner(out)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("Recent service logs:"))
+			Expect(out.String()).To(ContainSubstring(long[:maxSummaryLength-3] + "...\n"))
+		})
+
+		It("truncates service log summaries without splitting characters", func() {
+			long := strings.Repeat("é", maxSummaryLength+1)
+			s := &Status{
+				Cluster:     buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateReady)),
+				ServiceLogs: []ServiceLog{{Severity: "Info", Summary: long}},
+			}
+
+			out := &bytes.Buffer{}
+			Expect(s.WriteBanner(out)).To(Succeed())
+			Expect(utf8.Valid(out.Bytes())).To(BeTrue())
+			Expect(out.String()).To(ContainSubstring(strings.Repeat("é", maxSummaryLength-3) + "...\n"))
+		})
+	})
+})
+
+var _ = Describe("BannerConfig", func() {
+	AfterEach(func() {
+		viper.Reset()
+	})
+
+	It("defaults to showing the last week's service logs", func() {
+		cfg, err := ReadBannerConfig()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).To(Equal(DefaultBannerConfig()))
+
+		opts, err := cfg.Options()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opts).To(Equal(Options{ServiceLogs: 3, ServiceLogsSince: 7 * 24 * time.Hour}))
+	})
+
+	It("reads the config, keeping the defaults of unset options", func() {
+		viper.Set(BannerConfigKey, map[string]any{"serviceLogs": 5})
+		cfg, err := ReadBannerConfig()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).To(Equal(BannerConfig{Enabled: true, ServiceLogs: 5, ServiceLogsSince: "7d"}))
+	})
+
+	It("is disabled by the flag", func() {
+		viper.Set(NoBannerFlag, true)
+		cfg, err := ReadBannerConfig()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Enabled).To(BeFalse())
+	})
+
+	It("rejects an invalid duration", func() {
+		_, err := BannerConfig{ServiceLogsSince: "a while"}.Options()
+		Expect(err).To(MatchError(ContainSubstring("serviceLogsSince")))
+	})
+})
+
+var _ = Describe("formatDuration", func() {
+	It("formats whole days as days", func() {
+		Expect(formatDuration(14 * 24 * time.Hour)).To(Equal("14d"))
+	})
+
+	It("formats other durations as Go durations", func() {
+		Expect(formatDuration(36 * time.Hour)).To(Equal("36h0m0s"))
+	})
+})
@@ -0,0 +1,156 @@
+package clusterstatus
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// GuardrailsConfigKey is the config key of the guardrails' actions
+	GuardrailsConfigKey = "clusterGuardrails"
+	// ForceFlag logs into the cluster regardless of the guardrails
+	ForceFlag = "force"
+
+	// ActionAllow logs into the cluster without a warning
+	ActionAllow = "allow"
+	// ActionWarn logs a warning, then logs into the cluster
+	ActionWarn = "warn"
+	// ActionConfirm asks before logging into the cluster
+	ActionConfirm = "confirm"
+	// ActionDeny refuses to log into the cluster
+	ActionDeny = "deny"
+)
+
+// Actions are the supported guardrail actions
+var Actions = []string{ActionAllow, ActionWarn, ActionConfirm, ActionDeny}
+
+// The conditions the guardrails act on
+const (
+	ConditionUninstalling     = "uninstalling"
+	ConditionError            = "error"
+	ConditionHibernating      = "hibernating"
+	ConditionNotReady         = "notReady"
+	ConditionLimitedSupport   = "limitedSupport"
+	ConditionAccessProtection = "accessProtection"
+)
+
+// Condition is something about a cluster which someone logging into it
+// must not miss
+type Condition struct {
+	Name    string
+	Message string
+}
+
+// Guardrails are the actions taken when logging into a cluster with
+// each condition
+type Guardrails struct {
+	Uninstalling string `mapstructure:"uninstalling"`
+	Error        string `mapstructure:"error"`
+	Hibernating  string `mapstructure:"hibernating"`
+	// NotReady is any other state than ready, eg: installing
+	NotReady         string `mapstructure:"notReady"`
+	LimitedSupport   string `mapstructure:"limitedSupport"`
+	AccessProtection string `mapstructure:"accessProtection"`
+}
+
+// DefaultGuardrails returns the guardrail defaults: dead clusters are
+// refused, and clusters under restrictions need confirmation
+func DefaultGuardrails() Guardrails {
+	return Guardrails{
+		Uninstalling:     ActionDeny,
+		Error:            ActionConfirm,
+		Hibernating:      ActionDeny,
+		NotReady:         ActionWarn,
+		LimitedSupport:   ActionConfirm,
+		AccessProtection: ActionConfirm,
+	}
+}
+
+// ReadGuardrails returns the guardrails config, with defaults for
+// anything not configured
+func ReadGuardrails() (Guardrails, error) {
+	g := DefaultGuardrails()
+	err := viper.UnmarshalKey(GuardrailsConfigKey, &g)
+	if err != nil {
+		return g, fmt.Errorf("invalid %s config: %v", GuardrailsConfigKey, err)
+	}
+	return g, g.validate()
+}
+
+func (g Guardrails) validate() error {
+	for _, condition := range []string{
+		ConditionUninstalling,
+		ConditionError,
+		ConditionHibernating,
+		ConditionNotReady,
+		ConditionLimitedSupport,
+		ConditionAccessProtection,
+	} {
+		action := g.action(condition)
+		if !slices.Contains(Actions, action) {
+			return fmt.Errorf("invalid %s.%s %q: must be one of %s", GuardrailsConfigKey, condition, action, strings.Join(Actions, ", "))
+		}
+	}
+	return nil
+}
+
+// action returns the action taken for a condition
+func (g Guardrails) action(condition string) string {
+	switch condition {
+	case ConditionUninstalling:
+		return g.Uninstalling
+	case ConditionError:
+		return g.Error
+	case ConditionHibernating:
+		return g.Hibernating
+	case ConditionNotReady:
+		return g.NotReady
+	case ConditionLimitedSupport:
+		return g.LimitedSupport
+	case ConditionAccessProtection:
+		return g.AccessProtection
+	}
+	return ActionWarn
+}
+
+// Enforce takes the action for each of the cluster's conditions. It
+// returns an error if logging in is denied, or not confirmed. With
+// force, denied and unconfirmed conditions are only warned about.
+// confirm asks the user, and returns an error if it is not able to.
+func (g Guardrails) Enforce(s *Status, force bool, confirm func(prompt string) (bool, error)) error {
+	for _, c := range s.Conditions() {
+		action := g.action(c.Name)
+		if force && (action == ActionConfirm || action == ActionDeny) {
+			logrus.Warnf("%s; continuing because of --%s", c.Message, ForceFlag)
+			continue
+		}
+
+		switch action {
+		case ActionAllow:
+		case ActionWarn:
+			logrus.Warn(c.Message)
+		case ActionConfirm:
+			ok, err := confirm(fmt.Sprintf("%s. Log in anyway?", capitalize(c.Message)))
+			if err != nil {
+				return fmt.Errorf("%s, and logging in needs confirmation: %v; pass --%s to log in anyway", c.Message, err, ForceFlag)
+			}
+			if !ok {
+				return fmt.Errorf("not logging in: %s", c.Message)
+			}
+		case ActionDeny:
+			return fmt.Errorf("refusing to log in: %s; pass --%s to log in anyway, or change %s.%s", c.Message, ForceFlag, GuardrailsConfigKey, c.Name)
+		}
+	}
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,112 @@
+package clusterstatus
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Guardrails", func() {
+	var (
+		status  *Status
+		prompts []string
+		answer  bool
+		// confirm records the prompts, and answers with answer
+		confirm = func(prompt string) (bool, error) {
+			prompts = append(prompts, prompt)
+			return answer, nil
+		}
+		noTerminal = func(string) (bool, error) {
+			return false, errors.New("input is not a terminal")
+		}
+	)
+
+	BeforeEach(func() {
+		status = &Status{Cluster: buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateReady))}
+		prompts = nil
+		answer = false
+	})
+
+	AfterEach(func() {
+		viper.Reset()
+	})
+
+	Describe("ReadGuardrails", func() {
+		It("defaults to refusing dead clusters and confirming restricted ones", func() {
+			g, err := ReadGuardrails()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(g).To(Equal(DefaultGuardrails()))
+			Expect(g.Uninstalling).To(Equal(ActionDeny))
+			Expect(g.LimitedSupport).To(Equal(ActionConfirm))
+		})
+
+		It("keeps the defaults of unset conditions", func() {
+			viper.Set(GuardrailsConfigKey, map[string]any{"hibernating": "warn"})
+			g, err := ReadGuardrails()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(g.Hibernating).To(Equal(ActionWarn))
+			Expect(g.Uninstalling).To(Equal(ActionDeny))
+		})
+
+		It("rejects unknown actions", func() {
+			viper.Set(GuardrailsConfigKey, map[string]any{"error": "maybe"})
+			_, err := ReadGuardrails()
+			Expect(err).To(MatchError(ContainSubstring(`invalid clusterGuardrails.error "maybe"`)))
+		})
+	})
+
+	Describe("Enforce", func() {
+		It("logs into a ready cluster", func() {
+			Expect(DefaultGuardrails().Enforce(status, false, confirm)).To(Succeed())
+			Expect(prompts).To(BeEmpty())
+		})
+
+		It("refuses a denied condition", func() {
+			status.Cluster = buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateUninstalling))
+			err := DefaultGuardrails().Enforce(status, false, confirm)
+			Expect(err).To(MatchError(ContainSubstring("refusing to log in: cluster my-cluster is uninstalling")))
+			Expect(err).To(MatchError(ContainSubstring("--force")))
+			Expect(prompts).To(BeEmpty())
+		})
+
+		It("asks to confirm a condition needing confirmation", func() {
+			status.AccessProtection = true
+			answer = true
+			Expect(DefaultGuardrails().Enforce(status, false, confirm)).To(Succeed())
+			Expect(prompts).To(HaveExactElements(ContainSubstring("Cluster my-cluster has access protection enabled")))
+		})
+
+		It("refuses when confirmation is declined", func() {
+			status.AccessProtection = true
+			err := DefaultGuardrails().Enforce(status, false, confirm)
+			Expect(err).To(MatchError(ContainSubstring("not logging in")))
+		})
+
+		It("refuses when confirmation cannot be asked for", func() {
+			status.AccessProtection = true
+			err := DefaultGuardrails().Enforce(status, false, noTerminal)
+			Expect(err).To(MatchError(ContainSubstring("input is not a terminal")))
+		})
+
+		It("logs in regardless with force", func() {
+			status.Cluster = buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateHibernating))
+			status.AccessProtection = true
+			Expect(DefaultGuardrails().Enforce(status, true, noTerminal)).To(Succeed())
+		})
+
+		It("allows conditions configured to be allowed", func() {
+			status.Cluster = buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateError))
+			g := DefaultGuardrails()
+			g.Error = ActionAllow
+			Expect(g.Enforce(status, false, noTerminal)).To(Succeed())
+		})
+
+		It("only warns about conditions configured to warn", func() {
+			status.Cluster = buildCluster(cmv1.NewCluster().State(cmv1.ClusterStateInstalling))
+			Expect(DefaultGuardrails().Enforce(status, false, noTerminal)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// capabilitiesTTL is how long probed capabilities are cached for
+	capabilitiesTTL = 24 * time.Hour
+
+	capabilitiesCacheFile = ".config/ocm-container/engine-capabilities.json"
+
+	// selinuxRelabel is the mount option relabelling a mount's content
+	// so it can be shared between containers
+	selinuxRelabel = "z"
+)
+
+// resourceLimitArgs are engine args which need cgroups to apply
+var resourceLimitArgs = []string{"--memory", "--cpus", "--cpu-shares", "--pids-limit", "--blkio-weight"}
+
+// capabilitiesCachePath returns the file probed capabilities are cached
+// in. It is a var so tests do not write to $HOME.
+var capabilitiesCachePath = func() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, capabilitiesCacheFile), nil
+}
+
+// Capabilities describes how the container engine runs on this host,
+// as reported by `podman info` or `docker info`
+type Capabilities struct {
+	Engine  string `json:"engine"`
+	Version string `json:"version"`
+	// Rootless is true if the engine runs containers as this user
+	Rootless bool `json:"rootless"`
+	// CgroupVersion is 1 or 2, or 0 if unknown
+	CgroupVersion int `json:"cgroup_version"`
+	// SELinux is true if the engine applies SELinux labels, so mounts
+	// need relabelling to be readable in containers
+	SELinux bool `json:"selinux"`
+	// UserNamespaces is true if container root is mapped to an
+	// unprivileged host user, ie: rootless, or docker's userns-remap
+	UserNamespaces bool `json:"user_namespaces"`
+}
+
+// cachedCapabilities are the capabilities of an engine on a host
+type cachedCapabilities struct {
+	Capabilities
+	ProbedAt time.Time `json:"probed_at"`
+}
+
+// podmanInfo is the subset of `podman info` used
+type podmanInfo struct {
+	Host struct {
+		CgroupVersion string `json:"cgroupVersion"`
+		Security      struct {
+			Rootless       bool `json:"rootless"`
+			SELinuxEnabled bool `json:"selinuxEnabled"`
+		} `json:"security"`
+	} `json:"host"`
+	Version struct {
+		Version string `json:"Version"`
+	} `json:"version"`
+}
+
+// dockerInfo is the subset of `docker info` used
+type dockerInfo struct {
+	ServerVersion   string   `json:"ServerVersion"`
+	CgroupVersion   string   `json:"CgroupVersion"`
+	SecurityOptions []string `json:"SecurityOptions"`
+}
+
+func parsePodmanInfo(data []byte) (Capabilities, error) {
+	info := podmanInfo{}
+	err := json.Unmarshal(data, &info)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("error parsing podman info: %v", err)
+	}
+	return Capabilities{
+		Engine:         "podman",
+		Version:        info.Version.Version,
+		Rootless:       info.Host.Security.Rootless,
+		CgroupVersion:  parseCgroupVersion(info.Host.CgroupVersion),
+		SELinux:        info.Host.Security.SELinuxEnabled,
+		UserNamespaces: info.Host.Security.Rootless,
+	}, nil
+}
+
+func parseDockerInfo(data []byte) (Capabilities, error) {
+	info := dockerInfo{}
+	err := json.Unmarshal(data, &info)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("error parsing docker info: %v", err)
+	}
+
+	// Security options are reported as name=<option>[,key=value...]
+	options := []string{}
+	for _, o := range info.SecurityOptions {
+		name, _, _ := strings.Cut(o, ",")
+		options = append(options, strings.TrimPrefix(name, "name="))
+	}
+	rootless := slices.Contains(options, "rootless")
+	return Capabilities{
+		Engine:         "docker",
+		Version:        info.ServerVersion,
+		Rootless:       rootless,
+		CgroupVersion:  parseCgroupVersion(info.CgroupVersion),
+		SELinux:        slices.Contains(options, "selinux"),
+		UserNamespaces: rootless || slices.Contains(options, "userns"),
+	}, nil
+}
+
+// parseCgroupVersion parses "v2" (podman) or "2" (docker)
+func parseCgroupVersion(s string) int {
+	v, err := strconv.Atoi(strings.TrimPrefix(s, "v"))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// Capabilities returns the engine's capabilities on this host, probing
+// the engine if they are not cached. The bool is true if they were
+// probed rather than read from the cache.
+func (e *Engine) Capabilities() (Capabilities, bool, error) {
+	if e.dryRun {
+		return Capabilities{Engine: e.engine}, false, nil
+	}
+
+	key := e.engine
+	if host, err := os.Hostname(); err == nil {
+		key = e.engine + "@" + host
+	}
+
+	cachePath, err := capabilitiesCachePath()
+	if err != nil {
+		log.Debugf("unable to find the engine capabilities cache: %v", err)
+	}
+	cache := readCapabilitiesCache(cachePath)
+	if cached, ok := cache[key]; ok && time.Since(cached.ProbedAt) < capabilitiesTTL {
+		return cached.Capabilities, false, nil
+	}
+
+	caps, err := e.probeCapabilities()
+	if err != nil {
+		return caps, true, err
+	}
+
+	if cachePath != "" {
+		cache[key] = cachedCapabilities{Capabilities: caps, ProbedAt: time.Now().UTC()}
+		err = writeCapabilitiesCache(cachePath, cache)
+		if err != nil {
+			log.Debugf("unable to cache engine capabilities: %v", err)
+		}
+	}
+	return caps, true, nil
+}
+
+// probeCapabilities runs `info` against the engine
+func (e *Engine) probeCapabilities() (Capabilities, error) {
+	out, err := e.exec("info", "--format", "{{json .}}")
+	if err != nil {
+		return Capabilities{Engine: e.engine}, fmt.Errorf("error running %s info: %v", e.engine, err)
+	}
+	return e.dialect.parseInfo([]byte(out))
+}
+
+func readCapabilitiesCache(path string) map[string]cachedCapabilities {
+	cache := map[string]cachedCapabilities{}
+	if path == "" {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	err = json.Unmarshal(data, &cache)
+	if err != nil {
+		log.Debugf("ignoring invalid engine capabilities cache %s: %v", path, err)
+		return map[string]cachedCapabilities{}
+	}
+	return cache
+}
+
+// writeCapabilitiesCache writes the cache atomically, since several
+// sessions may start at once
+func writeCapabilitiesCache(path string, cache map[string]cachedCapabilities) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), os.FileMode(0o755))
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RelabelMounts returns the mounts with the SELinux relabel option
+// added, if the engine applies SELinux labels. Only mounts inside the
+// home directory are relabelled, since relabelling host system files,
+// eg: /etc/pki, would stop host services reading them. Mounts which
+// are already labelled are left unchanged.
+func (c Capabilities) RelabelMounts(mounts []VolumeMount) []VolumeMount {
+	if !c.SELinux {
+		return mounts
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Debugf("not relabelling mounts: %v", err)
+		return mounts
+	}
+	home = filepath.Clean(home) + string(filepath.Separator)
+
+	relabelled := make([]VolumeMount, 0, len(mounts))
+	for _, m := range mounts {
+		options := strings.Split(m.MountOptions, ",")
+		switch {
+		case slices.Contains(options, "z"), slices.Contains(options, "Z"):
+		case !strings.HasPrefix(filepath.Clean(m.Source), home):
+			log.Debugf("not relabelling mount of %s outside the home directory", m.Source)
+		case m.MountOptions == "":
+			m.MountOptions = selinuxRelabel
+		default:
+			m.MountOptions += "," + selinuxRelabel
+		}
+		relabelled = append(relabelled, m)
+	}
+	return relabelled
+}
+
+// Warnings returns problems running a container on this host
+func (c Capabilities) Warnings(ref ContainerRef) []string {
+	warnings := []string{}
+	if ref.Privileged && c.Rootless {
+		warnings = append(warnings, fmt.Sprintf(
+			"%s is running rootless, so the --privileged container only has the privileges of your user; tools needing host root access, eg: to manage host networking or devices, will not work",
+			c.Engine,
+		))
+	}
+	if ref.Privileged && !c.UserNamespaces && c.Engine == "docker" {
+		warnings = append(warnings,
+			"docker is running as root without user namespace remapping, so the --privileged container has full root access to this host; consider rootless docker or podman",
+		)
+	}
+	return warnings
+}
+
+// Compatible returns the container with the args the engine can't apply
+// on this host removed, and a message for each arg removed. Rootless
+// engines can't apply resource limits with cgroup v1, and fail to
+// create the container if asked to.
+func (c Capabilities) Compatible(ref ContainerRef) (ContainerRef, []string) {
+	if !c.Rootless || c.CgroupVersion != 1 {
+		return ref, nil
+	}
+
+	messages := []string{}
+	args := []string{}
+	for i := 0; i < len(ref.BestEffortArgs); i++ {
+		arg := ref.BestEffortArgs[i]
+		name, _, hasValue := strings.Cut(arg, "=")
+		if !slices.Contains(resourceLimitArgs, name) {
+			args = append(args, arg)
+			continue
+		}
+		// The limit's value is the next arg, eg: --memory 2g
+		if !hasValue && i+1 < len(ref.BestEffortArgs) {
+			i++
+		}
+		messages = append(messages, fmt.Sprintf(
+			"rootless %s can't apply resource limits with cgroup v1, so %s in launch-opts is ignored; use cgroup v2 to apply it",
+			c.Engine, name,
+		))
+	}
+	ref.BestEffortArgs = args
+	return ref, messages
+}
@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapabilities(t *testing.T) {
+	testCases := []struct {
+		engine    string
+		recording string
+		expected  Capabilities
+	}{
+		{"podman", "info-rootless", Capabilities{Engine: "podman", Version: "5.2.2", Rootless: true, CgroupVersion: 2, SELinux: true, UserNamespaces: true}},
+		{"podman", "info-rootful", Capabilities{Engine: "podman", Version: "5.2.2", CgroupVersion: 1}},
+		{"docker", "info-rootless", Capabilities{Engine: "docker", Version: "27.3.1", Rootless: true, CgroupVersion: 2, UserNamespaces: true}},
+		{"docker", "info-rootful", Capabilities{Engine: "docker", Version: "27.3.1", CgroupVersion: 1, SELinux: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.engine+"/"+tc.recording, func(t *testing.T) {
+			e, verify := newFakeEngine(t, tc.engine, "", tc.recording)
+
+			caps, probed, err := e.Capabilities()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !probed {
+				t.Errorf("Expected the engine to be probed")
+			}
+			if caps != tc.expected {
+				t.Errorf("Expected '%+v', but got '%+v'", tc.expected, caps)
+			}
+
+			// The second lookup is cached, so does not run the engine again
+			caps, probed, err = e.Capabilities()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if probed {
+				t.Errorf("Expected the capabilities to be cached")
+			}
+			if caps != tc.expected {
+				t.Errorf("Expected '%+v', but got '%+v'", tc.expected, caps)
+			}
+			verify()
+		})
+	}
+}
+
+func TestCapabilitiesCacheExpires(t *testing.T) {
+	e, verify := newFakeEngine(t, "podman", "", "info-rootless")
+
+	path, err := capabilitiesCachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	err = writeCapabilitiesCache(path, map[string]cachedCapabilities{
+		"podman@" + host: {Capabilities: Capabilities{Engine: "podman", Version: "4.0.0"}, ProbedAt: time.Now().Add(-2 * capabilitiesTTL)},
+		"docker@" + host: {Capabilities: Capabilities{Engine: "docker", Version: "27.3.1"}, ProbedAt: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caps, probed, err := e.Capabilities()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !probed || caps.Version != "5.2.2" {
+		t.Errorf("Expected the expired capabilities to be probed again, but got '%+v'", caps)
+	}
+	verify()
+
+	// Other engines' capabilities are kept
+	cache := readCapabilitiesCache(path)
+	if cache["docker@"+host].Version != "27.3.1" {
+		t.Errorf("Expected the docker capabilities to be kept, but got '%+v'", cache)
+	}
+}
+
+func TestRelabelMounts(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := []VolumeMount{
+		{Source: filepath.Join(home, ".config/ocm-container/histories"), Destination: "/root/.histories"},
+		{Source: filepath.Join(home, ".aws"), Destination: "/root/.aws", MountOptions: "ro"},
+		{Source: filepath.Join(home, ".ssh"), Destination: "/root/.ssh", MountOptions: "ro,Z"},
+		{Source: "/etc/pki/ca-trust", Destination: "/etc/pki/ca-trust", MountOptions: "ro"},
+		{Source: home, Destination: "/root/host-home"},
+	}
+
+	testCases := []struct {
+		name     string
+		caps     Capabilities
+		expected []string
+	}{
+		{"SELinux disabled", Capabilities{}, []string{"", "ro", "ro,Z", "ro", ""}},
+		{"SELinux enabled", Capabilities{SELinux: true}, []string{"z", "ro,z", "ro,Z", "ro", ""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := []string{}
+			for _, m := range tc.caps.RelabelMounts(mounts) {
+				result = append(result, m.MountOptions)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', but got '%v'", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesWarnings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		caps     Capabilities
+		ref      ContainerRef
+		expected []string
+	}{
+		{"Rootful podman", Capabilities{Engine: "podman", CgroupVersion: 2}, ContainerRef{Privileged: true}, nil},
+		{"Rootless podman privileged", Capabilities{Engine: "podman", Rootless: true, UserNamespaces: true, CgroupVersion: 2}, ContainerRef{Privileged: true}, []string{"running rootless"}},
+		{"Rootless podman unprivileged", Capabilities{Engine: "podman", Rootless: true, UserNamespaces: true, CgroupVersion: 2}, ContainerRef{}, nil},
+		{"Rootful docker privileged", Capabilities{Engine: "docker", CgroupVersion: 2}, ContainerRef{Privileged: true}, []string{"full root access"}},
+		{"Docker with userns-remap", Capabilities{Engine: "docker", UserNamespaces: true, CgroupVersion: 2}, ContainerRef{Privileged: true}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.caps.Warnings(tc.ref)
+			if len(result) != len(tc.expected) {
+				t.Fatalf("Expected %d warnings, but got '%v'", len(tc.expected), result)
+			}
+			for i, w := range tc.expected {
+				if !strings.Contains(result[i], w) {
+					t.Errorf("Expected a warning containing '%s', but got '%s'", w, result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilitiesCompatible(t *testing.T) {
+	rootlessV1 := Capabilities{Engine: "podman", Rootless: true, UserNamespaces: true, CgroupVersion: 1}
+	testCases := []struct {
+		name     string
+		caps     Capabilities
+		args     []string
+		expected []string
+		messages []string
+	}{
+		{
+			"Rootless cgroup v1 drops resource limits",
+			rootlessV1,
+			[]string{"--memory=2g", "--network", "host", "--cpus", "2", "--pids-limit=100"},
+			[]string{"--network", "host"},
+			[]string{"--memory in launch-opts", "--cpus in launch-opts", "--pids-limit in launch-opts"},
+		},
+		{
+			"Rootless cgroup v1 keeps other args",
+			rootlessV1,
+			[]string{"--network", "host"},
+			[]string{"--network", "host"},
+			nil,
+		},
+		{
+			"Rootless cgroup v2 keeps resource limits",
+			Capabilities{Engine: "podman", Rootless: true, UserNamespaces: true, CgroupVersion: 2},
+			[]string{"--memory=2g", "--cpus", "2"},
+			[]string{"--memory=2g", "--cpus", "2"},
+			nil,
+		},
+		{
+			"Rootful cgroup v1 keeps resource limits",
+			Capabilities{Engine: "docker", CgroupVersion: 1},
+			[]string{"--memory=2g"},
+			[]string{"--memory=2g"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, messages := tc.caps.Compatible(ContainerRef{Privileged: true, BestEffortArgs: tc.args})
+			args, err := parseRefToArgs(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := append([]string{"--privileged"}, tc.expected...)
+			if !reflect.DeepEqual(args, expected) {
+				t.Errorf("Expected args '%v', but got '%v'", expected, args)
+			}
+			if len(messages) != len(tc.messages) {
+				t.Fatalf("Expected %d messages, but got '%v'", len(tc.messages), messages)
+			}
+			for i, m := range tc.messages {
+				if !strings.Contains(messages[i], m) {
+					t.Errorf("Expected a message containing '%s', but got '%s'", m, messages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilitiesDryRun(t *testing.T) {
+	e := &Engine{engine: "podman", dryRun: true}
+	caps, probed, err := e.Capabilities()
+	if err != nil || probed || caps != (Capabilities{Engine: "podman"}) {
+		t.Errorf("Expected no probe in dry-run mode, but got '%+v', %v, %v", caps, probed, err)
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openshift/ocm-container/pkg/subprocess"
+)
+
+// dialect holds the parts of the podman and docker CLIs that differ,
+// so the rest of the engine can issue the same commands for both
+type dialect struct {
+	// imageExistsArgs returns the args of a command that succeeds only
+	// if the image is present locally
+	imageExistsArgs func(image string) []string
+	// imageMissing returns true if the error from the imageExistsArgs
+	// command means the image is not present, rather than a failure
+	imageMissing func(err error) bool
+
+	// pullPolicyArgs maps the pull policies the engine's pull command
+	// can apply itself to the args applying them. Policies missing from
+	// the map are emulated.
+	pullPolicyArgs map[string][]string
+
+	// parseInfo parses the output of `info --format {{json .}}`
+	parseInfo func(data []byte) (Capabilities, error)
+
+	// runningTemplate is the inspect template returning true if the
+	// container is running
+	runningTemplate string
+	// hostPortTemplate is the inspect template returning the host port
+	// a tcp container port is published on
+	hostPortTemplate string
+}
+
+var dialects = map[string]dialect{
+	"podman": {
+		imageExistsArgs: func(image string) []string {
+			return []string{"image", "exists", image}
+		},
+		// `podman image exists` exits 1, with no output, if the image
+		// is not present; other failures exit 125 with an error
+		imageMissing: func(err error) bool {
+			var ee *subprocess.ExecErr
+			return errors.As(err, &ee) && strings.TrimSpace(ee.ExecStdErr) == ""
+		},
+		pullPolicyArgs: map[string][]string{
+			"always":  {"--policy=always"},
+			"missing": {"--policy=missing"},
+			"newer":   {"--policy=newer"},
+		},
+		parseInfo:        parsePodmanInfo,
+		runningTemplate:  `{{.State.Running}}`,
+		hostPortTemplate: `{{(index (index .NetworkSettings.Ports "%d/tcp") 0).HostPort}}`,
+	},
+	"docker": {
+		// docker has no `image exists`; inspecting a missing image fails
+		// with "No such image"
+		imageExistsArgs: func(image string) []string {
+			return []string{"image", "inspect", "--format", "{{.Id}}", image}
+		},
+		imageMissing: func(err error) bool {
+			var ee *subprocess.ExecErr
+			return errors.As(err, &ee) && strings.Contains(strings.ToLower(ee.ExecStdErr), "no such image")
+		},
+		// `docker pull` always pulls, and has no "newer" policy
+		pullPolicyArgs: map[string][]string{
+			"always": {},
+		},
+		parseInfo:       parseDockerInfo,
+		runningTemplate: `{{.State.Running}}`,
+		// docker lists an IPv4 and an IPv6 binding for ports published on
+		// all interfaces, and no bindings for exposed but unpublished
+		// ports, so take the first binding if there is one
+		hostPortTemplate: `{{with index .NetworkSettings.Ports "%d/tcp"}}{{(index . 0).HostPort}}{{end}}`,
+	},
+}
+
+// dialectFor returns the dialect of a supported engine
+func dialectFor(engine string) (dialect, error) {
+	d, ok := dialects[engine]
+	if !ok {
+		return d, fmt.Errorf("error: engine %s not in supported engines: %v", engine, strings.Join(SupportedEngines, ", "))
+	}
+	return d, nil
+}
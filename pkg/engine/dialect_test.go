@@ -0,0 +1,323 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The engine matrix tests replay recorded podman and docker output from
+// testdata/<engine>/<recording>.json. The test binary stands in for the
+// engine: it is linked into $PATH as podman and docker, and replays the
+// recording named by fakeEngineRecordingEnv, in order, failing on any
+// command that was not recorded.
+const (
+	fakeEngineRecordingEnv = "OCMC_FAKE_ENGINE_RECORDING"
+	fakeEngineLogEnv       = "OCMC_FAKE_ENGINE_LOG"
+
+	testImage       = "quay.io/redhat-services-prod/openshift/ocm-container:latest"
+	testContainerID = "3f1c2ab45e6d7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708"
+)
+
+// recordedCall is a command run against an engine, and its output
+type recordedCall struct {
+	Args   []string `json:"args"`
+	Stdout string   `json:"stdout"`
+	Stderr string   `json:"stderr"`
+	Exit   int      `json:"exit"`
+}
+
+func TestMain(m *testing.M) {
+	if recording := os.Getenv(fakeEngineRecordingEnv); recording != "" {
+		os.Exit(fakeEngine(recording, os.Getenv(fakeEngineLogEnv), os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeEngine replays the next call in the recording, logging the args
+// it was run with
+func fakeEngine(recording, logPath string, args []string) int {
+	calls, err := readRecording(recording)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 125
+	}
+	logged, err := readCallLog(logPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 125
+	}
+
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 125
+	}
+	defer f.Close()
+	line, _ := json.Marshal(args)
+	fmt.Fprintln(f, string(line))
+
+	if len(logged) >= len(calls) {
+		fmt.Fprintf(os.Stderr, "unexpected command: %v\n", args)
+		return 125
+	}
+	call := calls[len(logged)]
+	if !reflect.DeepEqual(call.Args, args) {
+		fmt.Fprintf(os.Stderr, "unexpected command: %v, expected %v\n", args, call.Args)
+		return 125
+	}
+	fmt.Fprint(os.Stdout, call.Stdout)
+	fmt.Fprint(os.Stderr, call.Stderr)
+	return call.Exit
+}
+
+func readRecording(path string) ([]recordedCall, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	calls := []recordedCall{}
+	err = json.Unmarshal(data, &calls)
+	return calls, err
+}
+
+func readCallLog(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	logged := [][]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		args := []string{}
+		err = json.Unmarshal(scanner.Bytes(), &args)
+		if err != nil {
+			return nil, err
+		}
+		logged = append(logged, args)
+	}
+	return logged, scanner.Err()
+}
+
+// newFakeEngine returns an engine that replays a recording, and a
+// function checking every recorded call was made
+func newFakeEngine(t *testing.T, engine, pullPolicy, recording string) (*Engine, func()) {
+	t.Helper()
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Symlink(self, filepath.Join(dir, engine))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recordingPath, err := filepath.Abs(filepath.Join("testdata", engine, recording+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "calls.log")
+	cachePath := capabilitiesCachePath
+	capabilitiesCachePath = func() (string, error) {
+		return filepath.Join(dir, "engine-capabilities.json"), nil
+	}
+	t.Cleanup(func() { capabilitiesCachePath = cachePath })
+	t.Setenv("PATH", dir)
+	t.Setenv(fakeEngineRecordingEnv, recordingPath)
+	t.Setenv(fakeEngineLogEnv, logPath)
+
+	e, err := New(engine, pullPolicy, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e, func() {
+		t.Helper()
+		calls, err := readRecording(recordingPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logged, err := readCallLog(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logged) != len(calls) {
+			t.Errorf("Expected %d commands, but %d were run: %v", len(calls), len(logged), logged)
+		}
+	}
+}
+
+func TestEngineMatrix(t *testing.T) {
+	container := &Container{ID: testContainerID, Ref: ContainerRef{Image: testImage}}
+
+	imageExists := func(e *Engine) (string, error) {
+		exists, err := e.ImageExists(testImage)
+		return strconv.FormatBool(exists), err
+	}
+	create := func(e *Engine) (string, error) {
+		c, err := e.Create(ContainerRef{Image: testImage})
+		if err != nil {
+			return "", err
+		}
+		return c.ID, nil
+	}
+
+	testCases := []struct {
+		name       string
+		recording  string
+		pullPolicy string
+		run        func(e *Engine) (string, error)
+		expected   string
+		// expectErr is the expected error, for each engine
+		expectErr map[string]string
+	}{
+		{
+			name:      "Image present",
+			recording: "image-present",
+			run:       imageExists,
+			expected:  "true",
+		},
+		{
+			name:      "Image missing",
+			recording: "image-missing",
+			run:       imageExists,
+			expected:  "false",
+		},
+		{
+			name:      "Image existence check fails",
+			recording: "image-exists-error",
+			run:       imageExists,
+			expectErr: map[string]string{
+				"podman": "unable to connect to Podman socket",
+				"docker": "Cannot connect to the Docker daemon",
+			},
+		},
+		{
+			name:       "Create with the missing pull policy",
+			recording:  "create-missing",
+			pullPolicy: "missing",
+			run:        create,
+			expected:   testContainerID,
+		},
+		{
+			name:       "Create with the always pull policy pulls the image first",
+			recording:  "create-always",
+			pullPolicy: "always",
+			run:        create,
+			expected:   testContainerID,
+		},
+		{
+			name:       "Create with the missing pull policy pulls a missing image first",
+			recording:  "create-missing-pull",
+			pullPolicy: "missing",
+			run:        create,
+			expected:   testContainerID,
+		},
+		{
+			name:       "Create with the never pull policy does not check for the image",
+			recording:  "create-never",
+			pullPolicy: "never",
+			run:        create,
+			expected:   testContainerID,
+		},
+		{
+			name:       "Create with the newer pull policy pulls a newer image",
+			recording:  "create-newer",
+			pullPolicy: "newer",
+			run:        create,
+			expected:   testContainerID,
+		},
+		{
+			name:       "Create with the newer pull policy uses the local image when offline",
+			recording:  "create-newer-offline",
+			pullPolicy: "newer",
+			run:        create,
+			expected:   testContainerID,
+		},
+		{
+			name:       "Create with the newer pull policy fails when offline without a local image",
+			recording:  "create-newer-missing-offline",
+			pullPolicy: "newer",
+			run:        create,
+			expectErr: map[string]string{
+				"podman": "error pulling image",
+				"docker": "error pulling image",
+			},
+		},
+		{
+			name:      "Inspect the host port",
+			recording: "host-port",
+			run: func(e *Engine) (string, error) {
+				return e.Inspect(container, e.HostPortTemplate(9999))
+			},
+			expected: "'41000'\n",
+		},
+		{
+			name:      "Inspect the running state",
+			recording: "running",
+			run: func(e *Engine) (string, error) {
+				return e.Inspect(container, e.RunningTemplate())
+			},
+			expected: "'true'\n",
+		},
+	}
+
+	for _, engine := range SupportedEngines {
+		for _, tc := range testCases {
+			t.Run(engine+"/"+tc.name, func(t *testing.T) {
+				e, verify := newFakeEngine(t, engine, tc.pullPolicy, tc.recording)
+				result, err := tc.run(e)
+				verify()
+
+				if expectErr, ok := tc.expectErr[engine]; ok {
+					if err == nil || !strings.Contains(err.Error(), expectErr) {
+						t.Errorf("Expected error containing '%s', but got '%v'", expectErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if result != tc.expected {
+					t.Errorf("Expected '%s', but got '%s'", tc.expected, result)
+				}
+			})
+		}
+	}
+}
+
+func TestDialects(t *testing.T) {
+	for _, engine := range SupportedEngines {
+		t.Run(engine, func(t *testing.T) {
+			d, err := dialectFor(engine)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// Policies the engine can't apply fall back to always pulling
+			if _, ok := d.pullPolicyArgs["always"]; !ok {
+				t.Errorf("The always pull policy is not supported")
+			}
+			if _, ok := d.pullPolicyArgs["never"]; ok {
+				t.Errorf("The never pull policy should not pull")
+			}
+		})
+	}
+
+	_, err := dialectFor("lxc")
+	if err == nil {
+		t.Errorf("Expected an error for an unsupported engine")
+	}
+}
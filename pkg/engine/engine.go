@@ -1,18 +1,25 @@
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/openshift/ocm-container/pkg/subprocess"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
 )
 
+// DefaultBindAddress is the host address ports are published on
+// unless a binding says otherwise
+const DefaultBindAddress = "127.0.0.1"
+
 var (
 	SupportedEngines           = []string{"podman", "docker"}
 	SupportedPullImagePolicies = []string{"always", "missing", "never", "newer"}
@@ -45,6 +52,19 @@ type ContainerRef struct {
 	Privileged      bool
 	RemoveAfterExit bool
 	LocalPorts      map[string]int
+
+	// PortBindings optionally define how the named LocalPorts are
+	// published on the host. Ports without a binding are published
+	// on localhost with a host port chosen by the engine.
+	PortBindings map[string]PortBinding
+}
+
+// PortBinding describes the host side of a published container port
+type PortBinding struct {
+	// HostPort is the port on the host; 0 lets the engine choose
+	HostPort int
+	// BindAddress is the host address to bind to; defaults to localhost
+	BindAddress string
 }
 
 type VolumeMount struct {
@@ -93,6 +113,7 @@ type Engine struct {
 	binary     string
 	pullPolicy string
 	dryRun     bool
+	dialect    dialect
 }
 
 func New(engine, pullPolicy string, dryRun bool) (*Engine, error) {
@@ -108,6 +129,11 @@ func New(engine, pullPolicy string, dryRun bool) (*Engine, error) {
 		return nil, err
 	}
 
+	d, err := dialectFor(engine)
+	if err != nil {
+		return nil, err
+	}
+
 	bin, err := exec.LookPath(engine)
 	if err != nil {
 		err = fmt.Errorf("error: engine not found in $PATH: %v", err)
@@ -116,6 +142,7 @@ func New(engine, pullPolicy string, dryRun bool) (*Engine, error) {
 
 	e.engine = engine
 	e.binary = bin
+	e.dialect = d
 
 	return e, nil
 }
@@ -125,6 +152,12 @@ func (e *Engine) Attach(c *Container) error {
 	return e.execAndReplace([]string{"attach", c.ID}...)
 }
 
+// AttachAndWait attaches to a container with the given id as a child
+// process, returning once the container exits or is detached from
+func (e *Engine) AttachAndWait(c *Container) error {
+	return subprocess.RunAttached(exec.Command(e.binary, "attach", c.ID))
+}
+
 // Copy copies a source file to a destination (eg: podman cp)
 func (e *Engine) Copy(cpArgs ...string) (string, error) {
 	var args = []string{"cp"}
@@ -136,7 +169,16 @@ func (e *Engine) Copy(cpArgs ...string) (string, error) {
 // Exec creates a container with the given args, returning a *Container object
 func (e *Engine) Create(c ContainerRef) (*Container, error) {
 	var err error
-	var args = []string{"create", pullPolicyToString(e.pullPolicy)}
+
+	pullPolicy := e.pullPolicy
+	if c.Image != "" {
+		pullPolicy, err = e.preparePull(c.Image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var args = []string{"create", pullPolicyToString(pullPolicy)}
 
 	// --quiet suppresses image pull policy output which is written to /dev/null and
 	// misinterpreted by os.Exec as an error message
@@ -149,17 +191,6 @@ func (e *Engine) Create(c ContainerRef) (*Container, error) {
 		args = append(args, "--quiet")
 	}
 
-	// Check if image exists locally before creating the container
-	if c.Image != "" && e.pullPolicy != "never" {
-		imageExists, err := e.ImageExists(c.Image)
-		if err != nil {
-			log.Debugf("unable to check if image exists: %v", err)
-		}
-		if !imageExists {
-			log.Warnf("Image %s not present locally. Pulling image, this may take some time on first run...", c.Image)
-		}
-	}
-
 	err = validateContainerRef(c)
 	if err != nil {
 		return nil, err
@@ -208,15 +239,7 @@ func (e *Engine) Exec(c *Container, execArgs []string) (string, error) {
 
 func (e *Engine) ExecLive(c *Container, execArgs []string) error {
 	var err error
-	var args = []string{"exec", "--interactive", "--tty"}
-
-	// The container may be --privileged, but Exec doesn't use that flag by default
-	if c.Ref.Privileged {
-		args = append(args, "--privileged")
-	}
-
-	args = append(args, c.ID)
-	args = append(args, execArgs...)
+	var args = execLiveArgs(c, execArgs, stdinIsTerminal())
 
 	if !e.dryRun {
 		log.Debugf("executing command inside the running container: %v %v\n", e.binary, args)
@@ -228,10 +251,46 @@ func (e *Engine) ExecLive(c *Container, execArgs []string) error {
 	return err
 }
 
+// execLiveArgs returns the args of an exec command connected to this
+// process. A tty is only allocated when run from a terminal, so the
+// command's output is passed through unchanged when it is captured,
+// eg: by `ocm-container fanout`.
+func execLiveArgs(c *Container, execArgs []string, tty bool) []string {
+	var args = []string{"exec", "--interactive"}
+	if tty {
+		args = append(args, "--tty")
+	}
+
+	// The container may be --privileged, but Exec doesn't use that flag by default
+	if c.Ref.Privileged {
+		args = append(args, "--privileged")
+	}
+
+	args = append(args, c.ID)
+	return append(args, execArgs...)
+}
+
+// stdinIsTerminal returns true if this process's input is a terminal
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
 // Inspect takes a string value as a formatter for inspect output
 // (eg: podman inspect --format=)
 func (e *Engine) Inspect(c *Container, value string) (string, error) {
-	return e.exec([]string{"inspect", c.ID, fmt.Sprintf("--format='%s'", value)}...)
+	return e.exec([]string{"container", "inspect", c.ID, fmt.Sprintf("--format='%s'", value)}...)
+}
+
+// RunningTemplate returns the Inspect template reporting whether the
+// container is running
+func (e *Engine) RunningTemplate() string {
+	return e.dialect.runningTemplate
+}
+
+// HostPortTemplate returns the Inspect template reporting the host port
+// a tcp container port is published on
+func (e *Engine) HostPortTemplate(port int) string {
+	return fmt.Sprintf(e.dialect.hostPortTemplate, port)
 }
 
 func (e *Engine) Stop(c *Container, timeout int) error {
@@ -317,7 +376,7 @@ func parseRefToArgs(c ContainerRef) ([]string, error) {
 		args = append(args, "--publish-all")
 	} else if c.LocalPorts != nil {
 		for service := range c.LocalPorts {
-			args = append(args, fmt.Sprintf("--publish=127.0.0.1::%d", c.LocalPorts[service]))
+			args = append(args, portToPublishString(c.LocalPorts[service], c.PortBindings[service]))
 		}
 	}
 
@@ -419,6 +478,26 @@ func volumesToString(volumes []VolumeMount) []string {
 	return args
 }
 
+// portToPublishString returns the --publish flag for a container port and its
+// host binding, eg: --publish=127.0.0.1::9999 or --publish=0.0.0.0:8080:9999
+func portToPublishString(containerPort int, b PortBinding) string {
+	addr := b.BindAddress
+	if addr == "" {
+		addr = DefaultBindAddress
+	}
+	// IPv6 addresses must be bracketed to be separated from the ports
+	if strings.Contains(addr, ":") && !strings.HasPrefix(addr, "[") {
+		addr = "[" + addr + "]"
+	}
+
+	hostPort := ""
+	if b.HostPort != 0 {
+		hostPort = strconv.Itoa(b.HostPort)
+	}
+
+	return fmt.Sprintf("--publish=%s:%s:%d", addr, hostPort, containerPort)
+}
+
 // pullPolicyToString returns a string for the --pull flag as a valid container engine argument
 func pullPolicyToString(s string) string {
 	return fmt.Sprintf("--pull=%s", s)
@@ -426,12 +505,174 @@ func pullPolicyToString(s string) string {
 
 // ImageExists checks if an image exists locally
 func (e *Engine) ImageExists(imageName string) (bool, error) {
-	_, err := e.exec("image", "exists", imageName)
+	_, err := e.exec(e.dialect.imageExistsArgs(imageName)...)
 	if err != nil {
-		// image exists returns exit code 1 if image doesn't exist
-		// This is not an error, just means image is not present
-		return false, nil
+		if e.dialect.imageMissing(err) {
+			// This is not an error, just means image is not present
+			return false, nil
+		}
+		return false, err
 	}
 	// If no error, image exists
 	return true, nil
 }
+
+// preparePull pulls the image, if the pull policy requires it, before
+// the container is created, so the engine's progress can be shown. It
+// returns the --pull value for creating the container.
+func (e *Engine) preparePull(imageName string) (string, error) {
+	if e.pullPolicy == "never" {
+		return e.pullPolicy, nil
+	}
+
+	imageExists, err := e.ImageExists(imageName)
+	if err != nil {
+		log.Debugf("unable to check if image exists: %v", err)
+	}
+
+	switch {
+	case e.pullPolicy == "missing" && imageExists:
+		// Nothing to pull
+	case e.pullPolicy == "newer" && imageExists:
+		err = e.pullNewer(imageName)
+	default:
+		if !imageExists {
+			log.Warnf("Image %s not present locally. Pulling image, this may take some time on first run...", imageName)
+		}
+		err = e.Pull(imageName)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The image has been pulled if needed; let the engine pull it again
+	// only if it has since been removed
+	return "missing", nil
+}
+
+// Pull pulls an image with the engine's pull policy, showing the
+// engine's progress on stderr
+func (e *Engine) Pull(imageName string) error {
+	args, err := e.pullArgs(imageName)
+	if err != nil {
+		return err
+	}
+	err = subprocess.RunProgress(exec.Command(e.binary, args...))
+	if err != nil {
+		return fmt.Errorf("error pulling image %s: %v", imageName, err)
+	}
+	return nil
+}
+
+// PullInBackground starts pulling a newer version of an image, if there
+// is one, without waiting for it, so the pull can finish after
+// ocm-container exits and the image is up to date for the next session
+func (e *Engine) PullInBackground(imageName string) error {
+	args := []string{"pull", "--quiet"}
+	args = append(args, e.dialect.pullPolicyArgs["newer"]...)
+	args = append(args, imageName)
+	return subprocess.RunDetached(exec.Command(e.binary, args...))
+}
+
+// pullArgs returns the args pulling an image with the engine's pull
+// policy. Policies the engine can't apply pull unconditionally.
+func (e *Engine) pullArgs(imageName string) ([]string, error) {
+	if e.pullPolicy == "never" {
+		return nil, fmt.Errorf("error: the %s pull policy does not allow pulling images", e.pullPolicy)
+	}
+	args := []string{"pull"}
+	args = append(args, e.dialect.pullPolicyArgs[e.pullPolicy]...)
+	return append(args, imageName), nil
+}
+
+// pullNewer pulls a local image again if the registry has a different
+// digest, keeping the local image if the registry can't be reached,
+// like podman's "newer" pull policy
+func (e *Engine) pullNewer(imageName string) error {
+	if _, ok := e.dialect.pullPolicyArgs[e.pullPolicy]; ok {
+		err := e.Pull(imageName)
+		if err != nil {
+			log.Warnf("unable to check for a newer %s image; using the local image: %v", imageName, err)
+		}
+		return nil
+	}
+
+	before, err := e.ImageDigest(imageName)
+	if err != nil {
+		log.Debugf("unable to look up image digest: %v", err)
+	}
+
+	// The pull only downloads layers if the registry's digest differs
+	err = e.Pull(imageName)
+	if err != nil {
+		log.Warnf("unable to check for a newer %s image; using the local image: %v", imageName, err)
+		return nil
+	}
+
+	after, err := e.ImageDigest(imageName)
+	if err != nil {
+		log.Debugf("unable to look up image digest: %v", err)
+	}
+	if after != before {
+		log.Infof("pulled a newer %s image (%s)", imageName, after)
+	}
+	return nil
+}
+
+// ImageDigest returns the registry digest of a local image, or an empty
+// string if the image was not pulled from a registry
+func (e *Engine) ImageDigest(imageName string) (string, error) {
+	out, err := e.exec("image", "inspect", "--format", "{{json .RepoDigests}}", imageName)
+	if err != nil {
+		return "", err
+	}
+	return parseRepoDigests(out, imageName)
+}
+
+// ImageLabels returns the labels of a local image
+func (e *Engine) ImageLabels(imageName string) (map[string]string, error) {
+	out, err := e.exec("image", "inspect", "--format", "{{json .Config.Labels}}", imageName)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{}
+	out = strings.TrimSpace(out)
+	if out == "" || out == "null" {
+		return labels, nil
+	}
+	err = json.Unmarshal([]byte(out), &labels)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing image labels: %v", err)
+	}
+	return labels, nil
+}
+
+// parseRepoDigests returns the digest from the image's RepoDigests for
+// the image's repository, since an image may be in several repositories
+func parseRepoDigests(out, imageName string) (string, error) {
+	out = strings.TrimSpace(out)
+	if out == "" || out == "null" {
+		return "", nil
+	}
+	repoDigests := []string{}
+	err := json.Unmarshal([]byte(out), &repoDigests)
+	if err != nil {
+		return "", fmt.Errorf("error parsing image digests: %v", err)
+	}
+
+	name, _, _ := strings.Cut(imageName, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	for _, rd := range repoDigests {
+		repo, digest, ok := strings.Cut(rd, "@")
+		if ok && repo == name {
+			return digest, nil
+		}
+	}
+	if len(repoDigests) > 0 {
+		_, digest, _ := strings.Cut(repoDigests[0], "@")
+		return digest, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,272 @@
+package fanout
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// The fanout package runs a command against many clusters. Each cluster
+// gets its own ocm-container, run as a separate process with
+// --cluster-id, so it is logged into the cluster by the image's
+// cluster-command-entrypoint before the command runs. The output and
+// exit code of each are collected into a Result.
+
+const (
+	// OutputTable prints each cluster's output followed by a summary table
+	OutputTable = "table"
+	// OutputJSONL prints a JSON Result per line
+	OutputJSONL = "jsonl"
+	// OutputDir writes each cluster's output to files in a directory
+	OutputDir = "dir"
+
+	// DefaultConcurrency is the number of containers run at once
+	DefaultConcurrency = 5
+
+	// exitCodeNotRun is the exit code of a Result for a command which
+	// could not be run at all
+	exitCodeNotRun = -1
+
+	// maxErrorLength is the longest error shown in the summary table
+	maxErrorLength = 60
+)
+
+// Outputs are the supported output formats
+var Outputs = []string{OutputTable, OutputJSONL, OutputDir}
+
+// Target is a cluster to run the command against
+type Target struct {
+	ID   string
+	Name string
+}
+
+// Result is the outcome of running the command against a cluster
+type Result struct {
+	ClusterID string `json:"cluster_id"`
+	Name      string `json:"name"`
+	ExitCode  int    `json:"exit_code"`
+	// Duration is how long the container ran for, in seconds
+	Duration float64 `json:"duration_seconds"`
+	Stdout   string  `json:"stdout,omitempty"`
+	Stderr   string  `json:"stderr,omitempty"`
+	// Error is set if the command could not be run at all
+	Error string `json:"error,omitempty"`
+}
+
+// Succeeded returns true if the command exited successfully
+func (r Result) Succeeded() bool {
+	return r.ExitCode == 0 && r.Error == ""
+}
+
+// Runner runs the command against clusters, in at most Concurrency
+// containers at once
+type Runner struct {
+	// Binary is the ocm-container binary run for each cluster
+	Binary string
+	// Args are passed to each ocm-container, before --cluster-id
+	Args []string
+	// Command is run in each container
+	Command     []string
+	Concurrency int
+	// Progress is written a line as each cluster finishes
+	Progress io.Writer
+
+	run func(*exec.Cmd) error
+}
+
+// NewRunner returns a Runner running the current binary
+func NewRunner(args, command []string, concurrency int) (*Runner, error) {
+	if len(command) == 0 {
+		return nil, errors.New("no command to run; pass it after --, eg: ocm-container fanout --search ... -- oc get co")
+	}
+	if concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d: must be at least 1", concurrency)
+	}
+	binary, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{
+		Binary:      binary,
+		Args:        args,
+		Command:     command,
+		Concurrency: concurrency,
+		Progress:    os.Stderr,
+		run:         func(c *exec.Cmd) error { return c.Run() },
+	}, nil
+}
+
+// Run runs the command against every target, and returns their results
+// in the same order
+func (r *Runner) Run(targets []Target) []Result {
+	results := make([]Result, len(targets))
+	slots := make(chan struct{}, r.Concurrency)
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	done := 0
+
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			results[i] = r.runOne(t)
+			<-slots
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			fmt.Fprintf(r.Progress, "[%d/%d] %s: %s\n", done, len(targets), t.Name, status(results[i]))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runOne runs the command against a cluster in its own ocm-container
+func (r *Runner) runOne(t Target) Result {
+	args := append(slices.Clone(r.Args), "--cluster-id", t.ID, "--")
+	args = append(args, r.Command...)
+
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(r.Binary, args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	start := time.Now()
+	err := r.run(c)
+	result := Result{
+		ClusterID: t.ID,
+		Name:      t.Name,
+		Duration:  time.Since(start).Round(time.Millisecond).Seconds(),
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = exitCodeNotRun
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Failed returns the number of results which did not succeed
+func Failed(results []Result) int {
+	failed := 0
+	for _, r := range results {
+		if !r.Succeeded() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteTable writes each cluster's output, then a table of the results
+func WriteTable(w io.Writer, results []Result) error {
+	for _, r := range results {
+		if r.Stdout == "" {
+			continue
+		}
+		fmt.Fprintf(w, "==> %s (%s) <==\n%s", r.Name, r.ClusterID, r.Stdout)
+		if !strings.HasSuffix(r.Stdout, "\n") {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tID\tEXIT\tDURATION\tERROR")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1fs\t%s\n", r.Name, r.ClusterID, r.ExitCode, r.Duration, errorSummary(r))
+	}
+	return tw.Flush()
+}
+
+// WriteJSONL writes a JSON object per result
+func WriteJSONL(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		err := enc.Encode(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDir writes each cluster's stdout, stderr and exit code to files
+// in a directory named after its ID, and a results.jsonl file with the
+// results without their output
+func WriteDir(dir string, results []Result) error {
+	summary := []Result{}
+	for _, r := range results {
+		clusterDir := filepath.Join(dir, r.ClusterID)
+		err := os.MkdirAll(clusterDir, os.FileMode(0o755))
+		if err != nil {
+			return err
+		}
+		files := map[string]string{
+			"stdout":    r.Stdout,
+			"stderr":    r.Stderr,
+			"exit_code": fmt.Sprintf("%d\n", r.ExitCode),
+		}
+		for name, content := range files {
+			err = os.WriteFile(filepath.Join(clusterDir, name), []byte(content), os.FileMode(0o644))
+			if err != nil {
+				return err
+			}
+		}
+
+		r.Stdout = ""
+		r.Stderr = ""
+		summary = append(summary, r)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "results.jsonl"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteJSONL(f, summary)
+}
+
+func status(r Result) string {
+	if r.Error != "" {
+		return "error: " + r.Error
+	}
+	if r.ExitCode != 0 {
+		return fmt.Sprintf("failed with exit code %d", r.ExitCode)
+	}
+	return "ok"
+}
+
+// errorSummary returns why a command failed: the error running it, or
+// the last line it wrote to stderr
+func errorSummary(r Result) string {
+	if r.Succeeded() {
+		return ""
+	}
+	s := r.Error
+	if s == "" {
+		lines := strings.Split(strings.TrimSpace(r.Stderr), "\n")
+		s = strings.TrimSpace(lines[len(lines)-1])
+	}
+	if len(s) > maxErrorLength {
+		s = s[:maxErrorLength-3] + "..."
+	}
+	return s
+}
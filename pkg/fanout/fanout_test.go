@@ -0,0 +1,167 @@
+package fanout
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeSession stands in for ocm-container: run as
+// `sh -c fakeSession sh --cluster-id ID -- COMMAND...`, it fails on the
+// "bad" cluster and echoes the command on the others
+const fakeSession = `cluster=$2
+shift 3
+case $cluster in
+bad) echo "error: You must be logged in to the server" >&2; exit 3;;
+esac
+echo "$@ on $cluster"`
+
+var targets = []Target{
+	{ID: "id-a", Name: "a"},
+	{ID: "bad", Name: "broken"},
+	{ID: "id-c", Name: "c"},
+}
+
+func newTestRunner(concurrency int) *Runner {
+	return &Runner{
+		Binary:      "sh",
+		Args:        []string{"-c", fakeSession, "sh"},
+		Command:     []string{"oc", "get", "co"},
+		Concurrency: concurrency,
+		Progress:    &bytes.Buffer{},
+		run:         func(c *exec.Cmd) error { return c.Run() },
+	}
+}
+
+var _ = Describe("Pkg/Fanout", func() {
+	Context("Tests NewRunner()", func() {
+		It("Requires a command", func() {
+			_, err := NewRunner(nil, nil, 1)
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("Requires a positive concurrency", func() {
+			_, err := NewRunner(nil, []string{"oc"}, 0)
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	Context("Tests Run()", func() {
+		It("Collects the output and exit code of each cluster in order", func() {
+			results := newTestRunner(2).Run(targets)
+			Expect(results).To(HaveLen(3))
+
+			Expect(results[0].ClusterID).To(Equal("id-a"))
+			Expect(results[0].Name).To(Equal("a"))
+			Expect(results[0].Stdout).To(Equal("oc get co on id-a\n"))
+			Expect(results[0].Succeeded()).To(BeTrue())
+
+			Expect(results[1].ExitCode).To(Equal(3))
+			Expect(results[1].Stderr).To(ContainSubstring("You must be logged in"))
+			Expect(results[1].Succeeded()).To(BeFalse())
+
+			Expect(results[2].Stdout).To(Equal("oc get co on id-c\n"))
+			Expect(Failed(results)).To(Equal(1))
+		})
+
+		It("Records commands which could not be run", func() {
+			r := newTestRunner(1)
+			r.Binary = "/nonexistent/ocm-container"
+			results := r.Run(targets[:1])
+			Expect(results[0].ExitCode).To(Equal(exitCodeNotRun))
+			Expect(results[0].Error).ToNot(BeEmpty())
+			Expect(results[0].Succeeded()).To(BeFalse())
+		})
+
+		It("Runs at most Concurrency clusters at once", func() {
+			mu := sync.Mutex{}
+			running, maxRunning := 0, 0
+			r := newTestRunner(2)
+			r.run = func(c *exec.Cmd) error {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			}
+
+			many := []Target{}
+			for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+				many = append(many, Target{ID: id, Name: id})
+			}
+			results := r.Run(many)
+			Expect(results).To(HaveLen(6))
+			Expect(maxRunning).To(Equal(2))
+			Expect(r.Progress.(*bytes.Buffer).String()).To(ContainSubstring("[6/6]"))
+		})
+
+		It("Passes the args, cluster and command to each session", func() {
+			r := newTestRunner(1)
+			var args []string
+			r.run = func(c *exec.Cmd) error {
+				args = c.Args
+				return nil
+			}
+			r.Args = []string{"--config=/tmp/occ.yaml"}
+			r.Run(targets[:1])
+			Expect(args).To(Equal([]string{"sh", "--config=/tmp/occ.yaml", "--cluster-id", "id-a", "--", "oc", "get", "co"}))
+		})
+	})
+
+	Context("Tests the outputs", func() {
+		var results []Result
+
+		BeforeEach(func() {
+			results = newTestRunner(3).Run(targets)
+		})
+
+		It("Writes the output and a summary table", func() {
+			out := &bytes.Buffer{}
+			Expect(WriteTable(out, results)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("==> a (id-a) <==\noc get co on id-a\n"))
+			Expect(out.String()).ToNot(ContainSubstring("==> broken"))
+			Expect(out.String()).To(MatchRegexp(`CLUSTER\s+ID\s+EXIT\s+DURATION\s+ERROR`))
+			Expect(out.String()).To(MatchRegexp(`broken\s+bad\s+3\s+\S+s\s+error: You must be logged in to the server`))
+		})
+
+		It("Writes JSON lines", func() {
+			out := &bytes.Buffer{}
+			Expect(WriteJSONL(out, results)).To(Succeed())
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			Expect(lines).To(HaveLen(3))
+			r := Result{}
+			Expect(json.Unmarshal([]byte(lines[1]), &r)).To(Succeed())
+			Expect(r.ClusterID).To(Equal("bad"))
+			Expect(r.ExitCode).To(Equal(3))
+		})
+
+		It("Writes a directory of results", func() {
+			dir := GinkgoT().TempDir()
+			Expect(WriteDir(dir, results)).To(Succeed())
+
+			stdout, err := os.ReadFile(filepath.Join(dir, "id-a", "stdout"))
+			Expect(err).To(BeNil())
+			Expect(string(stdout)).To(Equal("oc get co on id-a\n"))
+			exitCode, err := os.ReadFile(filepath.Join(dir, "bad", "exit_code"))
+			Expect(err).To(BeNil())
+			Expect(string(exitCode)).To(Equal("3\n"))
+
+			summary, err := os.ReadFile(filepath.Join(dir, "results.jsonl"))
+			Expect(err).To(BeNil())
+			Expect(strings.Count(string(summary), "\n")).To(Equal(3))
+			Expect(string(summary)).ToNot(ContainSubstring("stdout"))
+		})
+	})
+})
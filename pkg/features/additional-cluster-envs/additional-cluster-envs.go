@@ -3,9 +3,13 @@ package additionalclusterenvs
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	sdk "github.com/openshift-online/ocm-sdk-go"
 	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/openshift/ocm-container/pkg/engine"
 	"github.com/openshift/ocm-container/pkg/features"
 	"github.com/openshift/ocm-container/pkg/ocm"
 	log "github.com/sirupsen/logrus"
@@ -16,11 +20,65 @@ import (
 const (
 	FeatureFlagName = "no-additional-cluster-envs"
 	FlagHelpMessage = "Disables additional cluster environment variables functionality"
+
+	configKey = "features.additional_cluster_envs"
+
+	// envPrefix is the prefix of the cluster metadata environment
+	// variables. The HyperShift namespaces predate it, and are not
+	// prefixed.
+	envPrefix = "CLUSTER_"
+)
+
+// The groups of environment variables which can be exported
+const (
+	GroupIdentity     = "identity"
+	GroupHive         = "hive"
+	GroupHyperShift   = "hypershift"
+	GroupCloud        = "cloud"
+	GroupVersion      = "version"
+	GroupProduct      = "product"
+	GroupSubscription = "subscription"
+	GroupNetwork      = "network"
+	GroupURLs         = "urls"
 )
 
+// Groups are the groups of environment variables, in the order they are
+// exported by default
+var Groups = []string{
+	GroupIdentity,
+	GroupHive,
+	GroupHyperShift,
+	GroupCloud,
+	GroupVersion,
+	GroupProduct,
+	GroupSubscription,
+	GroupNetwork,
+	GroupURLs,
+}
+
+// groupEnvs returns the environment variables of each group. Groups
+// needing more than the cluster look it up with the connection, and
+// skip values the user does not have permission to read.
+var groupEnvs = map[string]func(conn *sdk.Connection, cluster *cmv1.Cluster) envList{
+	GroupIdentity:     identityEnvs,
+	GroupHive:         hiveEnvs,
+	GroupHyperShift:   hyperShiftEnvs,
+	GroupCloud:        cloudEnvs,
+	GroupVersion:      versionEnvs,
+	GroupProduct:      productEnvs,
+	GroupSubscription: subscriptionEnvs,
+	GroupNetwork:      networkEnvs,
+	GroupURLs:         urlEnvs,
+}
+
 // Any internal config needed for the setup of the feature
 type config struct {
 	Enabled bool `mapstructure:"enabled"`
+	// Groups are the groups of environment variables to export
+	Groups []string `mapstructure:"groups"`
+	// Custom are environment variables whose values are Go templates
+	// evaluated against the cluster
+	Custom []customEnv `mapstructure:"custom"`
 }
 
 // This is where we want to set all of our config defaults. If
@@ -29,12 +87,24 @@ type config struct {
 func newConfigWithDefaults() *config {
 	config := config{}
 	config.Enabled = true
+	config.Groups = slices.Clone(Groups)
 	return &config
 }
 
 // Validate is where any custom configuration validation logic
 // lives. This is where you need to validate your user's input
 func (cfg *config) validate() error {
+	for _, g := range cfg.Groups {
+		if !slices.Contains(Groups, g) {
+			return fmt.Errorf("invalid group %q in %s.groups: must be one of %s", g, configKey, strings.Join(Groups, ", "))
+		}
+	}
+	for i := range cfg.Custom {
+		err := cfg.Custom[i].parse()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -80,13 +150,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.additional_cluster_envs") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.additional_cluster_envs", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -116,13 +186,68 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 		return opts, err
 	}
 
-	opts.AddEnvKeyVal("CLUSTER_ID", cluster.ID())
-	opts.AddEnvKeyVal("CLUSTER_UUID", cluster.ExternalID())
-	opts.AddEnvKeyVal("CLUSTER_NAME", cluster.Name())
-	opts.AddEnvKeyVal("CLUSTER_DOMAIN_PREFIX", cluster.DomainPrefix())
-	opts.AddEnvKeyVal("CLUSTER_INFRA_ID", cluster.InfraID())
+	exported := envList{}
+	for _, group := range f.config.Groups {
+		exported = append(exported, groupEnvs[group](ocmClient, cluster)...)
+	}
+	for _, env := range exported {
+		opts.AddEnvKeyVal(env.Key, env.Value)
+	}
 
-	shard, err := ocmClient.ClustersMgmt().V1().Clusters().
+	// Custom variables can use anything exported by the groups
+	data := newTemplateData(cluster, exported)
+	for _, c := range f.config.Custom {
+		value, err := c.render(data)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		opts.AddEnvKeyVal(c.Name, value)
+	}
+
+	return opts, nil
+}
+
+// If initialize fails, how should we handle the error? This
+// allows you to customize what log level to use or how to
+// clean up anything you need to.
+func (f *Feature) HandleError(err error) {
+	if f.userHasConfig {
+		log.Warnf("Error initializing additional-cluster-envs functionality: %v", err)
+	}
+	log.Debugf("Error initializing additional-cluster-envs functionality: %v", err)
+}
+
+// envList collects environment variables, skipping those without a
+// value
+type envList []engine.EnvVar
+
+func (l *envList) add(key, value string) {
+	if value != "" {
+		*l = append(*l, engine.EnvVar{Key: key, Value: value})
+	}
+}
+
+// addBool adds a boolean value if it is set on the cluster
+func (l *envList) addBool(key string, value, ok bool) {
+	if ok {
+		l.add(key, strconv.FormatBool(value))
+	}
+}
+
+func identityEnvs(_ *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	l.add(envPrefix+"ID", cluster.ID())
+	l.add(envPrefix+"UUID", cluster.ExternalID())
+	l.add(envPrefix+"NAME", cluster.Name())
+	l.add(envPrefix+"DOMAIN_PREFIX", cluster.DomainPrefix())
+	l.add(envPrefix+"INFRA_ID", cluster.InfraID())
+	return l
+}
+
+func hiveEnvs(conn *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	shard, err := conn.ClustersMgmt().V1().Clusters().
 		Cluster(cluster.ID()).
 		ProvisionShard().
 		Get().
@@ -130,39 +255,97 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 	if shard != nil && err == nil {
 		shard := shard.Body().HiveConfig().Server()
 		r, _ := regexp.Compile(`hive[\-a-z0-9]+`)
-		hive := r.FindString(shard)
-		if hive != "" {
-			opts.AddEnvKeyVal("CLUSTER_HIVE_NAME", hive)
-		}
+		l.add(envPrefix+"HIVE_NAME", r.FindString(shard))
 	}
+	return l
+}
 
-	// Parse Hypershift-related values
-	mgmtClusterName, svcClusterName, hcpNamespace := findHyperShiftInfo(ocmClient, cluster)
-	if mgmtClusterName != "" {
-		opts.AddEnvKeyVal("CLUSTER_MC_NAME", mgmtClusterName)
-	}
-	if svcClusterName != "" {
-		opts.AddEnvKeyVal("CLUSTER_SC_NAME", svcClusterName)
-	}
+func hyperShiftEnvs(conn *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	mgmtClusterName, svcClusterName, hcpNamespace := findHyperShiftInfo(conn, cluster)
+	l.add(envPrefix+"MC_NAME", mgmtClusterName)
+	l.add(envPrefix+"SC_NAME", svcClusterName)
 	if hcpNamespace != "" {
-		opts.AddEnvKeyVal("HCP_NAMESPACE", hcpNamespace)
+		l.add("HCP_NAMESPACE", hcpNamespace)
 		hcNamespaceRegex, _ := regexp.Compile(`ocm-[a-z0-9]+-[a-z0-9]+`)
-		hcNS := hcNamespaceRegex.FindString(hcpNamespace)
-		opts.AddEnvKeyVal("HC_NAMESPACE", hcNS)
-		opts.AddEnvKeyVal("KUBELET_NAMESPACE", fmt.Sprintf("kubelet-%s", cluster.ID()))
+		l.add("HC_NAMESPACE", hcNamespaceRegex.FindString(hcpNamespace))
+		l.add("KUBELET_NAMESPACE", fmt.Sprintf("kubelet-%s", cluster.ID()))
 	}
+	return l
+}
 
-	return opts, nil
+func cloudEnvs(_ *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	l.add(envPrefix+"CLOUD_PROVIDER", cluster.CloudProvider().ID())
+	l.add(envPrefix+"REGION", cluster.Region().ID())
+	multiAZ, ok := cluster.GetMultiAZ()
+	l.addBool(envPrefix+"MULTI_AZ", multiAZ, ok)
+	return l
 }
 
-// If initialize fails, how should we handle the error? This
-// allows you to customize what log level to use or how to
-// clean up anything you need to.
-func (f *Feature) HandleError(err error) {
-	if f.userHasConfig {
-		log.Warnf("Error initializing additional-cluster-envs functionality: %v", err)
+func versionEnvs(_ *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	version := cluster.OpenshiftVersion()
+	if version == "" {
+		version = cluster.Version().RawID()
 	}
-	log.Debugf("Error initializing additional-cluster-envs functionality: %v", err)
+	l.add(envPrefix+"VERSION", version)
+	l.add(envPrefix+"CHANNEL_GROUP", cluster.Version().ChannelGroup())
+	return l
+}
+
+func productEnvs(_ *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	l.add(envPrefix+"PRODUCT", cluster.Product().ID())
+	hcp, ok := cluster.Hypershift().GetEnabled()
+	l.addBool(envPrefix+"HYPERSHIFT", hcp, ok)
+	return l
+}
+
+// subscriptionEnvs looks up the organization through the subscription,
+// since the cluster only references its subscription
+func subscriptionEnvs(conn *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	subID := cluster.Subscription().ID()
+	l.add(envPrefix+"SUBSCRIPTION_ID", subID)
+	if subID == "" || conn == nil {
+		return l
+	}
+
+	sub, err := conn.AccountsMgmt().V1().Subscriptions().Subscription(subID).Get().Send()
+	if err != nil {
+		log.Debugf("unable to look up subscription %s: %v", subID, err)
+		return l
+	}
+	orgID := sub.Body().OrganizationID()
+	l.add(envPrefix+"ORG_ID", orgID)
+	if orgID == "" {
+		return l
+	}
+
+	org, err := conn.AccountsMgmt().V1().Organizations().Organization(orgID).Get().Send()
+	if err != nil {
+		log.Debugf("unable to look up organization %s: %v", orgID, err)
+		return l
+	}
+	l.add(envPrefix+"ORG_NAME", org.Body().Name())
+	return l
+}
+
+func networkEnvs(_ *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	privateLink, ok := cluster.AWS().GetPrivateLink()
+	l.addBool(envPrefix+"PRIVATE_LINK", privateLink, ok)
+	listening, ok := cluster.API().GetListening()
+	l.addBool(envPrefix+"PRIVATE_API", listening == cmv1.ListeningMethodInternal, ok)
+	return l
+}
+
+func urlEnvs(_ *sdk.Connection, cluster *cmv1.Cluster) envList {
+	l := envList{}
+	l.add(envPrefix+"API_URL", cluster.API().URL())
+	l.add(envPrefix+"CONSOLE_URL", cluster.Console().URL())
+	return l
 }
 
 // findHyperShiftMgmtSvcClusters returns the name of a HyperShift cluster's management and service clusters.
@@ -200,6 +383,14 @@ func findHyperShiftInfo(conn *sdk.Connection, cluster *cmv1.Cluster) (string, st
 	return mgmtClusterName, "", hcpNamespace
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{}
 	if err := features.Register("additional-cluster-envs", &f); err != nil {
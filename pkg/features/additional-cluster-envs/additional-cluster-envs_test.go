@@ -1,8 +1,13 @@
 package additionalclusterenvs
 
 import (
+	"net/http"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/openshift/ocm-container/pkg/ocm/ocmtest"
 	"github.com/spf13/viper"
 )
 
@@ -16,6 +21,7 @@ var _ = Describe("Pkg/Features/AdditionalClusterEnvs/AdditionalClusterEnvs", fun
 			cfg := newConfigWithDefaults()
 			Expect(cfg).ToNot(BeNil())
 			Expect(cfg.Enabled).To(BeTrue())
+			Expect(cfg.Groups).To(Equal(Groups))
 		})
 	})
 
@@ -28,6 +34,16 @@ var _ = Describe("Pkg/Features/AdditionalClusterEnvs/AdditionalClusterEnvs", fun
 			Expect(err).To(BeNil())
 		})
 
+		It("Returns an error for unknown groups", func() {
+			cfg := config{
+				Enabled: true,
+				Groups:  []string{GroupIdentity, "secrets"},
+			}
+			err := cfg.validate()
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("secrets"))
+		})
+
 		It("Returns nil for disabled config", func() {
 			cfg := config{
 				Enabled: false,
@@ -59,6 +75,17 @@ var _ = Describe("Pkg/Features/AdditionalClusterEnvs/AdditionalClusterEnvs", fun
 			Expect(f.config.Enabled).To(BeFalse())
 		})
 
+		It("Replaces the default groups with the configured groups", func() {
+			viper.Set("features.additional_cluster_envs", map[string]any{
+				"groups": []string{GroupIdentity, GroupURLs},
+			})
+			f := Feature{}
+			err := f.Configure()
+			Expect(err).To(BeNil())
+			Expect(f.config.Enabled).To(BeTrue())
+			Expect(f.config.Groups).To(Equal([]string{GroupIdentity, GroupURLs}))
+		})
+
 		It("Returns an error when viper cannot unmarshal the config", func() {
 			viper.Set("features.additional_cluster_envs", map[string]any{
 				"enabled": "someString",
@@ -121,11 +148,172 @@ var _ = Describe("Pkg/Features/AdditionalClusterEnvs/AdditionalClusterEnvs", fun
 	})
 
 	Context("Tests Feature.Initialize()", func() {
-		// Note: Initialize() requires OCM client which we can't easily mock in unit tests
-		// The Initialize() method is tested through integration tests
-		It("Would require OCM client mocking for proper unit testing", func() {
-			// This is a placeholder to acknowledge that Initialize() needs integration testing
-			// since it calls ocm.NewClient() and ocm.GetCluster()
+		var fixture ocmtest.Fixture
+
+		BeforeEach(func() {
+			var err error
+			fixture, err = ocmtest.LoadFixture("clusters")
+			Expect(err).To(BeNil())
+		})
+
+		// initialize logs into a fake OCM serving the fixture, and
+		// returns the environment variables exported for the cluster
+		initialize := func(clusterID string) (map[string]string, error) {
+			server := ocmtest.NewServer(fixture)
+			DeferCleanup(server.Close)
+			restore, err := server.Arm(GinkgoT().TempDir())
+			Expect(err).To(BeNil())
+			DeferCleanup(restore)
+			c, err := ocm.New()
+			Expect(err).To(BeNil())
+			DeferCleanup(c.Cleanup)
+			DeferCleanup(ocm.CloseClient)
+
+			viper.Set("cluster-id", clusterID)
+			f := Feature{}
+			Expect(f.Configure()).To(Succeed())
+			opts, err := f.Initialize()
+			envs := map[string]string{}
+			for _, e := range opts.Envs {
+				envs[e.Key] = e.Value
+			}
+			return envs, err
+		}
+
+		It("Exports a classic cluster's metadata", func() {
+			envs, err := initialize("classic-cluster")
+			Expect(err).To(BeNil())
+			Expect(envs).To(Equal(map[string]string{
+				"CLUSTER_ID":              "1classic",
+				"CLUSTER_UUID":            "a1b2c3d4-0000-0000-0000-classic00001",
+				"CLUSTER_NAME":            "classic-cluster",
+				"CLUSTER_HIVE_NAME":       "hive-stage-01",
+				"CLUSTER_CLOUD_PROVIDER":  "aws",
+				"CLUSTER_REGION":          "us-east-1",
+				"CLUSTER_PRODUCT":         "osd",
+				"CLUSTER_HYPERSHIFT":      "false",
+				"CLUSTER_SUBSCRIPTION_ID": "sub-classic",
+				"CLUSTER_ORG_ID":          "org-1",
+				"CLUSTER_ORG_NAME":        "Example Org",
+			}))
+		})
+
+		It("Exports a HyperShift cluster's management and service clusters", func() {
+			envs, err := initialize("a1b2c3d4-0000-0000-0000-hcp000000001")
+			Expect(err).To(BeNil())
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_ID", "2hcp"))
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_HYPERSHIFT", "true"))
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_MC_NAME", "hs-mc-abc123"))
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_SC_NAME", "hs-sc-def456"))
+			Expect(envs).To(HaveKeyWithValue("HCP_NAMESPACE", "ocm-production-2hcp-hcp-cluster"))
+			Expect(envs).To(HaveKeyWithValue("HC_NAMESPACE", "ocm-production-2hcp"))
+			Expect(envs).To(HaveKeyWithValue("KUBELET_NAMESPACE", "kubelet-2hcp"))
+			Expect(envs).ToNot(HaveKey("CLUSTER_HIVE_NAME"))
+		})
+
+		It("Skips the HyperShift variables without permission to read them", func() {
+			fixture.Errors["/api/clusters_mgmt/v1/clusters/2hcp/hypershift"] = ocmtest.Error{Status: http.StatusForbidden, Reason: "Forbidden"}
+			envs, err := initialize("hcp-cluster")
+			Expect(err).To(BeNil())
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_ID", "2hcp"))
+			Expect(envs).ToNot(HaveKey("CLUSTER_MC_NAME"))
+			Expect(envs).ToNot(HaveKey("HCP_NAMESPACE"))
+		})
+
+		It("Skips the service cluster without permission to read the fleet", func() {
+			fixture.Errors["/api/osd_fleet_mgmt/v1/management_clusters"] = ocmtest.Error{Status: http.StatusForbidden, Reason: "Forbidden"}
+			envs, err := initialize("hcp-cluster")
+			Expect(err).To(BeNil())
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_MC_NAME", "hs-mc-abc123"))
+			Expect(envs).ToNot(HaveKey("CLUSTER_SC_NAME"))
+		})
+
+		It("Skips the organization name without permission to read it", func() {
+			fixture.Errors["/api/accounts_mgmt/v1/organizations/org-1"] = ocmtest.Error{Status: http.StatusForbidden, Reason: "Forbidden"}
+			envs, err := initialize("classic-cluster")
+			Expect(err).To(BeNil())
+			Expect(envs).To(HaveKeyWithValue("CLUSTER_ORG_ID", "org-1"))
+			Expect(envs).ToNot(HaveKey("CLUSTER_ORG_NAME"))
+		})
+
+		It("Returns an error for an ambiguous cluster name", func() {
+			_, err := initialize("duplicate")
+			Expect(err).To(MatchError(ContainSubstring("there are 2 subscriptions")))
+		})
+	})
+
+	Context("Tests the group environment variables", func() {
+		cluster, err := cmv1.NewCluster().
+			ID("2abc").
+			ExternalID("c1f2e3d4-uuid").
+			Name("my-cluster").
+			CloudProvider(cmv1.NewCloudProvider().ID("aws")).
+			Region(cmv1.NewCloudRegion().ID("us-east-1")).
+			MultiAZ(true).
+			OpenshiftVersion("4.16.3").
+			Version(cmv1.NewVersion().RawID("4.16.3").ChannelGroup("stable")).
+			Product(cmv1.NewProduct().ID("rosa")).
+			Hypershift(cmv1.NewHypershift().Enabled(false)).
+			Subscription(cmv1.NewSubscription().ID("sub-1")).
+			AWS(cmv1.NewAWS().PrivateLink(true)).
+			API(cmv1.NewClusterAPI().URL("https://api.my-cluster.example.com:6443").Listening(cmv1.ListeningMethodInternal)).
+			Console(cmv1.NewClusterConsole().URL("https://console.my-cluster.example.com")).
+			Build()
+
+		It("Builds the test cluster", func() {
+			Expect(err).To(BeNil())
+		})
+
+		DescribeTable("Exports the cluster's metadata",
+			func(group string, expected map[string]string) {
+				envs := map[string]string{}
+				for _, e := range groupEnvs[group](nil, cluster) {
+					envs[e.Key] = e.Value
+				}
+				Expect(envs).To(Equal(expected))
+			},
+			Entry("identity", GroupIdentity, map[string]string{
+				"CLUSTER_ID":   "2abc",
+				"CLUSTER_UUID": "c1f2e3d4-uuid",
+				"CLUSTER_NAME": "my-cluster",
+			}),
+			Entry("cloud", GroupCloud, map[string]string{
+				"CLUSTER_CLOUD_PROVIDER": "aws",
+				"CLUSTER_REGION":         "us-east-1",
+				"CLUSTER_MULTI_AZ":       "true",
+			}),
+			Entry("version", GroupVersion, map[string]string{
+				"CLUSTER_VERSION":       "4.16.3",
+				"CLUSTER_CHANNEL_GROUP": "stable",
+			}),
+			Entry("product", GroupProduct, map[string]string{
+				"CLUSTER_PRODUCT":    "rosa",
+				"CLUSTER_HYPERSHIFT": "false",
+			}),
+			Entry("subscription without a connection", GroupSubscription, map[string]string{
+				"CLUSTER_SUBSCRIPTION_ID": "sub-1",
+			}),
+			Entry("network", GroupNetwork, map[string]string{
+				"CLUSTER_PRIVATE_LINK": "true",
+				"CLUSTER_PRIVATE_API":  "true",
+			}),
+			Entry("urls", GroupURLs, map[string]string{
+				"CLUSTER_API_URL":     "https://api.my-cluster.example.com:6443",
+				"CLUSTER_CONSOLE_URL": "https://console.my-cluster.example.com",
+			}),
+		)
+
+		It("Skips values missing from the cluster", func() {
+			empty, err := cmv1.NewCluster().ID("2abc").Build()
+			Expect(err).To(BeNil())
+			Expect(groupEnvs[GroupNetwork](nil, empty)).To(BeEmpty())
+			Expect(groupEnvs[GroupCloud](nil, empty)).To(BeEmpty())
+		})
+
+		It("Has environment variables for every group", func() {
+			for _, g := range Groups {
+				Expect(groupEnvs).To(HaveKey(g))
+			}
 		})
 	})
 
@@ -0,0 +1,119 @@
+package additionalclusterenvs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+)
+
+// envNamePattern matches valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateFuncs are the functions available to custom templates, in
+// addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    strings.ReplaceAll,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+}
+
+// customEnv is a user-defined environment variable, whose value is a
+// Go template evaluated against the cluster
+type customEnv struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+
+	tmpl *template.Template
+}
+
+// templateData is what custom templates are evaluated against. The
+// cluster's methods can be used directly, eg: {{.InfraID}}, and the
+// nested values most often needed are flattened into strings, eg:
+// {{.Region}} rather than {{.Cluster.Region.ID}}.
+type templateData struct {
+	*cmv1.Cluster
+
+	Region        string
+	CloudProvider string
+	Version       string
+	ChannelGroup  string
+	Product       string
+	APIURL        string
+	ConsoleURL    string
+
+	// The Hive and HyperShift values are only set when their groups
+	// are exported
+	HiveName          string
+	ManagementCluster string
+	ServiceCluster    string
+	HCPNamespace      string
+
+	// Env holds the environment variables exported by the groups, eg:
+	// {{.Env.CLUSTER_ORG_NAME}}
+	Env map[string]string
+}
+
+// newTemplateData returns the data for a cluster and the environment
+// variables exported for it
+func newTemplateData(cluster *cmv1.Cluster, envs envList) templateData {
+	env := map[string]string{}
+	for _, e := range envs {
+		env[e.Key] = e.Value
+	}
+	return templateData{
+		Cluster:           cluster,
+		Region:            cluster.Region().ID(),
+		CloudProvider:     cluster.CloudProvider().ID(),
+		Version:           env[envPrefix+"VERSION"],
+		ChannelGroup:      cluster.Version().ChannelGroup(),
+		Product:           cluster.Product().ID(),
+		APIURL:            cluster.API().URL(),
+		ConsoleURL:        cluster.Console().URL(),
+		HiveName:          env[envPrefix+"HIVE_NAME"],
+		ManagementCluster: env[envPrefix+"MC_NAME"],
+		ServiceCluster:    env[envPrefix+"SC_NAME"],
+		HCPNamespace:      env["HCP_NAMESPACE"],
+		Env:               env,
+	}
+}
+
+// parse checks the name and parses the template. The template is run
+// against an empty cluster, so references to fields which do not exist
+// are found when the config is read, rather than in the container.
+func (c *customEnv) parse() error {
+	if !envNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid custom environment variable name %q", c.Name)
+	}
+
+	tmpl, err := template.New(c.Name).Funcs(templateFuncs).Option("missingkey=zero").Parse(c.Value)
+	if err != nil {
+		return fmt.Errorf("invalid template for custom environment variable %s: %v", c.Name, err)
+	}
+
+	empty, err := cmv1.NewCluster().Build()
+	if err != nil {
+		return err
+	}
+	err = tmpl.Execute(&strings.Builder{}, newTemplateData(empty, envList{}))
+	if err != nil {
+		return fmt.Errorf("invalid template for custom environment variable %s: %v", c.Name, err)
+	}
+
+	c.tmpl = tmpl
+	return nil
+}
+
+// render evaluates the template against the data
+func (c *customEnv) render(data templateData) (string, error) {
+	out := strings.Builder{}
+	err := c.tmpl.Execute(&out, data)
+	if err != nil {
+		return "", fmt.Errorf("error evaluating custom environment variable %s: %v", c.Name, err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,108 @@
+package additionalclusterenvs
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/Features/AdditionalClusterEnvs/Custom", func() {
+	var cluster *cmv1.Cluster
+
+	BeforeEach(func() {
+		viper.Reset()
+
+		var err error
+		cluster, err = cmv1.NewCluster().
+			ID("2abc").
+			Name("my-cluster").
+			InfraID("my-cluster-x7k2p").
+			Region(cmv1.NewCloudRegion().ID("us-east-1")).
+			CloudProvider(cmv1.NewCloudProvider().ID("aws")).
+			Version(cmv1.NewVersion().ChannelGroup("stable")).
+			Build()
+		Expect(err).To(BeNil())
+	})
+
+	Context("Tests customEnv.parse()", func() {
+		DescribeTable("Accepts valid templates",
+			func(value string) {
+				c := customEnv{Name: "MY_VAR", Value: value}
+				Expect(c.parse()).To(Succeed())
+			},
+			Entry("plain text", "https://grafana.example.com"),
+			Entry("flattened values", "https://grafana.{{.Region}}.example/{{.InfraID}}"),
+			Entry("cluster methods", "{{.Cluster.Region.ID}}-{{.DomainPrefix}}"),
+			Entry("exported variables", "{{.Env.CLUSTER_ORG_NAME}}"),
+			Entry("functions", `{{.Name | upper | replace "-" "_"}}`),
+		)
+
+		DescribeTable("Rejects invalid config",
+			func(name, value, expected string) {
+				c := customEnv{Name: name, Value: value}
+				err := c.parse()
+				Expect(err).ToNot(BeNil())
+				Expect(err.Error()).To(ContainSubstring(expected))
+			},
+			Entry("invalid name", "MY-VAR", "x", "invalid custom environment variable name"),
+			Entry("empty name", "", "x", "invalid custom environment variable name"),
+			Entry("unclosed action", "MY_VAR", "{{.Region", "invalid template"),
+			Entry("unknown function", "MY_VAR", "{{shout .Region}}", "invalid template"),
+			Entry("unknown field", "MY_VAR", "{{.Datacenter}}", "invalid template"),
+		)
+	})
+
+	Context("Tests customEnv.render()", func() {
+		It("Evaluates the template against the cluster and exported variables", func() {
+			c := customEnv{
+				Name:  "MY_GRAFANA",
+				Value: "https://grafana.{{.Region}}.example/{{.InfraID}}?org={{.Env.CLUSTER_ORG_NAME}}&hive={{.HiveName}}&channel={{.ChannelGroup}}",
+			}
+			Expect(c.parse()).To(Succeed())
+
+			data := newTemplateData(cluster, envList{
+				{Key: "CLUSTER_ORG_NAME", Value: "example"},
+				{Key: "CLUSTER_HIVE_NAME", Value: "hive-stage-01"},
+			})
+			value, err := c.render(data)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("https://grafana.us-east-1.example/my-cluster-x7k2p?org=example&hive=hive-stage-01&channel=stable"))
+		})
+
+		It("Renders missing exported variables as empty", func() {
+			c := customEnv{Name: "MY_VAR", Value: "[{{.Env.CLUSTER_MC_NAME}}]"}
+			Expect(c.parse()).To(Succeed())
+			value, err := c.render(newTemplateData(cluster, envList{}))
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("[]"))
+		})
+	})
+
+	Context("Tests Feature.Configure() with custom variables", func() {
+		It("Parses the templates", func() {
+			viper.Set("features.additional_cluster_envs", map[string]any{
+				"custom": []map[string]any{
+					{"name": "MY_GRAFANA", "value": "https://grafana.{{.Region}}.example"},
+				},
+			})
+			f := Feature{}
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.config.Custom).To(HaveLen(1))
+			Expect(f.config.Custom[0].Name).To(Equal("MY_GRAFANA"))
+			Expect(f.config.Custom[0].tmpl).ToNot(BeNil())
+		})
+
+		It("Returns an error for invalid templates", func() {
+			viper.Set("features.additional_cluster_envs", map[string]any{
+				"custom": []map[string]any{
+					{"name": "MY_GRAFANA", "value": "{{.Regoin}}"},
+				},
+			})
+			f := Feature{}
+			err := f.Configure()
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("MY_GRAFANA"))
+		})
+	})
+})
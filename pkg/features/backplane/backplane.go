@@ -15,6 +15,8 @@ const (
 	FeatureFlagName = "no-backplane"
 	FlagHelpMessage = "Disable backplane configuration mounting"
 
+	configKey = "features.backplane"
+
 	backplaneConfigDest      = "/root/.config/backplane/config.json"
 	backplaneConfigMountOpts = "rw"
 	defaultBackplaneConfig   = ".config/backplane/config.json"
@@ -62,13 +64,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.backplane") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.backplane", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -155,6 +157,14 @@ func (f *Feature) HandleError(err error) {
 	log.Debugf("Error initializing backplane functionality: %v", err)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
@@ -0,0 +1,319 @@
+package browserbridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/features"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The browser bridge runs a small HTTP listener on the host for the
+// length of the session, on a unix socket mounted into the container.
+// An `xdg-open` shim inside the container forwards URLs to it, and
+// allowed URLs are opened in the host's default browser.
+
+const (
+	FeatureFlagName = "no-browser-bridge"
+	FlagHelpMessage = "Disable opening URLs from the container in the host browser"
+
+	configKey = "features.browser_bridge"
+
+	// destDir is where the socket and shim are mounted in the container
+	destDir    = "/run/ocm-container/browser"
+	socketName = "browser.sock"
+	shimName   = "xdg-open"
+
+	// shimLink puts the shim on the PATH for tools that call
+	// xdg-open directly rather than using $BROWSER
+	shimLink = "/usr/local/bin/xdg-open"
+
+	openPath = "/open"
+)
+
+var (
+	defaultAllowedSchemes = []string{"http", "https"}
+	defaultAllowedHosts   = []string{
+		"localhost",
+		"127.0.0.1",
+		"*.openshift.com",
+		"*.redhat.com",
+	}
+
+	// openBrowser opens a URL on the host. It is a var so tests
+	// do not launch a browser.
+	openBrowser = hostOpen
+)
+
+const shim = `#!/bin/sh
+# Opens URLs in the host browser via the ocm-container browser bridge
+if [ -z "$1" ]; then
+  echo "usage: $(basename "$0") URL" >&2
+  exit 1
+fi
+exec curl --silent --show-error --fail-with-body \
+  --unix-socket ` + destDir + `/` + socketName + ` \
+  --data-urlencode "url=$1" \
+  http://localhost` + openPath + `
+`
+
+type config struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// AllowedSchemes are the URL schemes that may be opened on the host
+	AllowedSchemes []string `mapstructure:"allowed_schemes"`
+	// AllowedHosts are the URL hosts that may be opened on the host.
+	// Entries may be exact hosts, "*.example.com" to match any
+	// subdomain, or "*" to match any host.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+}
+
+func newConfigWithDefaults() *config {
+	cfg := config{}
+	cfg.Enabled = false
+	cfg.AllowedSchemes = defaultAllowedSchemes
+	cfg.AllowedHosts = defaultAllowedHosts
+	return &cfg
+}
+
+func (cfg *config) validate() error {
+	if len(cfg.AllowedSchemes) == 0 {
+		return fmt.Errorf("allowed_schemes must not be empty")
+	}
+	for _, s := range cfg.AllowedSchemes {
+		if s == "" || strings.Contains(s, ":") {
+			return fmt.Errorf("invalid scheme %q in allowed_schemes", s)
+		}
+	}
+	for _, h := range cfg.AllowedHosts {
+		if h == "" || strings.ContainsAny(h, "/:") {
+			return fmt.Errorf("invalid host %q in allowed_hosts", h)
+		}
+	}
+	return nil
+}
+
+// allowed returns an error if the URL may not be opened on the host
+func (cfg *config) allowed(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+
+	if !slices.ContainsFunc(cfg.AllowedSchemes, func(s string) bool { return strings.EqualFold(s, u.Scheme) }) {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+	for _, h := range cfg.AllowedHosts {
+		if hostMatches(strings.ToLower(h), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed", host)
+}
+
+func hostMatches(pattern, host string) bool {
+	if pattern == "*" || pattern == host {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix)
+	}
+	return false
+}
+
+type Feature struct {
+	config *config
+
+	userHasConfig bool
+}
+
+func (f *Feature) Enabled() bool {
+	if !f.config.Enabled {
+		log.Debugf("browser-bridge disabled via config")
+		return false
+	}
+	if viper.IsSet(FeatureFlagName) {
+		log.Debugf("browser-bridge disabled via flag")
+		return false
+	}
+	return true
+}
+
+func (f *Feature) ExitOnError() bool {
+	return false
+}
+
+func (f *Feature) Configure() error {
+	cfg := newConfigWithDefaults()
+
+	if !viper.IsSet(configKey) {
+		f.config = cfg
+		return nil
+	}
+
+	f.userHasConfig = true
+	err := viper.UnmarshalKey(configKey, &cfg)
+	if err != nil {
+		return err
+	}
+
+	f.config = cfg
+	err = cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *Feature) Initialize() (features.OptionSet, error) {
+	opts := features.NewOptionSet()
+
+	dir, err := os.MkdirTemp("", "ocm-container-browser-")
+	if err != nil {
+		return opts, fmt.Errorf("error creating browser bridge directory: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, shimName), []byte(shim), 0o755)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return opts, fmt.Errorf("error writing browser shim: %v", err)
+	}
+
+	stop, err := f.serve(filepath.Join(dir, socketName))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return opts, err
+	}
+
+	opts.AddVolumeMount(engine.VolumeMount{
+		Source:       dir,
+		Destination:  destDir,
+		MountOptions: "rw",
+	})
+
+	opts.AddEnvKeyVal("BROWSER", destDir+"/"+shimName)
+
+	opts.RegisterPostStartExecHook(func(o features.ContainerRuntime) error {
+		o.RegisterBlockingPostStartCmd([]string{"ln", "-sf", destDir + "/" + shimName, shimLink})
+		return nil
+	})
+
+	opts.RegisterCleanupFunc(func() {
+		stop()
+		_ = os.RemoveAll(dir)
+	})
+
+	return opts, nil
+}
+
+// serve starts the listener on the unix socket and returns a
+// function that shuts it down
+func (f *Feature) serve(socket string) (func(), error) {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error starting browser bridge listener: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(openPath, f.handleOpen)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := srv.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			log.Warnf("browser bridge stopped: %v", err)
+		}
+	}()
+	log.Debugf("browser bridge listening on %s", socket)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
+
+// handleOpen opens the posted URL in the host browser if it is allowed
+func (f *Feature) handleOpen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := r.PostFormValue("url")
+	err := f.config.allowed(u)
+	if err != nil {
+		log.Warnf("browser bridge refused to open %q: %v", u, err)
+		http.Error(w, fmt.Sprintf("refusing to open url: %v", err), http.StatusForbidden)
+		return
+	}
+
+	log.Infof("opening %s in the host browser", u)
+	err = openBrowser(u)
+	if err != nil {
+		log.Warnf("error opening host browser: %v", err)
+		http.Error(w, "error opening host browser", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hostOpen opens a URL with the host's default browser
+func hostOpen(u string) error {
+	name := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		name = "open"
+	}
+
+	cmd := exec.Command(name, u)
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+func (f *Feature) HandleError(err error) {
+	if f.userHasConfig {
+		log.Warnf("Error initializing browser bridge functionality: %v", err)
+		return
+	}
+	log.Debugf("Error initializing browser bridge functionality: %v", err)
+}
+
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+func init() {
+	f := Feature{}
+	if err := features.Register("browser-bridge", &f); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,13 @@
+package browserbridge_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBrowserBridge(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BrowserBridge Suite")
+}
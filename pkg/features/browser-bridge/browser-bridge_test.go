@@ -0,0 +1,191 @@
+package browserbridge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/ocm-container/pkg/engine"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/Features/BrowserBridge/BrowserBridge", func() {
+	BeforeEach(func() {
+		viper.Reset()
+	})
+
+	Context("Tests the config", func() {
+		It("Builds the defaults correctly", func() {
+			cfg := newConfigWithDefaults()
+			Expect(cfg.Enabled).To(BeFalse())
+			Expect(cfg.AllowedSchemes).To(Equal(defaultAllowedSchemes))
+			Expect(cfg.AllowedHosts).To(Equal(defaultAllowedHosts))
+		})
+
+		It("Rejects invalid schemes and hosts", func() {
+			cfg := config{AllowedSchemes: []string{}}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{AllowedSchemes: []string{"https:"}}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{AllowedSchemes: []string{"https"}, AllowedHosts: []string{"https://example.com"}}
+			Expect(cfg.validate()).ToNot(Succeed())
+		})
+
+		It("Is disabled unless enabled in the config", func() {
+			f := Feature{}
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.Enabled()).To(BeFalse())
+
+			viper.Set(configKey, map[string]any{"enabled": true})
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.Enabled()).To(BeTrue())
+
+			viper.Set(FeatureFlagName, true)
+			Expect(f.Enabled()).To(BeFalse())
+		})
+	})
+
+	Context("Tests config.allowed()", func() {
+		cfg := newConfigWithDefaults()
+
+		DescribeTable("checks urls against the allow-list",
+			func(u string, allowed bool) {
+				err := cfg.allowed(u)
+				if allowed {
+					Expect(err).To(BeNil())
+				} else {
+					Expect(err).ToNot(BeNil())
+				}
+			},
+			Entry("localhost console", "http://127.0.0.1:39485", true),
+			Entry("sso login", "https://sso.redhat.com/auth/device", true),
+			Entry("mixed case host", "https://SSO.RedHat.com/", true),
+			Entry("bare parent domain", "https://redhat.com/", false),
+			Entry("lookalike domain", "https://evilredhat.com/", false),
+			Entry("unknown host", "https://example.com/", false),
+			Entry("file scheme", "file:///etc/passwd", false),
+			Entry("javascript scheme", "javascript:alert(1)", false),
+			Entry("no host", "https:///path", false),
+		)
+
+		It("Allows any host with a wildcard", func() {
+			cfg := config{AllowedSchemes: []string{"https"}, AllowedHosts: []string{"*"}}
+			Expect(cfg.allowed("https://example.com")).To(Succeed())
+			Expect(cfg.allowed("http://example.com")).ToNot(Succeed())
+		})
+	})
+
+	Context("Tests Feature.handleOpen()", func() {
+		var opened []string
+
+		BeforeEach(func() {
+			opened = []string{}
+			openBrowser = func(u string) error {
+				opened = append(opened, u)
+				return nil
+			}
+		})
+
+		AfterEach(func() {
+			openBrowser = hostOpen
+		})
+
+		post := func(f *Feature, u string) *httptest.ResponseRecorder {
+			body := url.Values{"url": {u}}.Encode()
+			req := httptest.NewRequest(http.MethodPost, openPath, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			f.handleOpen(rec, req)
+			return rec
+		}
+
+		It("Opens allowed urls", func() {
+			f := &Feature{config: newConfigWithDefaults()}
+			rec := post(f, "https://console.redhat.com")
+			Expect(rec.Code).To(Equal(http.StatusNoContent))
+			Expect(opened).To(Equal([]string{"https://console.redhat.com"}))
+		})
+
+		It("Refuses urls that are not allowed", func() {
+			f := &Feature{config: newConfigWithDefaults()}
+			rec := post(f, "https://example.com")
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(rec.Body.String()).To(ContainSubstring("not allowed"))
+			Expect(opened).To(BeEmpty())
+		})
+
+		It("Only accepts POST requests", func() {
+			f := &Feature{config: newConfigWithDefaults()}
+			rec := httptest.NewRecorder()
+			f.handleOpen(rec, httptest.NewRequest(http.MethodGet, openPath+"?url=https://console.redhat.com", nil))
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(opened).To(BeEmpty())
+		})
+
+		It("Reports errors opening the browser", func() {
+			openBrowser = func(string) error { return fmt.Errorf("no browser") }
+			f := &Feature{config: newConfigWithDefaults()}
+			rec := post(f, "https://console.redhat.com")
+			Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("Tests Feature.Initialize()", func() {
+		var opened []string
+
+		BeforeEach(func() {
+			opened = []string{}
+			openBrowser = func(u string) error {
+				opened = append(opened, u)
+				return nil
+			}
+		})
+
+		AfterEach(func() {
+			openBrowser = hostOpen
+		})
+
+		It("Serves the socket, mounts the shim and cleans up", func() {
+			f := &Feature{config: newConfigWithDefaults()}
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+
+			Expect(opts.Mounts).To(HaveLen(1))
+			Expect(opts.Mounts[0].Destination).To(Equal(destDir))
+			dir := opts.Mounts[0].Source
+			Expect(opts.Envs).To(ContainElement(engine.EnvVar{Key: "BROWSER", Value: destDir + "/" + shimName}))
+			Expect(opts.PostStartExecHooks).To(HaveLen(1))
+			Expect(opts.CleanupFuncs).To(HaveLen(1))
+
+			shimInfo, err := os.Stat(filepath.Join(dir, shimName))
+			Expect(err).To(BeNil())
+			Expect(shimInfo.Mode().Perm() & 0o111).ToNot(BeZero())
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", filepath.Join(dir, socketName))
+				},
+			}}
+			resp, err := client.PostForm("http://localhost"+openPath, url.Values{"url": {"https://console.redhat.com"}})
+			Expect(err).To(BeNil())
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(opened).To(Equal([]string{"https://console.redhat.com"}))
+
+			opts.CleanupFuncs[0]()
+			_, err = os.Stat(dir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
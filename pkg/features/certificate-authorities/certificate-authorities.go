@@ -14,6 +14,8 @@ const (
 	FeatureFlagName = "no-certificate-authorities"
 	FlagHelpMessage = "Disable certificate authority trust mount"
 
+	configKey = "features.certificate_authorities"
+
 	defaultCaTrustSourceAnchorPath = "/etc/pki/ca-trust/source/anchors"
 	defaultcaTrustDestinationPath  = "/etc/pki/ca-trust/source/anchors"
 )
@@ -58,13 +60,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.certificate_authorities") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.certificate_authorities", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -103,6 +105,14 @@ func (f *Feature) HandleError(err error) {
 	log.Debugf("Error initializing certificate authorities functionality: %v", err)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"slices"
+	"strings"
 
 	"github.com/openshift/ocm-container/pkg/engine"
 	log "github.com/sirupsen/logrus"
@@ -17,13 +19,63 @@ type Feature interface {
 	ExitOnError() bool
 }
 
+// Configurable is implemented by features that expose their config
+// struct, so that a schema for the config file can be generated
+type Configurable interface {
+	// ConfigKey returns the config file key the feature reads its
+	// config from, eg: features.jira
+	ConfigKey() string
+	// DefaultConfig returns the feature's config struct with all
+	// defaults applied, which the schema is generated from
+	DefaultConfig() any
+}
+
+// ToolRequirer is implemented by features that rely on tools which are
+// not in every image variant, eg: the jira CLI is only in the full image
+type ToolRequirer interface {
+	RequiredTools() []string
+}
+
 var features map[string]Feature
 
+// image describes the tools in the image being run, so features that
+// need other tools are skipped
+var image struct {
+	variant string
+	tools   []string
+}
+
+// SetImageTools records the variant of the image being run and the
+// tools it contains. Features are not skipped if tools is nil, which
+// means the tools are not known.
+func SetImageTools(variant string, tools []string) {
+	image.variant = variant
+	image.tools = tools
+}
+
+// missingTools returns the tools the feature requires that are not in
+// the image
+func missingTools(f Feature) []string {
+	tr, ok := f.(ToolRequirer)
+	if !ok || image.tools == nil {
+		return nil
+	}
+	missing := []string{}
+	for _, t := range tr.RequiredTools() {
+		if !slices.Contains(image.tools, t) {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
 type OptionSet struct {
 	Mounts             []engine.VolumeMount
 	Envs               []engine.EnvVar
 	PortMap            map[string]int
+	PortBindings       map[string]engine.PortBinding
 	PostStartExecHooks [](func(ContainerRuntime) error)
+	CleanupFuncs       []func()
 }
 
 func (o *OptionSet) AddVolumeMount(mount ...engine.VolumeMount) {
@@ -49,13 +101,31 @@ func (o *OptionSet) RegisterPortMap(ports map[string]int) {
 	maps.Copy(o.PortMap, ports)
 }
 
+// RegisterPortBinding sets the host-side binding for a port
+// registered with RegisterPortMap
+func (o *OptionSet) RegisterPortBinding(name string, binding engine.PortBinding) {
+	if o.PortBindings == nil {
+		o.PortBindings = map[string]engine.PortBinding{}
+	}
+	o.PortBindings[name] = binding
+}
+
 func (o *OptionSet) RegisterPostStartExecHook(hooks ...func(ContainerRuntime) error) {
 	o.PostStartExecHooks = append(o.PostStartExecHooks, hooks...)
 }
 
+// RegisterCleanupFunc registers a function to run when the
+// ocm-container session ends
+func (o *OptionSet) RegisterCleanupFunc(funcs ...func()) {
+	o.CleanupFuncs = append(o.CleanupFuncs, funcs...)
+}
+
 type ContainerRuntime interface {
 	RegisterBlockingPostStartCmd([]string)
 	Inspect(string) (string, error)
+	// HostPort returns the host port a tcp container port is published on
+	HostPort(int) (string, error)
+	Exec([]string) (string, error)
 }
 
 func NewOptionSet() OptionSet {
@@ -63,7 +133,9 @@ func NewOptionSet() OptionSet {
 	o.Mounts = []engine.VolumeMount{}
 	o.Envs = []engine.EnvVar{}
 	o.PostStartExecHooks = [](func(ContainerRuntime) error){}
+	o.CleanupFuncs = []func(){}
 	o.PortMap = map[string]int{}
+	o.PortBindings = map[string]engine.PortBinding{}
 
 	return o
 }
@@ -97,6 +169,14 @@ func Initialize() (OptionSet, error) {
 			log.Infof("%s - feature not enabled", featureName)
 			continue
 		}
+		if missing := missingTools(f); len(missing) > 0 {
+			// Features with user config warn about this; others log it at debug level
+			f.HandleError(fmt.Errorf(
+				"skipped, since it requires %s, which the %s image does not include; select a larger image with --variant",
+				strings.Join(missing, ", "), image.variant,
+			))
+			continue
+		}
 		log.Debugf("feature %s configuration complete", featureName)
 		log.Debugf("initializing feature - %s", featureName)
 		opts, err := f.Initialize()
@@ -109,12 +189,29 @@ func Initialize() (OptionSet, error) {
 		allOptions.AddVolumeMount(opts.Mounts...)
 		allOptions.AddEnv(opts.Envs...)
 		allOptions.RegisterPortMap(opts.PortMap)
+		for name, binding := range opts.PortBindings {
+			allOptions.RegisterPortBinding(name, binding)
+		}
 		allOptions.RegisterPostStartExecHook(opts.PostStartExecHooks...)
+		allOptions.RegisterCleanupFunc(opts.CleanupFuncs...)
 		log.Debugf("feature %s initialization complete", featureName)
 	}
 	return allOptions, terminalErrors
 }
 
+// Configurables returns every registered feature that exposes its
+// config, keyed by the feature name
+func Configurables() map[string]Configurable {
+	c := map[string]Configurable{}
+	for name, f := range features {
+		if cf, ok := f.(Configurable); ok {
+			c[name] = cf
+		}
+	}
+	return c
+}
+
 func Reset() {
 	features = map[string]Feature{}
+	SetImageTools("", nil)
 }
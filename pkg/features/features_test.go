@@ -92,6 +92,24 @@ var _ = Describe("Features", func() {
 		})
 	})
 
+	Describe("Configurables", func() {
+		BeforeEach(func() {
+			features.Reset()
+		})
+
+		It("should only return features that expose their config", func() {
+			err := features.Register("plain", &MockFeature{})
+			Expect(err).NotTo(HaveOccurred())
+			err = features.Register("configurable", &MockConfigurableFeature{})
+			Expect(err).NotTo(HaveOccurred())
+
+			c := features.Configurables()
+			Expect(c).To(HaveLen(1))
+			Expect(c).To(HaveKey("configurable"))
+			Expect(c["configurable"].ConfigKey()).To(Equal("features.mock"))
+		})
+	})
+
 	Describe("Initialize", func() {
 		BeforeEach(func() {
 			features.Reset()
@@ -116,6 +134,59 @@ var _ = Describe("Features", func() {
 			Expect(opts.Mounts).To(ContainElement(mockFeature.options.Mounts[0]))
 		})
 
+		It("should collect cleanup funcs from enabled features", func() {
+			cleaned := false
+			mockFeature := &MockFeature{
+				enabled: true,
+				options: features.OptionSet{
+					CleanupFuncs: []func(){func() { cleaned = true }},
+				},
+			}
+			err := features.Register("cleanup-test", mockFeature)
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := features.Initialize()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.CleanupFuncs).To(HaveLen(1))
+			opts.CleanupFuncs[0]()
+			Expect(cleaned).To(BeTrue())
+		})
+
+		It("should skip features requiring tools the image does not include", func() {
+			mockFeature := &MockToolFeature{
+				MockFeature: MockFeature{enabled: true},
+				tools:       []string{"jira"},
+			}
+			err := features.Register("tool-test", mockFeature)
+			Expect(err).NotTo(HaveOccurred())
+
+			features.SetImageTools("micro", []string{"ocm", "oc"})
+			_, err = features.Initialize()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockFeature.initializeCalled).To(BeFalse())
+			Expect(mockFeature.handleErrorCalled).To(BeTrue())
+		})
+
+		It("should initialize features requiring tools the image includes, or when the tools are unknown", func() {
+			mockFeature := &MockToolFeature{
+				MockFeature: MockFeature{enabled: true},
+				tools:       []string{"jira"},
+			}
+			err := features.Register("tool-test", mockFeature)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = features.Initialize()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockFeature.initializeCalled).To(BeTrue())
+
+			mockFeature.initializeCalled = false
+			features.SetImageTools("full", []string{"ocm", "oc", "jira"})
+			_, err = features.Initialize()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockFeature.initializeCalled).To(BeTrue())
+			Expect(mockFeature.handleErrorCalled).To(BeFalse())
+		})
+
 		It("should skip disabled features", func() {
 			mockFeature := &MockFeature{
 				enabled: false,
@@ -234,6 +305,21 @@ func (m *MockFeature) ExitOnError() bool {
 	return m.exitOnError
 }
 
+// MockConfigurableFeature is a MockFeature that also implements Configurable
+type MockConfigurableFeature struct {
+	MockFeature
+}
+
+func (m *MockConfigurableFeature) ConfigKey() string {
+	return "features.mock"
+}
+
+func (m *MockConfigurableFeature) DefaultConfig() any {
+	return struct {
+		Enabled bool `mapstructure:"enabled"`
+	}{Enabled: true}
+}
+
 func Errorf(format string, args ...interface{}) error {
 	return &mockError{msg: format}
 }
@@ -245,3 +331,13 @@ type mockError struct {
 func (e *mockError) Error() string {
 	return e.msg
 }
+
+// MockToolFeature is a MockFeature that requires tools in the image
+type MockToolFeature struct {
+	MockFeature
+	tools []string
+}
+
+func (m *MockToolFeature) RequiredTools() []string {
+	return m.tools
+}
@@ -16,6 +16,8 @@ const (
 	FeatureFlagName = "no-gcloud"
 	FlagHelpMessage = "Disable GCloud configuration mounting"
 
+	configKey = "features.gcloud"
+
 	gcloudConfigDir = ".config/gcloud"
 )
 
@@ -74,13 +76,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.gcloud") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.gcloud", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -145,6 +147,14 @@ func (f *Feature) statConfigFileLocations() (string, error) {
 	return "", fmt.Errorf("could not find %s in any of: %s", filepath, errorPaths)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
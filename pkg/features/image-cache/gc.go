@@ -0,0 +1,176 @@
+package imagecache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// The cached storage is only readable by the podman inside the
+// ocm-container image, so garbage collection runs podman commands in a
+// container with the storage mounted, either the session's container or
+// a short-lived one started by `ocm-container image-cache`.
+
+// Runner runs a command in a container with the image cache mounted,
+// returning its output
+type Runner func(args []string) (string, error)
+
+// Limits are the garbage collection limits for the image cache. Zero
+// values disable a limit.
+type Limits struct {
+	// MaxSize is the total size of cached images, in bytes
+	MaxSize int64
+	// MaxAge is the age of an image, from when it was built
+	MaxAge time.Duration
+}
+
+func (l Limits) enabled() bool {
+	return l.MaxSize > 0 || l.MaxAge > 0
+}
+
+// cachedImage is an image in `podman images --format json` output
+type cachedImage struct {
+	ID      string   `json:"Id"`
+	Names   []string `json:"Names"`
+	Size    int64    `json:"Size"`
+	Created int64    `json:"Created"`
+}
+
+// ref returns the references to remove the image by. Images with several
+// tags can't be removed by ID without forcing it, which would also remove
+// any containers using them.
+func (i cachedImage) ref() []string {
+	if len(i.Names) > 0 {
+		return i.Names
+	}
+	return []string{i.ID}
+}
+
+// Collect removes dangling images, then any images older than the
+// MaxAge limit, then the oldest images until the cache is under the
+// MaxSize limit. It returns the images removed.
+func Collect(run Runner, limits Limits, now time.Time) ([]string, error) {
+	_, err := run([]string{"podman", "image", "prune", "--force"})
+	if err != nil {
+		return nil, fmt.Errorf("error removing dangling images: %v", err)
+	}
+
+	removed := []string{}
+	if !limits.enabled() {
+		return removed, nil
+	}
+
+	out, err := run([]string{"podman", "images", "--format", "json"})
+	if err != nil {
+		return nil, fmt.Errorf("error listing cached images: %v", err)
+	}
+	images := []cachedImage{}
+	err = json.Unmarshal([]byte(out), &images)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cached images: %v", err)
+	}
+
+	// Oldest first
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].Created < images[j].Created
+	})
+
+	// Layers shared between images are counted once per image, so
+	// this overestimates the size on disk
+	var total int64
+	for _, i := range images {
+		total += i.Size
+	}
+
+	for _, i := range images {
+		expired := limits.MaxAge > 0 && now.Sub(time.Unix(i.Created, 0)) > limits.MaxAge
+		oversize := limits.MaxSize > 0 && total > limits.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+
+		_, err := run(append([]string{"podman", "rmi"}, i.ref()...))
+		if err != nil {
+			// Images used by containers can't be removed
+			log.Debugf("unable to remove cached image %v: %v", i.ref(), err)
+			continue
+		}
+		total -= i.Size
+		removed = append(removed, i.ref()...)
+	}
+
+	return removed, nil
+}
+
+// Usage returns a summary of the image cache's disk usage
+func Usage(run Runner) (string, error) {
+	df, err := run([]string{"podman", "system", "df"})
+	if err != nil {
+		return "", fmt.Errorf("error reading image cache usage: %v", err)
+	}
+	du, err := run([]string{"du", "-sh", destDir})
+	if err != nil {
+		return "", fmt.Errorf("error reading image cache size: %v", err)
+	}
+	return df + "\nTotal on disk: " + du, nil
+}
+
+// Cache is the host directory holding the cached container storage
+type Cache struct {
+	Dir    string
+	Limits Limits
+
+	lock *utils.FileLock
+}
+
+// NewCache returns the image cache from the configured storage
+// directory and limits, creating the directory if needed
+func NewCache() (*Cache, error) {
+	cfg := newConfigWithDefaults()
+	if viper.IsSet(configKey) {
+		err := viper.UnmarshalKey(configKey, &cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	limits, err := cfg.limits()
+	if err != nil {
+		return nil, err
+	}
+
+	f := Feature{config: cfg, afs: &afero.Afero{Fs: afero.NewOsFs()}}
+	dir, err := f.ensureStorageDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir, Limits: limits}, nil
+}
+
+// Lock takes the lock sessions hold while using the cache, returning
+// an error if a session is using it
+func (c *Cache) Lock() error {
+	lock, err := tryLock(lockPath(c.Dir))
+	if errors.Is(err, utils.ErrLocked) {
+		return fmt.Errorf("the image cache is in use by a running ocm-container session")
+	}
+	if err != nil {
+		return fmt.Errorf("error locking the image cache: %v", err)
+	}
+	c.lock = lock
+	return nil
+}
+
+// Unlock releases the lock taken by Lock
+func (c *Cache) Unlock() {
+	if c.lock != nil {
+		_ = c.lock.Unlock()
+		c.lock = nil
+	}
+}
@@ -0,0 +1,85 @@
+package imagecache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakePodman is a Runner that records commands and serves a canned
+// image list
+type fakePodman struct {
+	images string
+	failed map[string]bool
+	cmds   []string
+}
+
+func (p *fakePodman) run(args []string) (string, error) {
+	cmd := strings.Join(args, " ")
+	p.cmds = append(p.cmds, cmd)
+	if p.failed[cmd] {
+		return "", fmt.Errorf("image is in use by a container")
+	}
+	if cmd == "podman images --format json" {
+		return p.images, nil
+	}
+	return "", nil
+}
+
+var _ = Describe("Pkg/Features/ImageCache/GC", func() {
+	const gib = 1024 * 1024 * 1024
+
+	var (
+		now    time.Time
+		podman *fakePodman
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+		days := func(d int) int64 { return now.Add(-time.Duration(d) * 24 * time.Hour).Unix() }
+		podman = &fakePodman{
+			images: fmt.Sprintf(`[
+				{"Id": "aaa", "Names": ["quay.io/app/new:latest"], "Size": %d, "Created": %d},
+				{"Id": "bbb", "Names": ["quay.io/app/old:v1", "quay.io/app/old:v1.0"], "Size": %d, "Created": %d},
+				{"Id": "ccc", "Names": [], "Size": %d, "Created": %d}
+			]`, 2*gib, days(1), 3*gib, days(60), 1*gib, days(10)),
+		}
+	})
+
+	It("Only removes dangling images without limits", func() {
+		removed, err := Collect(podman.run, Limits{}, now)
+		Expect(err).To(BeNil())
+		Expect(removed).To(BeEmpty())
+		Expect(podman.cmds).To(Equal([]string{"podman image prune --force"}))
+	})
+
+	It("Removes images older than MaxAge", func() {
+		removed, err := Collect(podman.run, Limits{MaxAge: 30 * 24 * time.Hour}, now)
+		Expect(err).To(BeNil())
+		Expect(removed).To(Equal([]string{"quay.io/app/old:v1", "quay.io/app/old:v1.0"}))
+		Expect(podman.cmds).To(ContainElement("podman rmi quay.io/app/old:v1 quay.io/app/old:v1.0"))
+	})
+
+	It("Removes the oldest images until under MaxSize", func() {
+		removed, err := Collect(podman.run, Limits{MaxSize: 2 * gib}, now)
+		Expect(err).To(BeNil())
+		Expect(removed).To(Equal([]string{"quay.io/app/old:v1", "quay.io/app/old:v1.0", "ccc"}))
+		Expect(podman.cmds).ToNot(ContainElement("podman rmi quay.io/app/new:latest"))
+	})
+
+	It("Skips images that can't be removed", func() {
+		podman.failed = map[string]bool{"podman rmi quay.io/app/old:v1 quay.io/app/old:v1.0": true}
+		removed, err := Collect(podman.run, Limits{MaxSize: 2 * gib}, now)
+		Expect(err).To(BeNil())
+		Expect(removed).To(Equal([]string{"ccc", "quay.io/app/new:latest"}))
+	})
+
+	It("Returns an error when the images can't be listed", func() {
+		podman.images = "not json"
+		_, err := Collect(podman.run, Limits{MaxSize: gib}, now)
+		Expect(err).To(MatchError(ContainSubstring("error parsing cached images")))
+	})
+})
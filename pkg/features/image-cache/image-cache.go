@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/openshift/ocm-container/pkg/engine"
 	"github.com/openshift/ocm-container/pkg/features"
+	"github.com/openshift/ocm-container/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
@@ -16,13 +19,29 @@ const (
 	FeatureFlagName = "no-image-cache"
 	FlagHelpMessage = "Disable persistent container image caching"
 
+	configKey = "features.image_cache"
+
 	destDir           = "/var/lib/containers/storage/"
 	defaultStorageDir = ".config/ocm-container/images"
+
+	// StorageMountPath is where the cache is mounted in the container
+	StorageMountPath = destDir
 )
 
+// tryLock locks the image cache. It is a var so tests do not lock
+// files in the real home directory.
+var tryLock = utils.TryLockFile
+
 type config struct {
 	Enabled    bool   `mapstructure:"enabled"`
 	StorageDir string `mapstructure:"storage_dir"`
+
+	// MaxSize is the size above which the oldest cached images are
+	// removed when a session starts, eg: 20G. "" disables the limit.
+	MaxSize string `mapstructure:"max_size"`
+	// MaxAge removes cached images built longer ago than this when a
+	// session starts, eg: 30d. "" disables the limit.
+	MaxAge string `mapstructure:"max_age"`
 }
 
 func newConfigWithDefaults() *config {
@@ -33,7 +52,28 @@ func newConfigWithDefaults() *config {
 }
 
 func (cfg *config) validate() error {
-	return nil
+	_, err := cfg.limits()
+	return err
+}
+
+// limits parses the configured garbage collection limits
+func (cfg *config) limits() (Limits, error) {
+	l := Limits{}
+	if cfg.MaxSize != "" {
+		size, err := utils.ParseSize(cfg.MaxSize)
+		if err != nil {
+			return l, fmt.Errorf("invalid max_size: %v", err)
+		}
+		l.MaxSize = size
+	}
+	if cfg.MaxAge != "" {
+		age, err := utils.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return l, fmt.Errorf("invalid max_age: %v", err)
+		}
+		l.MaxAge = age
+	}
+	return l, nil
 }
 
 type Feature struct {
@@ -64,13 +104,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.image_cache") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.image_cache", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -88,20 +128,56 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 	opts := features.NewOptionSet()
 
 	// Determine the storage directory
-	storageDir, err := f.statStorageDir()
+	storageDir, err := f.ensureStorageDir()
 	if err != nil {
 		return opts, fmt.Errorf("error locating storage directory: %v", err)
 	}
 
+	// Two containers using the same storage at once can corrupt it, so
+	// the cache is held for the whole session
+	cache := &Cache{Dir: storageDir}
+	err = cache.Lock()
+	if err != nil {
+		return opts, fmt.Errorf("%v; continuing without it", err)
+	}
+	opts.RegisterCleanupFunc(cache.Unlock)
+
 	opts.AddVolumeMount(engine.VolumeMount{
 		Source:       storageDir,
 		Destination:  destDir,
 		MountOptions: "rw",
 	})
 
+	limits, err := f.config.limits()
+	if err != nil {
+		return opts, err
+	}
+	if limits.enabled() {
+		opts.RegisterPostStartExecHook(func(o features.ContainerRuntime) error {
+			// Size limits are best-effort, and applied in the background
+			// so the session does not wait for them. The user is attached
+			// by the time they finish, so the result is only logged at
+			// debug level.
+			go collectInBackground(o.Exec, limits)
+			return nil
+		})
+	}
+
 	return opts, nil
 }
 
+// collectInBackground applies the cache's limits, logging the result
+func collectInBackground(run Runner, limits Limits) {
+	removed, err := Collect(run, limits, time.Now())
+	if err != nil {
+		log.Debugf("unable to apply image cache limits: %v", err)
+		return
+	}
+	if len(removed) > 0 {
+		log.Debugf("removed %d images from the image cache: %s", len(removed), strings.Join(removed, ", "))
+	}
+}
+
 func (f *Feature) HandleError(err error) {
 	if f.userHasConfig {
 		log.Warnf("Error initializing image cache functionality: %v", err)
@@ -109,10 +185,10 @@ func (f *Feature) HandleError(err error) {
 	log.Debugf("Error initializing image cache functionality: %v", err)
 }
 
-// statStorageDir checks for storage directory locations in the following order:
+// ensureStorageDir checks for storage directory locations in the following order:
 // absolute path -> $HOME/(path)
-// Returns an error if the directory doesn't exist in any of these locations
-func (f *Feature) statStorageDir() (string, error) {
+// If the directory doesn't exist in either location, it is created
+func (f *Feature) ensureStorageDir() (string, error) {
 	dirpath := f.config.StorageDir
 	if dirpath == "" {
 		return "", fmt.Errorf("no storage directory provided")
@@ -130,7 +206,7 @@ func (f *Feature) statStorageDir() (string, error) {
 	}
 
 	// Try $HOME-relative path
-	path := filepath.Join(os.Getenv("HOME"), dirpath)
+	path := resolveStorageDir(dirpath)
 	fileinfo, err = f.afs.Stat(path)
 	if err == nil {
 		log.Debugf("using %s for image cache storage dir", path)
@@ -141,9 +217,42 @@ func (f *Feature) statStorageDir() (string, error) {
 		return path, nil
 	}
 
-	// If neither exists, return an error
-	errorPaths := []string{dirpath, path}
-	return "", fmt.Errorf("could not find %s in any of: %v", dirpath, errorPaths)
+	// If neither exists, create it
+	log.Debugf("creating image cache storage dir %s", path)
+	err = f.afs.MkdirAll(path, 0o700)
+	if err != nil {
+		f.criticalError = true
+		return "", fmt.Errorf("error creating image cache storage dir: %v", err)
+	}
+	return path, nil
+}
+
+// resolveStorageDir returns the storage directory, relative to $HOME
+// if it is not absolute
+func resolveStorageDir(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), dir)
+}
+
+// lockPath returns the file locked while the storage directory is in use
+func lockPath(storageDir string) string {
+	return filepath.Clean(storageDir) + ".lock"
+}
+
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+// RequiredTools returns the tools the feature needs in the image:
+// podman, which is only in the full image
+func (f *Feature) RequiredTools() []string {
+	return []string{"podman"}
 }
 
 func init() {
@@ -2,9 +2,12 @@ package imagecache
 
 import (
 	"os"
+	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/openshift/ocm-container/pkg/utils"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 )
@@ -12,6 +15,16 @@ import (
 var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 	BeforeEach(func() {
 		viper.Reset()
+
+		// Lock files in a temporary directory rather than next to
+		// the in-memory storage directory
+		lockDir := GinkgoT().TempDir()
+		tryLock = func(path string) (*utils.FileLock, error) {
+			return utils.TryLockFile(filepath.Join(lockDir, filepath.Base(path)))
+		}
+		DeferCleanup(func() {
+			tryLock = utils.TryLockFile
+		})
 	})
 
 	Context("Tests the config", func() {
@@ -41,6 +54,26 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 			err := cfg.validate()
 			Expect(err).To(BeNil())
 		})
+
+		It("Parses the limits", func() {
+			cfg := config{
+				MaxSize: "20G",
+				MaxAge:  "30d",
+			}
+			Expect(cfg.validate()).To(Succeed())
+			limits, err := cfg.limits()
+			Expect(err).To(BeNil())
+			Expect(limits.MaxSize).To(Equal(int64(20 * 1024 * 1024 * 1024)))
+			Expect(limits.MaxAge).To(Equal(30 * 24 * time.Hour))
+		})
+
+		It("Returns an error for invalid limits", func() {
+			cfg := config{MaxSize: "big"}
+			Expect(cfg.validate()).To(MatchError(ContainSubstring("invalid max_size")))
+
+			cfg = config{MaxAge: "old"}
+			Expect(cfg.validate()).To(MatchError(ContainSubstring("invalid max_age")))
+		})
 	})
 
 	Context("Tests Feature.Configure()", func() {
@@ -123,7 +156,7 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 		})
 	})
 
-	Context("Tests statStorageDir()", func() {
+	Context("Tests ensureStorageDir()", func() {
 		It("Returns absolute path when it exists and is a directory", func() {
 			afs := afero.Afero{Fs: afero.NewMemMapFs()}
 			storagePath := "/absolute/storage"
@@ -138,7 +171,7 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			result, err := f.statStorageDir()
+			result, err := f.ensureStorageDir()
 			Expect(err).To(BeNil())
 			Expect(result).To(Equal(storagePath))
 		})
@@ -157,7 +190,7 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			result, err := f.statStorageDir()
+			result, err := f.ensureStorageDir()
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(ContainSubstring("not a directory"))
 			Expect(result).To(Equal(""))
@@ -180,7 +213,7 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			result, err := f.statStorageDir()
+			result, err := f.ensureStorageDir()
 			Expect(err).To(BeNil())
 			Expect(result).To(Equal(fullPath))
 		})
@@ -201,16 +234,17 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			result, err := f.statStorageDir()
+			result, err := f.ensureStorageDir()
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(ContainSubstring("not a directory"))
 			Expect(result).To(Equal(""))
 			Expect(f.criticalError).To(BeTrue())
 		})
 
-		It("Returns error when directory doesn't exist in any location", func() {
+		It("Creates the HOME-relative path when it doesn't exist in any location", func() {
 			afs := afero.Afero{Fs: afero.NewMemMapFs()}
 			relativeDir := ".config/nonexistent"
+			fullPath := os.Getenv("HOME") + "/" + relativeDir
 
 			f := Feature{
 				afs: &afs,
@@ -219,10 +253,26 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			result, err := f.statStorageDir()
-			Expect(err).ToNot(BeNil())
-			Expect(err.Error()).To(ContainSubstring("could not find"))
-			Expect(result).To(Equal(""))
+			result, err := f.ensureStorageDir()
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(fullPath))
+			Expect(afs.DirExists(fullPath)).To(BeTrue())
+		})
+
+		It("Creates an absolute path when it doesn't exist", func() {
+			afs := afero.Afero{Fs: afero.NewMemMapFs()}
+
+			f := Feature{
+				afs: &afs,
+				config: &config{
+					StorageDir: "/absolute/new",
+				},
+			}
+
+			result, err := f.ensureStorageDir()
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal("/absolute/new"))
+			Expect(afs.DirExists("/absolute/new")).To(BeTrue())
 		})
 
 		It("Returns error when storage directory is empty", func() {
@@ -235,7 +285,7 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			result, err := f.statStorageDir()
+			result, err := f.ensureStorageDir()
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(ContainSubstring("no storage directory provided"))
 			Expect(result).To(Equal(""))
@@ -269,7 +319,7 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 			Expect(opts.Mounts[0].MountOptions).To(Equal("rw"))
 		})
 
-		It("Returns error when storage directory doesn't exist", func() {
+		It("Creates the storage directory when it doesn't exist", func() {
 			afs := afero.Afero{Fs: afero.NewMemMapFs()}
 			homeDir := os.Getenv("HOME")
 			relativeDir := ".config/nonexistent-cache"
@@ -283,14 +333,58 @@ var _ = Describe("Pkg/Features/ImageCache/ImageCache", func() {
 				},
 			}
 
-			// Verify directory doesn't exist
-			_, err := afs.Stat(expectedPath)
-			Expect(err).ToNot(BeNil())
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.Mounts).To(HaveLen(1))
+			Expect(opts.Mounts[0].Source).To(Equal(expectedPath))
+			Expect(afs.DirExists(expectedPath)).To(BeTrue())
+		})
+
+		It("Holds the lock until the session ends", func() {
+			afs := afero.Afero{Fs: afero.NewMemMapFs()}
+			f := Feature{
+				afs: &afs,
+				config: &config{
+					Enabled:    true,
+					StorageDir: "/cache",
+				},
+			}
 
 			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.CleanupFuncs).To(HaveLen(1))
+
+			// A second session runs without the cache
+			opts2, err := f.Initialize()
 			Expect(err).ToNot(BeNil())
-			Expect(err.Error()).To(ContainSubstring("error locating storage directory"))
-			Expect(opts.Mounts).To(HaveLen(0))
+			Expect(err.Error()).To(ContainSubstring("in use by"))
+			Expect(opts2.Mounts).To(BeEmpty())
+
+			opts.CleanupFuncs[0]()
+			_, err = f.Initialize()
+			Expect(err).To(BeNil())
+		})
+
+		It("Registers a hook to apply the limits", func() {
+			afs := afero.Afero{Fs: afero.NewMemMapFs()}
+			f := Feature{
+				afs: &afs,
+				config: &config{
+					Enabled:    true,
+					StorageDir: "/cache",
+				},
+			}
+
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PostStartExecHooks).To(BeEmpty())
+			opts.CleanupFuncs[0]()
+
+			f.config.MaxAge = "30d"
+			opts, err = f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PostStartExecHooks).To(HaveLen(1))
+			opts.CleanupFuncs[0]()
 		})
 	})
 
@@ -17,6 +17,8 @@ const (
 	FeatureFlagName = "no-jira"
 	FlagHelpMessage = "Disables jira functionality"
 
+	configKey = "features.jira"
+
 	jiraEnvTokenKey           = "JIRA_API_TOKEN" //nolint:gosec // env var name, not a credential
 	jiraEnvEmailKey           = "JIRA_EMAIL"
 	jiraAuthTypeKey           = "JIRA_AUTH_TYPE"
@@ -100,12 +102,12 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.jira") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.jira", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -197,6 +199,21 @@ func (f *Feature) statConfigFileLocations() (string, error) {
 
 	return "", fmt.Errorf("could not find %s in any of: %s", filepath, errorPaths)
 }
+
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+// RequiredTools returns the tools the feature needs in the image:
+// the jira CLI, which is only in the full image
+func (f *Feature) RequiredTools() []string {
+	return []string{"jira"}
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
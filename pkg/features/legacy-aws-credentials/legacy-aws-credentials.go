@@ -15,6 +15,8 @@ const (
 	FeatureFlagName = "no-legacy-aws"
 	FlagHelpMessage = "Disable legacy AWS credentials mounting"
 
+	configKey = "features.legacy_aws_credentials"
+
 	awsCredentials = ".aws/credentials"
 	awsConfig      = ".aws/config"
 )
@@ -57,13 +59,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.legacy_aws_credentials") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.legacy_aws_credentials", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -109,6 +111,20 @@ func (f *Feature) HandleError(err error) {
 	log.Debugf("Error initializing legacy AWS credentials functionality: %v", err)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+// RequiredTools returns the tools the feature needs in the image:
+// the aws CLI, which is not in the micro image
+func (f *Feature) RequiredTools() []string {
+	return []string{"aws"}
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
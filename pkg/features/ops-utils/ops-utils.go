@@ -14,6 +14,8 @@ const (
 	FeatureFlagName = "no-ops-utils"
 	FlagHelpMessage = "Disable ops-utils mounts and environment"
 
+	configKey = "features.ops_utils"
+
 	destinationDir      = "/root/ops-utils"
 	defaultMountOptions = "ro"
 )
@@ -73,13 +75,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.ops_utils") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.ops_utils", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -120,6 +122,14 @@ func (f *Feature) HandleError(err error) {
 	log.Debugf("Error initializing ops-utils functionality: %v", err)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
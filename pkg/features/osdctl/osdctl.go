@@ -15,6 +15,8 @@ const (
 	FeatureFlagName = "no-osdctl"
 	FlagHelpMessage = "Disable OSDCTL mounts and environment"
 
+	configKey = "features.osdctl"
+
 	osdctlConfigFile = ".config/osdctl"
 	vaultTokenFile   = ".vault-token"
 )
@@ -83,13 +85,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.osdctl") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.osdctl", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -167,6 +169,20 @@ func (f *Feature) statFileLocations(filepath string) (string, error) {
 	return "", fmt.Errorf("could not find %s in any of: %s", filepath, errorPaths)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+// RequiredTools returns the tools the feature needs in the image:
+// osdctl, which is not in the micro image
+func (f *Feature) RequiredTools() []string {
+	return []string{"osdctl"}
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
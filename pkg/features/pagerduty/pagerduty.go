@@ -16,6 +16,8 @@ const (
 	FeatureFlagName = "no-pagerduty"
 	FlagHelpMessage = "Disable PagerDuty config mounts and environment"
 
+	configKey = "features.pagerduty"
+
 	defaultPagerDutyTokenFile = ".config/pagerduty/token.json" //nolint:gosec // file path, not a credential
 	pagerDutyTokenDest        = "/root/" + defaultPagerDutyTokenFile
 )
@@ -76,14 +78,14 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.pagerduty") {
+	if !viper.IsSet(configKey) {
 		// if they haven't set a config, exit here
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.pagerduty", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -145,6 +147,14 @@ func (f *Feature) statConfigFileLocations() (string, error) {
 	return "", fmt.Errorf("could not find %s in any of: %s", filepath, errorPaths)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
@@ -0,0 +1,187 @@
+package persistenthistories
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/percluster"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// HistoryEntry is a single command from a cluster's history
+type HistoryEntry struct {
+	ClusterID string
+	// Time is when the command was run, if the history was written
+	// with timestamps, otherwise the zero time
+	Time    time.Time
+	Command string
+}
+
+// Store reads the per-cluster histories saved by the feature
+type Store struct {
+	afs *afero.Afero
+	Dir string
+}
+
+// NewStore returns a Store for the configured storage directory
+func NewStore() (*Store, error) {
+	cfg := newConfigWithDefaults()
+	if viper.IsSet(configKey) {
+		err := viper.UnmarshalKey(configKey, &cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f := Feature{
+		config: cfg,
+		afs:    &afero.Afero{Fs: afero.NewOsFs()},
+	}
+	dir, err := f.statStorageDir()
+	if err != nil {
+		return nil, fmt.Errorf("error locating persistent histories storage directory: %v", err)
+	}
+	return &Store{afs: f.afs, Dir: dir}, nil
+}
+
+// Clusters returns the IDs of all clusters with a saved history
+func (s *Store) Clusters() ([]string, error) {
+	infos, err := s.afs.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		exists, err := s.afs.Exists(s.historyFile(info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			ids = append(ids, info.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Has returns true if a history is saved for the cluster ID
+func (s *Store) Has(clusterID string) bool {
+	if !percluster.ValidClusterID(clusterID) {
+		return false
+	}
+	exists, err := s.afs.Exists(s.historyFile(clusterID))
+	return err == nil && exists
+}
+
+// ClusterName returns the cluster name recorded with the history,
+// or an empty string if it is not known
+func (s *Store) ClusterName(clusterID string) string {
+	if !percluster.ValidClusterID(clusterID) {
+		return ""
+	}
+	data, err := s.afs.ReadFile(filepath.Join(s.Dir, clusterID, percluster.ClusterNameFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Modified returns when the cluster's history was last written
+func (s *Store) Modified(clusterID string) (time.Time, error) {
+	if !percluster.ValidClusterID(clusterID) {
+		return time.Time{}, fmt.Errorf("invalid cluster ID %q", clusterID)
+	}
+	info, err := s.afs.Stat(s.historyFile(clusterID))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Read returns every entry in the cluster's history, oldest first
+func (s *Store) Read(clusterID string) ([]HistoryEntry, error) {
+	file, err := s.afs.Open(s.historyFile(clusterID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []HistoryEntry{}
+	var ts time.Time
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if t, ok := parseTimestamp(line); ok {
+			ts = t
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entries = append(entries, HistoryEntry{ClusterID: clusterID, Time: ts, Command: line})
+		ts = time.Time{}
+	}
+	return entries, scanner.Err()
+}
+
+// Write replaces the cluster's history with the given entries,
+// keeping the given modification time
+func (s *Store) Write(clusterID string, entries []HistoryEntry, modified time.Time) error {
+	var b strings.Builder
+	for _, e := range entries {
+		if !e.Time.IsZero() {
+			fmt.Fprintf(&b, "#%d\n", e.Time.Unix())
+		}
+		b.WriteString(e.Command)
+		b.WriteString("\n")
+	}
+
+	path := s.historyFile(clusterID)
+	err := s.afs.WriteFile(path, []byte(b.String()), os.FileMode(0o600))
+	if err != nil {
+		return err
+	}
+	return s.afs.Chtimes(path, modified, modified)
+}
+
+// Remove deletes the cluster's history
+func (s *Store) Remove(clusterID string) error {
+	return s.afs.Remove(s.historyFile(clusterID))
+}
+
+func (s *Store) historyFile(clusterID string) string {
+	return filepath.Join(s.Dir, clusterID, histFile)
+}
+
+// parseTimestamp parses the `#<epoch>` comment lines bash writes
+// before each command when HISTTIMEFORMAT is set
+func parseTimestamp(line string) (time.Time, bool) {
+	epoch, ok := strings.CutPrefix(line, "#")
+	if !ok || epoch == "" {
+		return time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
+
+// writeClusterName records the cluster's name in its history directory
+func (f *Feature) writeClusterName(dir, name string) error {
+	if name == "" {
+		return nil
+	}
+	return f.afs.WriteFile(filepath.Join(dir, percluster.ClusterNameFile), []byte(name+"\n"), os.FileMode(0o644))
+}
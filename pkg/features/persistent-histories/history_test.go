@@ -0,0 +1,94 @@
+package persistenthistories
+
+import (
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/percluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Pkg/Features/PersistentHistories/History", func() {
+	var (
+		afs   *afero.Afero
+		store *Store
+	)
+
+	BeforeEach(func() {
+		afs = &afero.Afero{Fs: afero.NewMemMapFs()}
+		store = &Store{afs: afs, Dir: "/histories"}
+
+		Expect(afs.WriteFile("/histories/abc123/.bash_history", []byte(
+			"#1700000000\noc get pods\n#1700000060\noc adm must-gather\n\nls\n",
+		), 0o644)).To(Succeed())
+		Expect(afs.WriteFile("/histories/abc123/"+percluster.ClusterNameFile, []byte("my-cluster\n"), 0o644)).To(Succeed())
+		Expect(afs.WriteFile("/histories/def456/.bash_history", []byte("oc whoami\n"), 0o644)).To(Succeed())
+		// directories without a history are ignored
+		Expect(afs.MkdirAll("/histories/empty", 0o755)).To(Succeed())
+	})
+
+	Context("Tests Store.Clusters()", func() {
+		It("Lists clusters with a saved history", func() {
+			ids, err := store.Clusters()
+			Expect(err).To(BeNil())
+			Expect(ids).To(Equal([]string{"abc123", "def456"}))
+			Expect(store.Has("abc123")).To(BeTrue())
+			Expect(store.Has("empty")).To(BeFalse())
+		})
+
+		It("Does not find histories outside the store", func() {
+			Expect(afs.WriteFile("/.bash_history", []byte("oc whoami\n"), 0o644)).To(Succeed())
+			for _, id := range []string{"..", "../histories/abc123", "abc123/..", ""} {
+				Expect(store.Has(id)).To(BeFalse(), id)
+				_, err := store.Modified(id)
+				Expect(err).To(MatchError(ContainSubstring("invalid cluster ID")), id)
+			}
+			Expect(store.ClusterName("../histories/abc123")).To(BeEmpty())
+		})
+	})
+
+	Context("Tests Store.ClusterName()", func() {
+		It("Returns the recorded name", func() {
+			Expect(store.ClusterName("abc123")).To(Equal("my-cluster"))
+		})
+
+		It("Returns an empty string when no name was recorded", func() {
+			Expect(store.ClusterName("def456")).To(BeEmpty())
+		})
+	})
+
+	Context("Tests Store.Read()", func() {
+		It("Parses timestamps and skips blank lines", func() {
+			entries, err := store.Read("abc123")
+			Expect(err).To(BeNil())
+			Expect(entries).To(Equal([]HistoryEntry{
+				{ClusterID: "abc123", Time: time.Unix(1700000000, 0), Command: "oc get pods"},
+				{ClusterID: "abc123", Time: time.Unix(1700000060, 0), Command: "oc adm must-gather"},
+				{ClusterID: "abc123", Command: "ls"},
+			}))
+		})
+
+		It("Keeps comments that are not timestamps", func() {
+			Expect(afs.WriteFile("/histories/def456/.bash_history", []byte("# a note\n"), 0o644)).To(Succeed())
+			entries, err := store.Read("def456")
+			Expect(err).To(BeNil())
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Command).To(Equal("# a note"))
+		})
+
+		It("Returns an error for an unknown cluster", func() {
+			_, err := store.Read("missing")
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	Context("Tests Feature.writeClusterName()", func() {
+		It("Records the cluster name", func() {
+			f := Feature{afs: afs}
+			Expect(f.writeClusterName("/histories/def456", "other-cluster")).To(Succeed())
+			Expect(store.ClusterName("def456")).To(Equal("other-cluster"))
+		})
+	})
+})
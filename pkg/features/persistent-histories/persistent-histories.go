@@ -8,6 +8,7 @@ import (
 	"github.com/openshift/ocm-container/pkg/engine"
 	"github.com/openshift/ocm-container/pkg/features"
 	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/openshift/ocm-container/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
@@ -17,24 +18,74 @@ const (
 	FeatureFlagName = "no-persistent-histories"
 	FlagHelpMessage = "Disable persistent histories file mounts and environment"
 
+	configKey = "features.persistent_histories"
+
 	histFile          = ".bash_history"
 	destDir           = "/root/.cluster-history"
 	defaultStorageDir = ".config/ocm-container/per-cluster-persistent"
+
+	// defaultTimestampFormat makes bash record when each command was run
+	defaultTimestampFormat = "%F %T "
 )
 
 type config struct {
 	Enabled    bool   `mapstructure:"enabled"`
 	StorageDir string `mapstructure:"storage_dir"`
+
+	// TimestampFormat is exported as HISTTIMEFORMAT in the container,
+	// which makes bash save a timestamp with every command. An empty
+	// string disables timestamps.
+	TimestampFormat string `mapstructure:"timestamp_format"`
+
+	Retention retentionConfig `mapstructure:"retention"`
+	Redaction redactionConfig `mapstructure:"redaction"`
+}
+
+type retentionConfig struct {
+	// MaxAge drops commands older than this, eg: 90d
+	MaxAge string `mapstructure:"max_age"`
+	// MaxEntries keeps at most this many commands per cluster
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxTotalSize caps the size of all histories, eg: 50M. The
+	// least recently used clusters' histories are removed first.
+	MaxTotalSize string `mapstructure:"max_total_size"`
+}
+
+type redactionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Patterns are additional regular expressions to redact
+	Patterns []string `mapstructure:"patterns"`
 }
 
 func newConfigWithDefaults() *config {
 	config := config{}
 	config.Enabled = false
 	config.StorageDir = defaultStorageDir
+	config.TimestampFormat = defaultTimestampFormat
+	config.Redaction.Enabled = true
 	return &config
 }
 
 func (cfg *config) validate() error {
+	if cfg.Retention.MaxAge != "" {
+		_, err := utils.ParseDuration(cfg.Retention.MaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid retention max_age: %v", err)
+		}
+	}
+	if cfg.Retention.MaxEntries < 0 {
+		return fmt.Errorf("retention max_entries must not be negative")
+	}
+	if cfg.Retention.MaxTotalSize != "" {
+		_, err := utils.ParseSize(cfg.Retention.MaxTotalSize)
+		if err != nil {
+			return fmt.Errorf("invalid retention max_total_size: %v", err)
+		}
+	}
+	_, err := compilePatterns(cfg.Redaction.Patterns)
+	if err != nil {
+		return fmt.Errorf("invalid redaction pattern: %v", err)
+	}
 	return nil
 }
 
@@ -72,13 +123,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.persistent_histories") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.persistent_histories", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -103,10 +154,11 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 	// Get the cluster ID from OCM
 	ocmClient := ocm.GetClient()
 
-	clusterId, err := ocm.GetClusterId(ocmClient, cluster)
+	clusterObj, err := ocm.GetCluster(ocmClient, cluster)
 	if err != nil {
 		return opts, fmt.Errorf("error getting cluster ID from OCM: %v", err)
 	}
+	clusterId := clusterObj.ID()
 
 	// Determine the storage directory
 	storageDir, err := f.statStorageDir()
@@ -123,6 +175,19 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 		return opts, fmt.Errorf("error creating persistent histories directory: %v", err)
 	}
 
+	err = f.writeClusterName(mount, clusterObj.Name())
+	if err != nil {
+		log.Debugf("unable to record cluster name for persistent history: %v", err)
+	}
+
+	// Apply redaction and retention to the stored histories before the
+	// session, and again after it so new commands are not kept as-is
+	store := &Store{afs: f.afs, Dir: storageDir}
+	f.maintain(store, clusterId)
+	opts.RegisterCleanupFunc(func() {
+		f.maintain(store, clusterId)
+	})
+
 	opts.AddVolumeMount(engine.VolumeMount{
 		Source:       mount,
 		Destination:  destDir,
@@ -134,6 +199,10 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 		Value: destDir + "/" + histFile,
 	})
 
+	if f.config.TimestampFormat != "" {
+		opts.AddEnvKeyVal("HISTTIMEFORMAT", f.config.TimestampFormat)
+	}
+
 	return opts, nil
 }
 
@@ -180,6 +249,14 @@ func (f *Feature) statStorageDir() (string, error) {
 	return "", fmt.Errorf("could not find %s in any of: %s", dirpath, errorPaths)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
@@ -0,0 +1,194 @@
+package persistenthistories
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "[REDACTED]"
+
+// defaultRedactionPatterns match common credentials pasted into a shell.
+// If a pattern has a capture group, the text matched by the first group
+// is kept and only the rest of the match is redacted.
+var defaultRedactionPatterns = []string{
+	// Authorization headers, eg: curl -H "Authorization: Bearer ..."
+	`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`,
+	// Token and secret flags, eg: --token=..., --password ...
+	`(?i)(--(?:token|password|client-secret|secret)[= ]\s*)\S+`,
+	// Token and secret variables, eg: AWS_SECRET_ACCESS_KEY=...
+	`(?i)(\b[A-Z0-9_]*(?:TOKEN|SECRET|PASSWORD|SECRET_ACCESS_KEY)=)\S+`,
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`,
+	// OpenShift tokens
+	`\bsha256~[A-Za-z0-9_-]{20,}`,
+	// JWTs, such as OCM access and refresh tokens
+	`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := []*regexp.Regexp{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// redact replaces every match of the patterns in a command
+func redact(command string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		command = re.ReplaceAllStringFunc(command, func(match string) string {
+			if re.NumSubexp() > 0 {
+				if sub := re.FindStringSubmatch(match); len(sub) > 1 {
+					return sub[1] + redacted
+				}
+			}
+			return redacted
+		})
+	}
+	return command
+}
+
+// maintain applies redaction and retention to the stored histories.
+// Errors are logged, since they should not prevent the session.
+func (f *Feature) maintain(s *Store, current string) {
+	err := f.prune(s, current, time.Now())
+	if err != nil {
+		log.Warnf("error applying persistent history retention: %v", err)
+	}
+}
+
+// prune redacts and trims every cluster's history according to the
+// config, then removes the least recently used histories, other than
+// the current cluster's, until the total size is within the limit
+func (f *Feature) prune(s *Store, current string, now time.Time) error {
+	patterns := []*regexp.Regexp{}
+	if f.config.Redaction.Enabled {
+		var err error
+		patterns, err = compilePatterns(append(append([]string{}, defaultRedactionPatterns...), f.config.Redaction.Patterns...))
+		if err != nil {
+			return err
+		}
+	}
+
+	var cutoff time.Time
+	if f.config.Retention.MaxAge != "" {
+		maxAge, err := utils.ParseDuration(f.config.Retention.MaxAge)
+		if err != nil {
+			return err
+		}
+		cutoff = now.Add(-maxAge)
+	}
+
+	ids, err := s.Clusters()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := f.pruneCluster(s, id, patterns, cutoff)
+		if err != nil {
+			return fmt.Errorf("cluster %s: %v", id, err)
+		}
+	}
+
+	if f.config.Retention.MaxTotalSize == "" {
+		return nil
+	}
+	maxSize, err := utils.ParseSize(f.config.Retention.MaxTotalSize)
+	if err != nil {
+		return err
+	}
+	return f.pruneTotalSize(s, ids, current, maxSize)
+}
+
+func (f *Feature) pruneCluster(s *Store, id string, patterns []*regexp.Regexp, cutoff time.Time) error {
+	entries, err := s.Read(id)
+	if err != nil {
+		return err
+	}
+	modified, err := s.Modified(id)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	kept := []HistoryEntry{}
+	for _, e := range entries {
+		// Commands without a timestamp are as old as the file
+		t := e.Time
+		if t.IsZero() {
+			t = modified
+		}
+		if !cutoff.IsZero() && t.Before(cutoff) {
+			changed = true
+			continue
+		}
+
+		command := redact(e.Command, patterns)
+		if command != e.Command {
+			e.Command = command
+			changed = true
+		}
+		kept = append(kept, e)
+	}
+
+	if maxEntries := f.config.Retention.MaxEntries; maxEntries > 0 && len(kept) > maxEntries {
+		kept = kept[len(kept)-maxEntries:]
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	log.Debugf("rewriting persistent history for cluster %s", id)
+	return s.Write(id, kept, modified)
+}
+
+func (f *Feature) pruneTotalSize(s *Store, ids []string, current string, maxSize int64) error {
+	type history struct {
+		id       string
+		size     int64
+		modified time.Time
+	}
+
+	var total int64
+	histories := []history{}
+	for _, id := range ids {
+		info, err := s.afs.Stat(s.historyFile(id))
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		histories = append(histories, history{id: id, size: info.Size(), modified: info.ModTime()})
+	}
+
+	sort.Slice(histories, func(i, j int) bool { return histories[i].modified.Before(histories[j].modified) })
+	for _, h := range histories {
+		if total <= maxSize {
+			return nil
+		}
+		if h.id == current {
+			continue
+		}
+		log.Infof("removing persistent history for cluster %s to stay within max_total_size", h.id)
+		err := s.Remove(h.id)
+		if err != nil {
+			return err
+		}
+		total -= h.size
+	}
+
+	if total > maxSize {
+		log.Warnf("persistent histories use %d bytes, more than max_total_size %s", total, f.config.Retention.MaxTotalSize)
+	}
+	return nil
+}
@@ -14,6 +14,8 @@ const (
 	FeatureFlagName = "no-personalization"
 	FlagHelpMessage = "Disable personalizations file mounts and environment"
 
+	configKey = "features.personalization"
+
 	destinationDir  = "/root/.config/personalizations.d"
 	destinationFile = "/root/.config/personalizations.d/personalizations.sh"
 )
@@ -73,13 +75,13 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("features.personalization") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("features.personalization", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
@@ -138,6 +140,14 @@ func (f *Feature) isDirectory(path string) (bool, error) {
 	return fileInfo.IsDir(), nil
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{
 		afs: &afero.Afero{Fs: afero.NewOsFs()},
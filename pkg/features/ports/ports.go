@@ -1,9 +1,16 @@
 package ports
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/openshift/ocm-container/pkg/engine"
 	"github.com/openshift/ocm-container/pkg/features"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -14,12 +21,32 @@ const (
 	FeatureFlagName = "no-ports"
 	FlagHelpMessage = "Disables all additional ports "
 
-	portLookupTemplate = `{{(index (index .NetworkSettings.Ports "%d/tcp") 0).HostPort}}`
+	configKey = "ports"
 
 	defaultConsolePort = 9999
 	defaultVaultPort   = 8250
 
 	vaultCallbackPortFile = "/tmp/vault_callback_port"
+
+	// portsFile contains the container and host ports for every named
+	// port, for discovery by tooling inside the container
+	portsFile = "/tmp/ports.json"
+
+	// portEnvPrefix is prepended to the upper-cased name of additional
+	// ports to build the env var holding the container port
+	portEnvPrefix = "OCMC_PORT_"
+
+	minPort = 1
+	maxPort = 65535
+)
+
+var (
+	portNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	reservedNames = []string{"console", "vault"}
+
+	// portAvailable reports whether a host port can be bound. It is a
+	// var so tests do not depend on the state of the host.
+	portAvailable = hostPortAvailable
 )
 
 // Any internal config needed for the setup of the feature
@@ -28,11 +55,41 @@ type config struct {
 
 	Console portConfig `mapstructure:"console"`
 	Vault   portConfig `mapstructure:"vault"`
+
+	// Additional holds any user-defined named ports
+	Additional map[string]portConfig `mapstructure:"additional"`
 }
 
 type portConfig struct {
 	Enabled bool `mapstructure:"enabled"`
 	Port    int  `mapstructure:"port"`
+
+	// HostPort optionally pins the port on the host side. If unset,
+	// the container engine chooses a random free port.
+	HostPort int `mapstructure:"host_port"`
+	// HostPortRange optionally pins the port on the host side to the
+	// first free port in a range, eg: "9000-9010". Mutually exclusive
+	// with HostPort.
+	HostPortRange string `mapstructure:"host_port_range"`
+	// BindAddress is the host address to publish the port on.
+	// Defaults to 127.0.0.1
+	BindAddress string `mapstructure:"bind_address"`
+}
+
+// binding returns the host-side engine binding for the port. Host port
+// ranges are resolved to a single port by Feature.resolveBinding.
+func (p portConfig) binding() engine.PortBinding {
+	return engine.PortBinding{
+		HostPort:    p.HostPort,
+		BindAddress: p.BindAddress,
+	}
+}
+
+// portInfo is written to the ports file for each named port
+type portInfo struct {
+	Port        int    `json:"port"`
+	HostPort    string `json:"host_port"`
+	BindAddress string `json:"bind_address,omitempty"`
 }
 
 // This is where we want to set all of our config defaults. If
@@ -55,10 +112,111 @@ func newConfigWithDefaults() *config {
 // Validate is where any custom configuration validation logic
 // lives. This is where you need to validate your user's input
 func (cfg *config) validate() error {
-	// TODO - validate that each individual port is within a valid range
+	envNames := map[string]string{}
+	for _, name := range sortedNames(cfg.Additional) {
+		p := cfg.Additional[name]
+		if !portNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid name for additional port %q: names may only contain letters, numbers, '-' and '_'", name)
+		}
+		if slices.Contains(reservedNames, strings.ToLower(name)) {
+			return fmt.Errorf("additional port name %q is reserved", name)
+		}
+		if p.Enabled && p.Port == 0 {
+			return fmt.Errorf("additional port %s must define a port", name)
+		}
+		// Names differing only in case or '-' and '_' share an env var
+		env := portEnvName(name)
+		if other, ok := envNames[env]; ok {
+			return fmt.Errorf("additional ports %s and %s would both set %s; rename one of them", other, name, env)
+		}
+		envNames[env] = name
+	}
+
+	containerPorts := map[int]string{}
+	hostPorts := map[int]string{}
+	named := cfg.enabledPorts()
+	for _, name := range sortedNames(named) {
+		p := named[name]
+		err := p.validate(name)
+		if err != nil {
+			return err
+		}
+
+		if other, ok := containerPorts[p.Port]; ok {
+			return fmt.Errorf("%s and %s ports both use container port %d", other, name, p.Port)
+		}
+		containerPorts[p.Port] = name
+
+		if p.HostPort != 0 {
+			if other, ok := hostPorts[p.HostPort]; ok {
+				return fmt.Errorf("%s and %s ports both use host port %d", other, name, p.HostPort)
+			}
+			hostPorts[p.HostPort] = name
+		}
+	}
 	return nil
 }
 
+// enabledPorts returns every enabled named port, keyed by name
+func (cfg *config) enabledPorts() map[string]portConfig {
+	ports := map[string]portConfig{}
+	if cfg.Console.Enabled {
+		ports["console"] = cfg.Console
+	}
+	if cfg.Vault.Enabled {
+		ports["vault"] = cfg.Vault
+	}
+	for name, p := range cfg.Additional {
+		if p.Enabled {
+			ports[name] = p
+		}
+	}
+	return ports
+}
+
+// validate checks the ranges and host settings of a single named port
+func (p portConfig) validate(name string) error {
+	if p.Port < minPort || p.Port > maxPort {
+		return fmt.Errorf("%s port %d is out of range (%d-%d)", name, p.Port, minPort, maxPort)
+	}
+	if p.HostPort != 0 && (p.HostPort < minPort || p.HostPort > maxPort) {
+		return fmt.Errorf("%s host port %d is out of range (%d-%d)", name, p.HostPort, minPort, maxPort)
+	}
+	if p.HostPortRange != "" {
+		if p.HostPort != 0 {
+			return fmt.Errorf("%s port may set only one of host_port and host_port_range", name)
+		}
+		_, _, err := parsePortRange(p.HostPortRange)
+		if err != nil {
+			return fmt.Errorf("invalid host port range for %s port: %v", name, err)
+		}
+	}
+	if p.BindAddress != "" && net.ParseIP(p.BindAddress) == nil {
+		return fmt.Errorf("invalid bind address for %s port: %s", name, p.BindAddress)
+	}
+	return nil
+}
+
+// parsePortRange parses a range of ports in the form "low-high"
+func parsePortRange(r string) (int, int, error) {
+	lowStr, highStr, ok := strings.Cut(r, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q must be in the form low-high", r)
+	}
+	low, err := strconv.Atoi(strings.TrimSpace(lowStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q must be in the form low-high", r)
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(highStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q must be in the form low-high", r)
+	}
+	if low < minPort || high > maxPort || low > high {
+		return 0, 0, fmt.Errorf("%q must be within %d-%d with low <= high", r, minPort, maxPort)
+	}
+	return low, high, nil
+}
+
 type Feature struct {
 	config *config
 
@@ -95,21 +253,25 @@ func (f *Feature) ExitOnError() bool {
 func (f *Feature) Configure() error {
 	cfg := newConfigWithDefaults()
 
-	if !viper.IsSet("ports") {
+	if !viper.IsSet(configKey) {
 		f.config = cfg
 		return nil
 	}
 
 	f.userHasConfig = true
-	err := viper.UnmarshalKey("ports", &cfg)
+	err := viper.UnmarshalKey(configKey, &cfg)
 	if err != nil {
 		return err
 	}
 
-	// The above gets all of the NAMED ports - if a user wants to
-	// define additional ports we'll want to check for those to
-
-	// TODO: check for additional ports in the config
+	// The above gets all of the NAMED ports - additional ports are
+	// enabled unless the user explicitly disables them
+	for name, p := range cfg.Additional {
+		if !viper.IsSet(fmt.Sprintf("%s.additional.%s.enabled", configKey, name)) {
+			p.Enabled = true
+			cfg.Additional[name] = p
+		}
+	}
 
 	f.config = cfg
 	err = cfg.validate()
@@ -133,12 +295,28 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 		ports["vault"] = f.config.Vault.Port
 	}
 
+	for name, p := range f.config.Additional {
+		if !p.Enabled {
+			continue
+		}
+		ports[name] = p.Port
+		opts.AddEnvKeyVal(portEnvName(name), strconv.Itoa(p.Port))
+	}
+
 	opts.RegisterPortMap(ports)
 
+	// Resolve pinned host ports in a stable order so two ports sharing
+	// a range are always assigned the same way
+	taken := map[int]bool{}
+	for _, name := range sortedNames(ports) {
+		if b := f.resolveBinding(name, taken); b != (engine.PortBinding{}) {
+			opts.RegisterPortBinding(name, b)
+		}
+	}
+
 	opts.RegisterPostStartExecHook(func(o features.ContainerRuntime) error {
-		consolePortLookup := fmt.Sprintf(portLookupTemplate, f.config.Console.Port)
 		log.Debugf("Inspect for console port")
-		consolePort, err := o.Inspect(consolePortLookup)
+		consolePort, err := o.HostPort(f.config.Console.Port)
 		if err != nil {
 			return err
 		}
@@ -156,9 +334,8 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 
 	if f.config.Vault.Enabled {
 		opts.RegisterPostStartExecHook(func(o features.ContainerRuntime) error {
-			vaultPortLookup := fmt.Sprintf(portLookupTemplate, f.config.Vault.Port)
 			log.Debugf("Inspect for vault OIDC callback port")
-			vaultPort, err := o.Inspect(vaultPortLookup)
+			vaultPort, err := o.HostPort(f.config.Vault.Port)
 			if err != nil {
 				return err
 			}
@@ -175,9 +352,135 @@ func (f *Feature) Initialize() (features.OptionSet, error) {
 		})
 	}
 
+	if len(f.config.Additional) > 0 {
+		opts.RegisterPostStartExecHook(func(o features.ContainerRuntime) error {
+			return f.writePortsFile(o, ports)
+		})
+	}
+
 	return opts, nil
 }
 
+// portConfig returns the config for a named port
+func (f *Feature) portConfig(name string) portConfig {
+	switch name {
+	case "console":
+		return f.config.Console
+	case "vault":
+		return f.config.Vault
+	}
+	return f.config.Additional[name]
+}
+
+// resolveBinding returns the host binding for a named port. Pinned host
+// ports are checked on the host before the container is created; if
+// none of the requested ports are free, the engine picks a random one.
+func (f *Feature) resolveBinding(name string, taken map[int]bool) engine.PortBinding {
+	p := f.portConfig(name)
+	b := p.binding()
+
+	candidates := []int{}
+	switch {
+	case p.HostPort != 0:
+		candidates = append(candidates, p.HostPort)
+	case p.HostPortRange != "":
+		low, high, err := parsePortRange(p.HostPortRange)
+		if err != nil {
+			log.Warnf("ignoring host port range for %s port: %v", name, err)
+			return b
+		}
+		for port := low; port <= high; port++ {
+			candidates = append(candidates, port)
+		}
+	default:
+		return b
+	}
+
+	addr := b.BindAddress
+	if addr == "" {
+		addr = engine.DefaultBindAddress
+	}
+
+	for _, port := range candidates {
+		if taken[port] {
+			continue
+		}
+		if portAvailable(addr, port) {
+			taken[port] = true
+			b.HostPort = port
+			return b
+		}
+		log.Debugf("host port %s is in use", net.JoinHostPort(addr, strconv.Itoa(port)))
+	}
+
+	requested := p.HostPortRange
+	if p.HostPort != 0 {
+		requested = strconv.Itoa(p.HostPort)
+	}
+	log.Warnf("host port %s for the %s port is in use; falling back to a random port", requested, name)
+	b.HostPort = 0
+	return b
+}
+
+// hostPortAvailable tries to listen on the host port to check that
+// it is not already in use
+func hostPortAvailable(addr string, port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+	return true
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writePortsFile looks up the host port of every named port and
+// registers a command to write them to the ports file as JSON
+func (f *Feature) writePortsFile(o features.ContainerRuntime, ports map[string]int) error {
+	info := map[string]portInfo{}
+	for name, port := range ports {
+		log.Debugf("Inspect for %s port", name)
+		hostPort, err := o.HostPort(port)
+		if err != nil {
+			return err
+		}
+		info[name] = portInfo{
+			Port:        port,
+			HostPort:    hostPort,
+			BindAddress: f.portConfig(name).BindAddress,
+		}
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	portsFileCmd := []string{
+		"/bin/bash",
+		"-c",
+		fmt.Sprintf("echo '%s' > %s", data, portsFile),
+	}
+
+	o.RegisterBlockingPostStartCmd(portsFileCmd)
+	log.Debugf("Ports file blocking command registered: '%s'", strings.Join(portsFileCmd, " "))
+	return nil
+}
+
+// portEnvName returns the env var name for an additional port,
+// eg: grafana-ui -> OCMC_PORT_GRAFANA_UI
+func portEnvName(name string) string {
+	return portEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
 // If initialize fails, how should we handle the error? This
 // allows you to customize what log level to use or how to
 // clean up anything you need to.
@@ -196,6 +499,14 @@ func (f *Feature) HandleError(err error) {
 	log.Debugf("Error initializing ports functionality: %v", err)
 }
 
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
 func init() {
 	f := Feature{}
 	if err := features.Register("ports", &f); err != nil {
@@ -1,7 +1,12 @@
 package ports
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"strings"
+
+	"github.com/openshift/ocm-container/pkg/engine"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -316,6 +321,215 @@ var _ = Describe("Pkg/Features/Ports/Ports", func() {
 		})
 	})
 
+	Context("Tests additional ports", func() {
+		It("Enables additional ports by default", func() {
+			viper.Set("ports", map[string]any{
+				"additional": map[string]any{
+					"grafana": map[string]any{"port": 3000},
+					"pprof":   map[string]any{"port": 6060, "enabled": false},
+				},
+			})
+			f := Feature{}
+			err := f.Configure()
+			Expect(err).To(BeNil())
+			Expect(f.config.Additional).To(HaveLen(2))
+			Expect(f.config.Additional["grafana"].Enabled).To(BeTrue())
+			Expect(f.config.Additional["grafana"].Port).To(Equal(3000))
+			Expect(f.config.Additional["pprof"].Enabled).To(BeFalse())
+		})
+
+		It("Rejects additional ports without a port", func() {
+			cfg := config{Additional: map[string]portConfig{"grafana": {Enabled: true}}}
+			Expect(cfg.validate()).ToNot(Succeed())
+		})
+
+		It("Rejects invalid and reserved names", func() {
+			cfg := config{Additional: map[string]portConfig{"my port": {Enabled: true, Port: 3000}}}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{Additional: map[string]portConfig{"console": {Enabled: true, Port: 3000}}}
+			Expect(cfg.validate()).ToNot(Succeed())
+		})
+
+		It("Rejects names sharing an env var", func() {
+			for _, names := range [][]string{{"grafana-ui", "grafana_ui"}, {"Grafana", "grafana"}} {
+				cfg := config{Additional: map[string]portConfig{
+					names[0]: {Enabled: true, Port: 3000},
+					names[1]: {Enabled: true, Port: 3001},
+				}}
+				Expect(cfg.validate()).To(MatchError(ContainSubstring("would both set " + portEnvName(names[0]))))
+			}
+		})
+
+		It("Rejects invalid bind addresses", func() {
+			cfg := config{Additional: map[string]portConfig{"grafana": {Enabled: true, Port: 3000, BindAddress: "localhost'"}}}
+			Expect(cfg.validate()).ToNot(Succeed())
+		})
+
+		It("Registers ports, bindings, envs and the ports file hook", func() {
+			portAvailable = func(string, int) bool { return true }
+			defer func() { portAvailable = hostPortAvailable }()
+			f := Feature{
+				config: &config{
+					Enabled: true,
+					Console: portConfig{Enabled: true, Port: defaultConsolePort},
+					Additional: map[string]portConfig{
+						"grafana-ui": {Enabled: true, Port: 3000, HostPort: 3001},
+						"pprof":      {Enabled: false, Port: 6060},
+					},
+				},
+			}
+
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PortMap).To(HaveKeyWithValue("grafana-ui", 3000))
+			Expect(opts.PortMap).ToNot(HaveKey("pprof"))
+			Expect(opts.PortBindings).To(HaveKeyWithValue("grafana-ui", engine.PortBinding{HostPort: 3001}))
+			Expect(opts.PortBindings).ToNot(HaveKey("console"))
+			Expect(opts.Envs).To(ContainElement(engine.EnvVar{Key: "OCMC_PORT_GRAFANA_UI", Value: "3000"}))
+			// console hook + ports file hook
+			Expect(opts.PostStartExecHooks).To(HaveLen(2))
+		})
+
+		It("Writes the host ports of every named port to the ports file", func() {
+			f := Feature{
+				config: &config{
+					Additional: map[string]portConfig{
+						"grafana": {Enabled: true, Port: 3000, BindAddress: "0.0.0.0"},
+					},
+				},
+			}
+			rt := &mockRuntime{hostPorts: map[int]string{
+				9999: "41000",
+				3000: "41001",
+			}}
+
+			err := f.writePortsFile(rt, map[string]int{"console": 9999, "grafana": 3000})
+			Expect(err).To(BeNil())
+			Expect(rt.cmds).To(HaveLen(1))
+
+			cmd := rt.cmds[0][2]
+			Expect(cmd).To(HaveSuffix("> " + portsFile))
+			raw := strings.TrimSuffix(strings.TrimPrefix(cmd, "echo '"), "' > "+portsFile)
+			info := map[string]portInfo{}
+			Expect(json.Unmarshal([]byte(raw), &info)).To(Succeed())
+			Expect(info["console"]).To(Equal(portInfo{Port: 9999, HostPort: "41000"}))
+			Expect(info["grafana"]).To(Equal(portInfo{Port: 3000, HostPort: "41001", BindAddress: "0.0.0.0"}))
+		})
+
+		It("Returns an error when a port cannot be inspected", func() {
+			f := Feature{config: &config{}}
+			rt := &mockRuntime{err: fmt.Errorf("no such container")}
+			err := f.writePortsFile(rt, map[string]int{"grafana": 3000})
+			Expect(err).ToNot(BeNil())
+			Expect(rt.cmds).To(BeEmpty())
+		})
+	})
+
+	Context("Tests port validation", func() {
+		It("Rejects ports out of range", func() {
+			cfg := config{Console: portConfig{Enabled: true, Port: 70000}}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{Console: portConfig{Enabled: true, Port: 9999, HostPort: -1}}
+			Expect(cfg.validate()).ToNot(Succeed())
+		})
+
+		It("Rejects duplicate container and host ports", func() {
+			cfg := config{
+				Console:    portConfig{Enabled: true, Port: 9999},
+				Additional: map[string]portConfig{"grafana": {Enabled: true, Port: 9999}},
+			}
+			err := cfg.validate()
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("container port 9999"))
+
+			cfg = config{
+				Console: portConfig{Enabled: true, Port: 9999, HostPort: 8000},
+				Vault:   portConfig{Enabled: true, Port: 8250, HostPort: 8000},
+			}
+			err = cfg.validate()
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("host port 8000"))
+		})
+
+		It("Ignores disabled ports", func() {
+			cfg := config{
+				Console: portConfig{Enabled: true, Port: 9999},
+				Vault:   portConfig{Enabled: false, Port: 9999},
+			}
+			Expect(cfg.validate()).To(Succeed())
+		})
+
+		It("Validates host port ranges", func() {
+			cfg := config{Console: portConfig{Enabled: true, Port: 9999, HostPortRange: "9000-9010"}}
+			Expect(cfg.validate()).To(Succeed())
+			cfg = config{Console: portConfig{Enabled: true, Port: 9999, HostPortRange: "9010-9000"}}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{Console: portConfig{Enabled: true, Port: 9999, HostPortRange: "9000"}}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{Console: portConfig{Enabled: true, Port: 9999, HostPort: 9000, HostPortRange: "9000-9010"}}
+			Expect(cfg.validate()).ToNot(Succeed())
+		})
+	})
+
+	Context("Tests host port pinning", func() {
+		var inUse map[int]bool
+
+		BeforeEach(func() {
+			inUse = map[int]bool{}
+			portAvailable = func(addr string, port int) bool {
+				Expect(addr).To(Equal(engine.DefaultBindAddress))
+				return !inUse[port]
+			}
+		})
+
+		AfterEach(func() {
+			portAvailable = hostPortAvailable
+		})
+
+		It("Pins a free host port", func() {
+			f := Feature{config: &config{Console: portConfig{Enabled: true, Port: 9999, HostPort: 9000}}}
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PortBindings).To(HaveKeyWithValue("console", engine.PortBinding{HostPort: 9000}))
+		})
+
+		It("Falls back to a random port when the host port is in use", func() {
+			inUse[9000] = true
+			f := Feature{config: &config{Console: portConfig{Enabled: true, Port: 9999, HostPort: 9000}}}
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PortBindings).ToNot(HaveKey("console"))
+		})
+
+		It("Uses the first free port in a range without reusing ports", func() {
+			inUse[9000] = true
+			f := Feature{config: &config{
+				Console: portConfig{Enabled: true, Port: 9999, HostPortRange: "9000-9002"},
+				Vault:   portConfig{Enabled: true, Port: 8250, HostPortRange: "9000-9002"},
+			}}
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PortBindings).To(HaveKeyWithValue("console", engine.PortBinding{HostPort: 9001}))
+			Expect(opts.PortBindings).To(HaveKeyWithValue("vault", engine.PortBinding{HostPort: 9002}))
+		})
+
+		It("Keeps the bind address when falling back", func() {
+			portAvailable = func(string, int) bool { return false }
+			f := Feature{config: &config{Console: portConfig{Enabled: true, Port: 9999, HostPortRange: "9000-9001", BindAddress: "0.0.0.0"}}}
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+			Expect(opts.PortBindings).To(HaveKeyWithValue("console", engine.PortBinding{BindAddress: "0.0.0.0"}))
+		})
+
+		It("Detects ports in use on the host", func() {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer l.Close()
+			port := l.Addr().(*net.TCPAddr).Port
+			Expect(hostPortAvailable("127.0.0.1", port)).To(BeFalse())
+		})
+	})
+
 	Context("Tests Feature.HandleError()", func() {
 		It("Does not panic when userHasConfig is true", func() {
 			f := Feature{userHasConfig: true}
@@ -332,3 +546,29 @@ var _ = Describe("Pkg/Features/Ports/Ports", func() {
 		})
 	})
 })
+
+// mockRuntime is a features.ContainerRuntime returning canned host ports
+type mockRuntime struct {
+	hostPorts map[int]string
+	err       error
+	cmds      [][]string
+}
+
+func (m *mockRuntime) RegisterBlockingPostStartCmd(cmd []string) {
+	m.cmds = append(m.cmds, cmd)
+}
+
+func (m *mockRuntime) Exec(cmd []string) (string, error) {
+	return "", nil
+}
+
+func (m *mockRuntime) Inspect(query string) (string, error) {
+	return "", m.err
+}
+
+func (m *mockRuntime) HostPort(port int) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	return m.hostPorts[port], nil
+}
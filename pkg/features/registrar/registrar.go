@@ -3,6 +3,7 @@ package registrar
 import (
 	additionalclusterenvs "github.com/openshift/ocm-container/pkg/features/additional-cluster-envs"
 	"github.com/openshift/ocm-container/pkg/features/backplane"
+	browserbridge "github.com/openshift/ocm-container/pkg/features/browser-bridge"
 	certificateauthorities "github.com/openshift/ocm-container/pkg/features/certificate-authorities"
 	"github.com/openshift/ocm-container/pkg/features/gcloud"
 	imagecache "github.com/openshift/ocm-container/pkg/features/image-cache"
@@ -14,6 +15,8 @@ import (
 	persistenthistories "github.com/openshift/ocm-container/pkg/features/persistent-histories"
 	"github.com/openshift/ocm-container/pkg/features/personalization"
 	"github.com/openshift/ocm-container/pkg/features/ports"
+	tokenrelay "github.com/openshift/ocm-container/pkg/features/token-relay"
+	"github.com/openshift/ocm-container/pkg/features/workspace"
 )
 
 // the registrar package registers the various features by
@@ -70,6 +73,10 @@ var featureFlags = []flag{
 		Name:    backplane.FeatureFlagName,
 		HelpMsg: backplane.FlagHelpMessage,
 	},
+	{
+		Name:    browserbridge.FeatureFlagName,
+		HelpMsg: browserbridge.FlagHelpMessage,
+	},
 	{
 		Name:    additionalclusterenvs.FeatureFlagName,
 		HelpMsg: additionalclusterenvs.FlagHelpMessage,
@@ -78,6 +85,14 @@ var featureFlags = []flag{
 		Name:    ports.FeatureFlagName,
 		HelpMsg: ports.FlagHelpMessage,
 	},
+	{
+		Name:    tokenrelay.FeatureFlagName,
+		HelpMsg: tokenrelay.FlagHelpMessage,
+	},
+	{
+		Name:    workspace.FeatureFlagName,
+		HelpMsg: workspace.FlagHelpMessage,
+	},
 }
 
 func FeatureFlags() []flag {
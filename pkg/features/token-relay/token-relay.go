@@ -0,0 +1,309 @@
+package tokenrelay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/features"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The token relay runs a small HTTP listener on the host for the length
+// of the session, on a unix socket mounted into the container. It serves
+// the OCM config with fresh tokens from the host's OCM connection, without
+// the client secret or password, and an
+// `ocm-token-refresh` script inside the container keeps the container's
+// copy of the OCM config up to date with it, so tools inside the
+// container stay logged in without the host's OCM config being mounted.
+
+const (
+	FeatureFlagName = "no-token-relay"
+	FlagHelpMessage = "Disable refreshing the OCM tokens inside the container from the host"
+
+	configKey = "features.token_relay"
+
+	// destDir is where the socket and shim are mounted in the container
+	destDir    = "/run/ocm-container/token"
+	socketName = "token.sock"
+	shimName   = "ocm-token-refresh"
+
+	// shimLink puts the shim on the PATH, to refresh the tokens by hand
+	shimLink = "/usr/local/bin/ocm-token-refresh"
+
+	// socketEnv tells scripts in the container where the relay is
+	socketEnv = "OCMC_TOKEN_RELAY_SOCKET"
+
+	configPath = "/config"
+	tokenPath  = "/token"
+
+	defaultRefreshInterval = "1m"
+	minRefreshInterval     = 10 * time.Second
+
+	// minValidity is how long the served access token is valid for at
+	// least; tokens expiring sooner are renewed first
+	minValidity = 5 * time.Minute
+)
+
+var (
+	// freshConfig returns the session's OCM config with fresh tokens. It
+	// is a var so tests do not need an OCM connection.
+	freshConfig = ocm.FreshConfig
+)
+
+const shim = `#!/bin/sh
+# Refreshes the OCM config's tokens from the ocm-container token relay.
+# With --watch SECONDS, keeps refreshing them at that interval.
+config="${OCM_CONFIG:-$HOME/.config/ocm/ocm.json}"
+
+refresh() {
+  tmp="$(mktemp "$config.XXXXXX")" || return 1
+  if curl --silent --show-error --fail \
+    --unix-socket ` + destDir + `/` + socketName + ` \
+    --output "$tmp" \
+    http://localhost` + configPath + `; then
+    mv -f "$tmp" "$config"
+  else
+    rm -f "$tmp"
+    return 1
+  fi
+}
+
+if [ "$1" = "--watch" ]; then
+  while :; do
+    refresh 2>/dev/null
+    sleep "${2:-60}"
+  done
+fi
+refresh
+`
+
+type config struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// RefreshInterval is how often the tokens inside the container are
+	// refreshed, eg: 1m
+	RefreshInterval string `mapstructure:"refresh_interval"`
+}
+
+func newConfigWithDefaults() *config {
+	cfg := config{}
+	cfg.Enabled = false
+	cfg.RefreshInterval = defaultRefreshInterval
+	return &cfg
+}
+
+func (cfg *config) validate() error {
+	_, err := cfg.interval()
+	return err
+}
+
+// interval returns the parsed refresh interval
+func (cfg *config) interval() (time.Duration, error) {
+	d, err := time.ParseDuration(cfg.RefreshInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid refresh_interval %q: %v", cfg.RefreshInterval, err)
+	}
+	if d < minRefreshInterval {
+		return 0, fmt.Errorf("refresh_interval must be at least %s", minRefreshInterval)
+	}
+	return d, nil
+}
+
+type Feature struct {
+	config *config
+
+	userHasConfig bool
+}
+
+func (f *Feature) Enabled() bool {
+	if !f.config.Enabled {
+		log.Debugf("token-relay disabled via config")
+		return false
+	}
+	if viper.IsSet(FeatureFlagName) {
+		log.Debugf("token-relay disabled via flag")
+		return false
+	}
+	return true
+}
+
+func (f *Feature) ExitOnError() bool {
+	return false
+}
+
+func (f *Feature) Configure() error {
+	cfg := newConfigWithDefaults()
+
+	if !viper.IsSet(configKey) {
+		f.config = cfg
+		return nil
+	}
+
+	f.userHasConfig = true
+	err := viper.UnmarshalKey(configKey, &cfg)
+	if err != nil {
+		return err
+	}
+
+	f.config = cfg
+	err = cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *Feature) Initialize() (features.OptionSet, error) {
+	opts := features.NewOptionSet()
+
+	interval, err := f.config.interval()
+	if err != nil {
+		return opts, err
+	}
+
+	dir, err := os.MkdirTemp("", "ocm-container-token-")
+	if err != nil {
+		return opts, fmt.Errorf("error creating token relay directory: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, shimName), []byte(shim), 0o755)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return opts, fmt.Errorf("error writing token refresh script: %v", err)
+	}
+
+	stop, err := serve(filepath.Join(dir, socketName))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return opts, err
+	}
+
+	opts.AddVolumeMount(engine.VolumeMount{
+		Source:       dir,
+		Destination:  destDir,
+		MountOptions: "rw",
+	})
+
+	opts.AddEnvKeyVal(socketEnv, destDir+"/"+socketName)
+
+	seconds := strconv.Itoa(int(interval.Seconds()))
+	opts.RegisterPostStartExecHook(func(o features.ContainerRuntime) error {
+		o.RegisterBlockingPostStartCmd([]string{"ln", "-sf", destDir + "/" + shimName, shimLink})
+		o.RegisterBlockingPostStartCmd([]string{"sh", "-c", "nohup " + shimLink + " --watch " + seconds + " >/dev/null 2>&1 &"})
+		return nil
+	})
+
+	opts.RegisterCleanupFunc(func() {
+		stop()
+		_ = os.RemoveAll(dir)
+	})
+
+	return opts, nil
+}
+
+// serve starts the listener on the unix socket and returns a
+// function that shuts it down
+func serve(socket string) (func(), error) {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error starting token relay listener: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(configPath, handleConfig)
+	mux.HandleFunc(tokenPath, handleToken)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := srv.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			log.Warnf("token relay stopped: %v", err)
+		}
+	}()
+	log.Debugf("token relay listening on %s", socket)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
+
+// handleConfig serves the session's OCM config with fresh tokens
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, err := freshConfig(minValidity)
+	if err != nil {
+		log.Warnf("token relay unable to refresh the OCM tokens: %v", err)
+		http.Error(w, "unable to refresh the OCM tokens", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := json.MarshalIndent(ocm.WithoutSecrets(cfg), "", "  ") //nolint:gosec // serving OCM config with tokens is intentional
+	if err != nil {
+		http.Error(w, "unable to marshal the OCM config", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// handleToken serves a fresh access token, for scripts that only need
+// the token
+func handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, err := freshConfig(minValidity)
+	if err != nil {
+		log.Warnf("token relay unable to refresh the OCM tokens: %v", err)
+		http.Error(w, "unable to refresh the OCM tokens", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = fmt.Fprintln(w, cfg.AccessToken)
+}
+
+func (f *Feature) HandleError(err error) {
+	if f.userHasConfig {
+		log.Warnf("Error initializing token relay functionality: %v", err)
+		return
+	}
+	log.Debugf("Error initializing token relay functionality: %v", err)
+}
+
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+func init() {
+	f := Feature{}
+	if err := features.Register("token-relay", &f); err != nil {
+		panic(err)
+	}
+}
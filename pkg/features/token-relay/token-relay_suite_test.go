@@ -0,0 +1,13 @@
+package tokenrelay_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTokenRelay(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TokenRelay Suite")
+}
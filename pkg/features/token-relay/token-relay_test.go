@@ -0,0 +1,171 @@
+package tokenrelay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ocmconfig "github.com/openshift-online/ocm-common/pkg/ocm/config"
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/ocm"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/Features/TokenRelay/TokenRelay", func() {
+	var (
+		calls      int
+		validFor   time.Duration
+		refreshErr error
+		fakeConfig = func(d time.Duration) (*ocmconfig.Config, error) {
+			calls++
+			validFor = d
+			if refreshErr != nil {
+				return nil, refreshErr
+			}
+			return &ocmconfig.Config{
+				URL:          "https://api.openshift.com",
+				AccessToken:  fmt.Sprintf("access-token-%d", calls),
+				RefreshToken: "refresh-token",
+				ClientID:     "ocm-cli",
+				ClientSecret: "client-secret",
+				Password:     "password",
+			}, nil
+		}
+	)
+
+	BeforeEach(func() {
+		viper.Reset()
+		calls = 0
+		refreshErr = nil
+		freshConfig = fakeConfig
+	})
+
+	AfterEach(func() {
+		freshConfig = ocm.FreshConfig
+	})
+
+	Context("Tests the config", func() {
+		It("Builds the defaults correctly", func() {
+			cfg := newConfigWithDefaults()
+			Expect(cfg.Enabled).To(BeFalse())
+			Expect(cfg.RefreshInterval).To(Equal(defaultRefreshInterval))
+			Expect(cfg.validate()).To(Succeed())
+		})
+
+		It("Rejects invalid and too short refresh intervals", func() {
+			cfg := config{RefreshInterval: "often"}
+			Expect(cfg.validate()).ToNot(Succeed())
+			cfg = config{RefreshInterval: "1s"}
+			Expect(cfg.validate()).To(MatchError(ContainSubstring("at least")))
+		})
+
+		It("Is only enabled in the config, unless disabled by flag", func() {
+			f := Feature{}
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.Enabled()).To(BeFalse())
+
+			viper.Set(configKey, map[string]any{"enabled": true})
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.Enabled()).To(BeTrue())
+
+			viper.Set(FeatureFlagName, true)
+			Expect(f.Enabled()).To(BeFalse())
+		})
+	})
+
+	Context("Tests the handlers", func() {
+		It("Serves the OCM config with fresh tokens", func() {
+			rec := httptest.NewRecorder()
+			handleConfig(rec, httptest.NewRequest(http.MethodGet, configPath, nil))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(validFor).To(Equal(minValidity))
+
+			cfg := ocmconfig.Config{}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &cfg)).To(Succeed())
+			Expect(cfg.URL).To(Equal("https://api.openshift.com"))
+			Expect(cfg.AccessToken).To(Equal("access-token-1"))
+			Expect(cfg.RefreshToken).To(Equal("refresh-token"))
+			Expect(cfg.ClientID).To(Equal("ocm-cli"))
+		})
+
+		It("Does not serve the client secret or password", func() {
+			rec := httptest.NewRecorder()
+			handleConfig(rec, httptest.NewRequest(http.MethodGet, configPath, nil))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).ToNot(ContainSubstring("client-secret"))
+			Expect(rec.Body.String()).ToNot(ContainSubstring("password"))
+		})
+
+		It("Serves the access token", func() {
+			rec := httptest.NewRecorder()
+			handleToken(rec, httptest.NewRequest(http.MethodGet, tokenPath, nil))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal("access-token-1\n"))
+		})
+
+		It("Reports errors refreshing the tokens", func() {
+			refreshErr = fmt.Errorf("not logged into OCM")
+			for path, handler := range map[string]http.HandlerFunc{configPath: handleConfig, tokenPath: handleToken} {
+				rec := httptest.NewRecorder()
+				handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(rec.Body.String()).ToNot(ContainSubstring("token-"))
+			}
+		})
+
+		It("Only accepts GET requests", func() {
+			rec := httptest.NewRecorder()
+			handleConfig(rec, httptest.NewRequest(http.MethodPost, configPath, strings.NewReader("")))
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(calls).To(BeZero())
+		})
+	})
+
+	Context("Tests Feature.Initialize()", func() {
+		It("Serves the socket, mounts the script and cleans up", func() {
+			f := &Feature{config: newConfigWithDefaults()}
+			opts, err := f.Initialize()
+			Expect(err).To(BeNil())
+
+			Expect(opts.Mounts).To(HaveLen(1))
+			Expect(opts.Mounts[0].Destination).To(Equal(destDir))
+			dir := opts.Mounts[0].Source
+			Expect(opts.Envs).To(ContainElement(engine.EnvVar{Key: socketEnv, Value: destDir + "/" + socketName}))
+			Expect(opts.PostStartExecHooks).To(HaveLen(1))
+			Expect(opts.CleanupFuncs).To(HaveLen(1))
+
+			shimInfo, err := os.Stat(filepath.Join(dir, shimName))
+			Expect(err).To(BeNil())
+			Expect(shimInfo.Mode().Perm() & 0o111).ToNot(BeZero())
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", filepath.Join(dir, socketName))
+				},
+			}}
+			for i := 1; i <= 2; i++ {
+				resp, err := client.Get("http://localhost" + tokenPath)
+				Expect(err).To(BeNil())
+				body, _ := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(string(body)).To(Equal(fmt.Sprintf("access-token-%d\n", i)))
+			}
+
+			opts.CleanupFuncs[0]()
+			_, err = os.Stat(dir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
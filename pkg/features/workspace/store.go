@@ -0,0 +1,146 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/percluster"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// Store reads the per-cluster workspaces saved by the feature
+type Store struct {
+	afs *afero.Afero
+	Dir string
+}
+
+// PathInfo describes one persisted path of a cluster's workspace
+type PathInfo struct {
+	Name     string
+	HostPath string
+	Size     int64
+	Modified time.Time
+}
+
+// NewStore returns a Store for the configured storage directory
+func NewStore() (*Store, error) {
+	cfg := newConfigWithDefaults()
+	if viper.IsSet(configKey) {
+		err := viper.UnmarshalKey(configKey, &cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Store{
+		afs: &afero.Afero{Fs: afero.NewOsFs()},
+		Dir: storageDir(cfg.StorageDir),
+	}, nil
+}
+
+// Clusters returns the IDs of all clusters with a workspace
+func (s *Store) Clusters() ([]string, error) {
+	infos, err := s.afs.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, info := range infos {
+		if info.IsDir() {
+			ids = append(ids, info.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Has returns true if a workspace exists for the cluster ID
+func (s *Store) Has(clusterID string) bool {
+	if !percluster.ValidClusterID(clusterID) {
+		return false
+	}
+	isDir, err := s.afs.DirExists(s.Path(clusterID))
+	return err == nil && isDir
+}
+
+// ClusterName returns the cluster name recorded with the workspace,
+// or an empty string if it is not known
+func (s *Store) ClusterName(clusterID string) string {
+	if !percluster.ValidClusterID(clusterID) {
+		return ""
+	}
+	data, err := s.afs.ReadFile(filepath.Join(s.Path(clusterID), percluster.ClusterNameFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Paths returns each persisted path in the cluster's workspace
+func (s *Store) Paths(clusterID string) ([]PathInfo, error) {
+	infos, err := s.afs.ReadDir(s.Path(clusterID))
+	if err != nil {
+		return nil, err
+	}
+	paths := []PathInfo{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		p := filepath.Join(s.Path(clusterID), info.Name())
+		size, modified, err := s.usage(p)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, PathInfo{Name: info.Name(), HostPath: p, Size: size, Modified: modified})
+	}
+	return paths, nil
+}
+
+// Size returns the total size of the cluster's workspace in bytes
+func (s *Store) Size(clusterID string) (int64, error) {
+	size, _, err := s.usage(s.Path(clusterID))
+	return size, err
+}
+
+// Modified returns when anything in the cluster's workspace last changed
+func (s *Store) Modified(clusterID string) (time.Time, error) {
+	_, modified, err := s.usage(s.Path(clusterID))
+	return modified, err
+}
+
+// Remove deletes the cluster's workspace
+func (s *Store) Remove(clusterID string) error {
+	return s.afs.RemoveAll(s.Path(clusterID))
+}
+
+// Path returns the host directory of the cluster's workspace
+func (s *Store) Path(clusterID string) string {
+	return filepath.Join(s.Dir, clusterID)
+}
+
+// usage walks a directory, returning the total size of its files and
+// the latest modification time of anything in it
+func (s *Store) usage(dir string) (int64, time.Time, error) {
+	var size int64
+	var modified time.Time
+	err := s.afs.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+		return nil
+	})
+	return size, modified, err
+}
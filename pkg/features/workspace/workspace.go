@@ -0,0 +1,274 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/features"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/openshift/ocm-container/pkg/percluster"
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// The workspace feature persists directories inside the container across
+// sessions on a per-cluster basis, such as scratch notes, must-gathers or
+// tool caches. Each configured path is stored on the host under
+// <storage_dir>/<cluster id>/<name> and mounted at its container path.
+
+const (
+	FeatureFlagName = "no-workspace"
+	FlagHelpMessage = "Disable the per-cluster persistent workspace mounts"
+
+	configKey = "features.workspace"
+
+	defaultStorageDir = ".config/ocm-container/per-cluster-workspace"
+	defaultQuota      = "5G"
+)
+
+var pathNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+type config struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	StorageDir string `mapstructure:"storage_dir"`
+
+	// Paths maps a name for each persisted path to its location in
+	// the container. Setting a path to "" disables it.
+	Paths map[string]string `mapstructure:"paths"`
+
+	// Quota is the size above which a cluster's workspace is reported,
+	// eg: 5G. Nothing is removed automatically. "" disables the warning.
+	Quota string `mapstructure:"quota"`
+}
+
+func newConfigWithDefaults() *config {
+	cfg := config{}
+	cfg.Enabled = false
+	cfg.StorageDir = defaultStorageDir
+	cfg.Paths = map[string]string{
+		"workspace": "/root/workspace",
+	}
+	cfg.Quota = defaultQuota
+	return &cfg
+}
+
+func (cfg *config) validate() error {
+	if cfg.StorageDir == "" {
+		return fmt.Errorf("storage_dir must not be empty")
+	}
+
+	seen := map[string]string{}
+	for name, dest := range cfg.Paths {
+		if !pathNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid workspace path name %q: names may only contain letters, numbers, '.', '-' and '_', and may not start with '.'", name)
+		}
+		if dest == "" {
+			continue
+		}
+		if !path.IsAbs(dest) {
+			return fmt.Errorf("workspace path %s must be an absolute container path: %s", name, dest)
+		}
+		clean := path.Clean(dest)
+		if clean == "/" || clean == "/root" {
+			return fmt.Errorf("workspace path %s may not be mounted at %s", name, clean)
+		}
+		if other, ok := seen[clean]; ok {
+			return fmt.Errorf("workspace paths %s and %s are both mounted at %s", other, name, clean)
+		}
+		seen[clean] = name
+	}
+
+	if cfg.Quota != "" {
+		_, err := utils.ParseSize(cfg.Quota)
+		if err != nil {
+			return fmt.Errorf("invalid quota: %v", err)
+		}
+	}
+	return nil
+}
+
+// enabledPaths returns the configured paths that are not disabled
+func (cfg *config) enabledPaths() map[string]string {
+	paths := map[string]string{}
+	for name, dest := range cfg.Paths {
+		if dest != "" {
+			paths[name] = path.Clean(dest)
+		}
+	}
+	return paths
+}
+
+type Feature struct {
+	config *config
+
+	userHasConfig bool
+	afs           *afero.Afero
+
+	criticalError bool
+}
+
+func (f *Feature) Enabled() bool {
+	if !f.config.Enabled {
+		log.Debugf("workspace disabled via config")
+		return false
+	}
+	if viper.IsSet(FeatureFlagName) {
+		log.Debugf("workspace disabled via flag")
+		return false
+	}
+	// Only enable if cluster-id is provided
+	if viper.GetString("cluster-id") == "" {
+		log.Debugf("workspace disabled: no cluster-id provided")
+		return false
+	}
+	return true
+}
+
+func (f *Feature) ExitOnError() bool {
+	return f.criticalError
+}
+
+func (f *Feature) Configure() error {
+	cfg := newConfigWithDefaults()
+
+	if !viper.IsSet(configKey) {
+		f.config = cfg
+		return nil
+	}
+
+	f.userHasConfig = true
+	err := viper.UnmarshalKey(configKey, &cfg)
+	if err != nil {
+		return err
+	}
+
+	f.config = cfg
+	err = cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *Feature) Initialize() (features.OptionSet, error) {
+	opts := features.NewOptionSet()
+
+	cluster := viper.GetString("cluster-id")
+	if cluster == "" {
+		return opts, fmt.Errorf("cluster-id is required for the workspace")
+	}
+
+	clusterObj, err := ocm.GetCluster(ocm.GetClient(), cluster)
+	if err != nil {
+		return opts, fmt.Errorf("error getting cluster ID from OCM: %v", err)
+	}
+
+	store := &Store{afs: f.afs, Dir: storageDir(f.config.StorageDir)}
+	err = f.mountPaths(&opts, store, clusterObj.ID(), clusterObj.Name())
+	if err != nil {
+		f.criticalError = true
+		return opts, err
+	}
+
+	f.checkQuota(store, clusterObj.ID())
+	opts.RegisterCleanupFunc(func() {
+		f.checkQuota(store, clusterObj.ID())
+	})
+
+	return opts, nil
+}
+
+// mountPaths creates the cluster's directory for every enabled path
+// and mounts it into the container
+func (f *Feature) mountPaths(opts *features.OptionSet, store *Store, clusterID, clusterName string) error {
+	base := store.Path(clusterID)
+	log.Debugf("ensuring workspace exists for cluster: %s", base)
+	err := f.afs.MkdirAll(base, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error creating workspace directory: %v", err)
+	}
+
+	if clusterName != "" {
+		err = f.afs.WriteFile(filepath.Join(base, percluster.ClusterNameFile), []byte(clusterName+"\n"), os.FileMode(0o644))
+		if err != nil {
+			log.Debugf("unable to record cluster name for workspace: %v", err)
+		}
+	}
+
+	for name, dest := range f.config.enabledPaths() {
+		source := filepath.Join(base, name)
+		err = f.afs.MkdirAll(source, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("error creating workspace directory %s: %v", name, err)
+		}
+		opts.AddVolumeMount(engine.VolumeMount{
+			Source:       source,
+			Destination:  dest,
+			MountOptions: "rw",
+		})
+	}
+	return nil
+}
+
+// checkQuota warns if the cluster's workspace is larger than the quota
+func (f *Feature) checkQuota(store *Store, clusterID string) {
+	if f.config.Quota == "" {
+		return
+	}
+	quota, err := utils.ParseSize(f.config.Quota)
+	if err != nil {
+		return
+	}
+	size, err := store.Size(clusterID)
+	if err != nil {
+		log.Debugf("unable to check workspace size: %v", err)
+		return
+	}
+	if size > quota {
+		log.Warnf(
+			"the workspace for cluster %s uses %s, more than the %s quota; run `ocm-container workspace ls %s` and `ocm-container workspace prune %s` to clean it up",
+			clusterID, utils.FormatSize(size), f.config.Quota, clusterID, clusterID,
+		)
+	}
+}
+
+// storageDir returns the storage directory, relative to $HOME if
+// it is not absolute
+func storageDir(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), dir)
+}
+
+func (f *Feature) HandleError(err error) {
+	if f.userHasConfig {
+		log.Warnf("Error initializing workspace functionality: %v", err)
+		return
+	}
+	log.Debugf("Error initializing workspace functionality: %v", err)
+}
+
+func (f *Feature) ConfigKey() string {
+	return configKey
+}
+
+func (f *Feature) DefaultConfig() any {
+	return newConfigWithDefaults()
+}
+
+func init() {
+	f := Feature{
+		afs: &afero.Afero{Fs: afero.NewOsFs()},
+	}
+	if err := features.Register("workspace", &f); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,198 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/features"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/Features/Workspace", func() {
+	BeforeEach(func() {
+		viper.Reset()
+	})
+
+	Context("Tests the config", func() {
+		It("Builds the defaults correctly", func() {
+			cfg := newConfigWithDefaults()
+			Expect(cfg.Enabled).To(BeFalse())
+			Expect(cfg.StorageDir).To(Equal(defaultStorageDir))
+			Expect(cfg.Paths).To(Equal(map[string]string{"workspace": "/root/workspace"}))
+			Expect(cfg.Quota).To(Equal(defaultQuota))
+			Expect(cfg.validate()).To(Succeed())
+		})
+
+		It("Skips disabled paths", func() {
+			cfg := config{Paths: map[string]string{
+				"workspace": "",
+				"notes":     "/root/notes/",
+			}}
+			Expect(cfg.enabledPaths()).To(Equal(map[string]string{"notes": "/root/notes"}))
+		})
+	})
+
+	Context("Tests config.validate()", func() {
+		DescribeTable("Validates the config",
+			func(paths map[string]string, quota string, errMsg string) {
+				cfg := newConfigWithDefaults()
+				cfg.Paths = paths
+				cfg.Quota = quota
+				err := cfg.validate()
+				if errMsg == "" {
+					Expect(err).To(BeNil())
+					return
+				}
+				Expect(err).ToNot(BeNil())
+				Expect(err.Error()).To(ContainSubstring(errMsg))
+			},
+			Entry("valid paths", map[string]string{"workspace": "/root/workspace", "gather": "/root/must-gather"}, "1G", ""),
+			Entry("disabled path", map[string]string{"workspace": ""}, "", ""),
+			Entry("invalid name", map[string]string{"../x": "/root/x"}, "", "invalid workspace path name"),
+			Entry("hidden name", map[string]string{".cluster_name": "/root/x"}, "", "invalid workspace path name"),
+			Entry("relative path", map[string]string{"workspace": "workspace"}, "", "must be an absolute container path"),
+			Entry("root path", map[string]string{"workspace": "/"}, "", "may not be mounted at /"),
+			Entry("home path", map[string]string{"workspace": "/root/"}, "", "may not be mounted at /root"),
+			Entry("duplicate path", map[string]string{"a": "/root/x", "b": "/root/x/"}, "", "are both mounted at /root/x"),
+			Entry("invalid quota", map[string]string{}, "lots", "invalid quota"),
+		)
+	})
+
+	Context("Tests Feature.Configure()", func() {
+		It("Uses defaults when no config is set", func() {
+			f := Feature{}
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.userHasConfig).To(BeFalse())
+			Expect(f.config.Enabled).To(BeFalse())
+		})
+
+		It("Merges configured paths with the defaults", func() {
+			viper.Set(configKey, map[string]any{
+				"enabled": true,
+				"paths": map[string]any{
+					"notes": "/root/notes",
+				},
+			})
+			f := Feature{}
+			Expect(f.Configure()).To(Succeed())
+			Expect(f.userHasConfig).To(BeTrue())
+			Expect(f.config.enabledPaths()).To(Equal(map[string]string{
+				"workspace": "/root/workspace",
+				"notes":     "/root/notes",
+			}))
+		})
+
+		It("Returns an error for an invalid config", func() {
+			viper.Set(configKey, map[string]any{
+				"paths": map[string]any{
+					"notes": "notes",
+				},
+			})
+			f := Feature{}
+			Expect(f.Configure()).ToNot(Succeed())
+		})
+	})
+
+	Context("Tests Feature.Enabled()", func() {
+		It("Requires the config and a cluster-id", func() {
+			f := Feature{config: newConfigWithDefaults()}
+			viper.Set("cluster-id", "my-cluster")
+			Expect(f.Enabled()).To(BeFalse())
+
+			f.config.Enabled = true
+			Expect(f.Enabled()).To(BeTrue())
+
+			viper.Set(FeatureFlagName, true)
+			Expect(f.Enabled()).To(BeFalse())
+		})
+
+		It("Is disabled without a cluster-id", func() {
+			f := Feature{config: &config{Enabled: true}}
+			Expect(f.Enabled()).To(BeFalse())
+		})
+	})
+
+	Context("Tests mounting the workspace", func() {
+		var (
+			afs   *afero.Afero
+			f     Feature
+			store *Store
+		)
+
+		BeforeEach(func() {
+			afs = &afero.Afero{Fs: afero.NewMemMapFs()}
+			cfg := newConfigWithDefaults()
+			cfg.Paths["gather"] = "/root/must-gather"
+			f = Feature{config: cfg, afs: afs}
+			store = &Store{afs: afs, Dir: "/storage"}
+		})
+
+		It("Creates and mounts each path", func() {
+			opts := features.NewOptionSet()
+			Expect(f.mountPaths(&opts, store, "abc123", "my-cluster")).To(Succeed())
+
+			Expect(opts.Mounts).To(ConsistOf(
+				engine.VolumeMount{Source: "/storage/abc123/workspace", Destination: "/root/workspace", MountOptions: "rw"},
+				engine.VolumeMount{Source: "/storage/abc123/gather", Destination: "/root/must-gather", MountOptions: "rw"},
+			))
+			Expect(afs.DirExists("/storage/abc123/workspace")).To(BeTrue())
+			Expect(afs.DirExists("/storage/abc123/gather")).To(BeTrue())
+			Expect(store.ClusterName("abc123")).To(Equal("my-cluster"))
+		})
+
+		It("Lists and measures workspaces", func() {
+			opts := features.NewOptionSet()
+			Expect(f.mountPaths(&opts, store, "abc123", "my-cluster")).To(Succeed())
+			Expect(afs.WriteFile("/storage/abc123/workspace/notes.txt", []byte("12345"), 0o644)).To(Succeed())
+			Expect(afs.WriteFile("/storage/abc123/gather/out.log", []byte("123"), 0o644)).To(Succeed())
+			modified := time.Now().Add(time.Hour).Truncate(time.Second)
+			Expect(afs.Chtimes("/storage/abc123/gather/out.log", modified, modified)).To(Succeed())
+
+			Expect(store.Clusters()).To(Equal([]string{"abc123"}))
+			Expect(store.Has("abc123")).To(BeTrue())
+			Expect(store.Has("def456")).To(BeFalse())
+			Expect(store.Has("../abc123")).To(BeFalse())
+
+			// The cluster name file counts towards the size
+			Expect(store.Size("abc123")).To(Equal(int64(8 + len("my-cluster\n"))))
+			Expect(store.Modified("abc123")).To(Equal(modified))
+
+			paths, err := store.Paths("abc123")
+			Expect(err).To(BeNil())
+			Expect(paths).To(HaveLen(2))
+			Expect(paths[0].Name).To(Equal("gather"))
+			Expect(paths[0].HostPath).To(Equal(filepath.Join("/storage", "abc123", "gather")))
+			Expect(paths[0].Size).To(Equal(int64(3)))
+			Expect(paths[1].Name).To(Equal("workspace"))
+			Expect(paths[1].Size).To(Equal(int64(5)))
+
+			Expect(store.Remove("abc123")).To(Succeed())
+			Expect(store.Clusters()).To(BeEmpty())
+		})
+
+		It("Returns no clusters when the storage directory is missing", func() {
+			Expect(store.Clusters()).To(BeEmpty())
+		})
+
+		It("Does not fail when the workspace is over quota", func() {
+			f.config.Quota = "1"
+			opts := features.NewOptionSet()
+			Expect(f.mountPaths(&opts, store, "abc123", "my-cluster")).To(Succeed())
+			Expect(afs.WriteFile("/storage/abc123/workspace/big", []byte("12345"), os.FileMode(0o644))).To(Succeed())
+			f.checkQuota(store, "abc123")
+		})
+	})
+
+	Context("Tests storageDir()", func() {
+		It("Resolves relative paths against $HOME", func() {
+			GinkgoT().Setenv("HOME", "/home/user")
+			Expect(storageDir("/abs/dir")).To(Equal("/abs/dir"))
+			Expect(storageDir(".config/ws")).To(Equal("/home/user/.config/ws"))
+		})
+	})
+})
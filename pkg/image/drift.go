@@ -0,0 +1,52 @@
+package image
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionLabel is the image label recording the ocm-container release
+// the image was built from
+const VersionLabel = "io.openshift.ocm-container.version"
+
+// CheckDrift compares the release versions of the binary and the image
+// and returns a warning if they are from different minor releases, or
+// an empty string if they match or either version is unknown
+func CheckDrift(binaryVersion, imageVersion string) string {
+	binary, ok := majorMinor(binaryVersion)
+	if !ok {
+		return ""
+	}
+	img, ok := majorMinor(imageVersion)
+	if !ok {
+		return ""
+	}
+	if binary == img {
+		return ""
+	}
+	return fmt.Sprintf(
+		"the ocm-container binary (%s) and image (%s) are from different releases; update whichever is older, or run the image matching the binary with `--image-tag %s`",
+		binaryVersion, imageVersion, ChannelRelease,
+	)
+}
+
+// majorMinor returns the major and minor parts of a semantic version
+func majorMinor(version string) ([2]int, bool) {
+	mm := [2]int{}
+	if version == "" || version == unknownVersion {
+		return mm, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return mm, false
+	}
+	for i := range mm {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return mm, false
+		}
+		mm[i] = n
+	}
+	return mm, true
+}
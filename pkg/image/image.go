@@ -0,0 +1,123 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The image package resolves the container image ocm-container runs
+// from the `image`, `imageTag` and `imageVerification` options: applying
+// tags and release channels, pinning digests, verifying signatures and
+// checking that the image and the binary are from similar releases.
+
+const (
+	// ChannelLatest is the nightly build of the image
+	ChannelLatest = "latest"
+	// ChannelStable is the most recent build promoted to stable
+	ChannelStable = "stable"
+	// ChannelRelease is the image built for this ocm-container release
+	ChannelRelease = "release"
+
+	// unknownVersion is the version of binaries built without a release
+	// version, eg: with `go build`
+	unknownVersion = "v0.0.0-unknown"
+)
+
+var digestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Reference is a parsed container image reference, eg:
+// quay.io/org/image:tag or quay.io/org/image@sha256:...
+type Reference struct {
+	// Name is the image name, including the registry if any
+	Name   string
+	Tag    string
+	Digest string
+}
+
+// ParseReference parses an image reference into its name, tag and digest
+func ParseReference(s string) (Reference, error) {
+	r := Reference{}
+	if s == "" {
+		return r, fmt.Errorf("image must not be empty")
+	}
+	if strings.ContainsAny(s, " \t\n") {
+		return r, fmt.Errorf("invalid image %q: must not contain whitespace", s)
+	}
+
+	name, digest, found := strings.Cut(s, "@")
+	if found {
+		if !digestRegex.MatchString(digest) {
+			return r, fmt.Errorf("invalid digest %q in image %s: must be sha256: followed by 64 hex characters", digest, s)
+		}
+		r.Digest = digest
+	}
+
+	// A colon after the last slash separates the tag; earlier colons
+	// are registry ports, eg: localhost:5000/image
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		r.Tag = name[i+1:]
+		name = name[:i]
+		if r.Tag == "" {
+			return r, fmt.Errorf("invalid image %q: empty tag", s)
+		}
+	}
+	if name == "" {
+		return r, fmt.Errorf("invalid image %q: empty name", s)
+	}
+	r.Name = name
+	return r, nil
+}
+
+// String returns the reference in the form the container engines expect
+func (r Reference) String() string {
+	s := r.Name
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// Pinned returns true if the reference is pinned to a digest
+func (r Reference) Pinned() bool {
+	return r.Digest != ""
+}
+
+// TagForChannel returns the image tag for an imageTag option, which may
+// be a channel or a literal tag. The release channel is the tag of the
+// ocm-container release the binary was built from.
+func TagForChannel(channel, version string) (string, error) {
+	switch channel {
+	case ChannelLatest, ChannelStable:
+		return channel, nil
+	case ChannelRelease:
+		if version == "" || version == unknownVersion {
+			return "", fmt.Errorf("the %s image tag requires a released ocm-container binary, but this binary has no release version", ChannelRelease)
+		}
+		return "v" + strings.TrimPrefix(version, "v"), nil
+	}
+	if strings.ContainsAny(channel, ":@/ ") {
+		return "", fmt.Errorf("invalid image tag %q", channel)
+	}
+	return channel, nil
+}
+
+// Resolve returns the reference for an image, with the tag from the
+// imageTag option (a channel or literal tag) applied if it is set
+func Resolve(image, tag, version string) (Reference, error) {
+	r, err := ParseReference(image)
+	if err != nil {
+		return r, err
+	}
+	if tag == "" {
+		return r, nil
+	}
+	if r.Pinned() {
+		return r, fmt.Errorf("image %s is pinned to a digest, and can't also use image tag %s", image, tag)
+	}
+	r.Tag, err = TagForChannel(tag, version)
+	return r, err
+}
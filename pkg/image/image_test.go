@@ -0,0 +1,193 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Image", func() {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	otherDigest := "sha256:" + strings.Repeat("b", 64)
+
+	Context("Tests ParseReference()", func() {
+		DescribeTable("Parses image references",
+			func(s string, expected Reference) {
+				r, err := ParseReference(s)
+				Expect(err).To(BeNil())
+				Expect(r).To(Equal(expected))
+				Expect(r.String()).To(Equal(s))
+			},
+			Entry("name only", "ocm-container", Reference{Name: "ocm-container"}),
+			Entry("tag", "quay.io/org/image:latest", Reference{Name: "quay.io/org/image", Tag: "latest"}),
+			Entry("digest", "quay.io/org/image@"+digest, Reference{Name: "quay.io/org/image", Digest: digest}),
+			Entry("tag and digest", "quay.io/org/image:v1@"+digest, Reference{Name: "quay.io/org/image", Tag: "v1", Digest: digest}),
+			Entry("registry port", "localhost:5000/image", Reference{Name: "localhost:5000/image"}),
+			Entry("registry port and tag", "localhost:5000/image:dev", Reference{Name: "localhost:5000/image", Tag: "dev"}),
+		)
+
+		DescribeTable("Rejects invalid references",
+			func(s string, errMsg string) {
+				_, err := ParseReference(s)
+				Expect(err).To(MatchError(ContainSubstring(errMsg)))
+			},
+			Entry("empty", "", "must not be empty"),
+			Entry("whitespace", "quay.io/org/image latest", "whitespace"),
+			Entry("short digest", "image@sha256:abc", "invalid digest"),
+			Entry("empty tag", "image:", "empty tag"),
+			Entry("empty name", ":latest", "empty name"),
+		)
+	})
+
+	Context("Tests Resolve()", func() {
+		It("Leaves the image unchanged without a tag", func() {
+			r, err := Resolve("quay.io/org/image:latest", "", "v1.2.3")
+			Expect(err).To(BeNil())
+			Expect(r.String()).To(Equal("quay.io/org/image:latest"))
+		})
+
+		DescribeTable("Applies tags and channels",
+			func(tag, version, expected string) {
+				r, err := Resolve("quay.io/org/image:latest", tag, version)
+				Expect(err).To(BeNil())
+				Expect(r.String()).To(Equal(expected))
+			},
+			Entry("latest", ChannelLatest, "v1.2.3", "quay.io/org/image:latest"),
+			Entry("stable", ChannelStable, "v1.2.3", "quay.io/org/image:stable"),
+			Entry("release", ChannelRelease, "v1.2.3", "quay.io/org/image:v1.2.3"),
+			Entry("release without a v prefix", ChannelRelease, "1.2.3", "quay.io/org/image:v1.2.3"),
+			Entry("literal tag", "nightly-20260101", "v1.2.3", "quay.io/org/image:nightly-20260101"),
+		)
+
+		It("Requires a release version for the release channel", func() {
+			_, err := Resolve("quay.io/org/image", ChannelRelease, unknownVersion)
+			Expect(err).To(MatchError(ContainSubstring("requires a released ocm-container binary")))
+		})
+
+		It("Rejects tags for images pinned to a digest", func() {
+			_, err := Resolve("quay.io/org/image@"+digest, ChannelStable, "v1.2.3")
+			Expect(err).To(MatchError(ContainSubstring("pinned to a digest")))
+		})
+
+		It("Rejects invalid tags", func() {
+			_, err := Resolve("quay.io/org/image", "a/b", "v1.2.3")
+			Expect(err).To(MatchError(ContainSubstring("invalid image tag")))
+		})
+	})
+
+	Context("Tests VerificationPolicy", func() {
+		var args []string
+
+		cosignOutput := func(d string) string {
+			return fmt.Sprintf(`[{"critical":{"identity":{"docker-reference":"quay.io/org/image"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}]`, d)
+		}
+
+		BeforeEach(func() {
+			args = nil
+			DeferCleanup(func(orig func(...string) (string, error)) {
+				runCosign = orig
+			}, runCosign)
+		})
+
+		stubCosign := func(out string, err error) {
+			runCosign = func(a ...string) (string, error) {
+				args = a
+				return out, err
+			}
+		}
+
+		DescribeTable("Validates the policy",
+			func(p VerificationPolicy, errMsg string) {
+				err := p.Validate()
+				if errMsg == "" {
+					Expect(err).To(BeNil())
+					return
+				}
+				Expect(err).To(MatchError(ContainSubstring(errMsg)))
+			},
+			Entry("disabled", VerificationPolicy{}, ""),
+			Entry("key", VerificationPolicy{Policy: PolicyEnforce, Key: "cosign.pub"}, ""),
+			Entry("keyless", VerificationPolicy{Policy: PolicyWarn, CertificateIdentity: "me@example.com", CertificateOidcIssuer: "https://issuer"}, ""),
+			Entry("invalid policy", VerificationPolicy{Policy: "strict", Key: "cosign.pub"}, "invalid image verification policy"),
+			Entry("no key", VerificationPolicy{Policy: PolicyWarn}, "requires a key"),
+			Entry("partial identity", VerificationPolicy{Policy: PolicyWarn, CertificateIdentity: "me@example.com"}, "requires a key"),
+		)
+
+		It("Does nothing when disabled", func() {
+			stubCosign("", fmt.Errorf("should not run"))
+			ref, _ := ParseReference("quay.io/org/image:latest")
+			r, err := VerificationPolicy{}.Verify(ref)
+			Expect(err).To(BeNil())
+			Expect(r).To(Equal(ref))
+			Expect(args).To(BeNil())
+		})
+
+		It("Pins the image to the verified digest", func() {
+			stubCosign(cosignOutput(digest), nil)
+			ref, _ := ParseReference("quay.io/org/image:latest")
+			r, err := VerificationPolicy{Policy: PolicyEnforce, Key: "cosign.pub"}.Verify(ref)
+			Expect(err).To(BeNil())
+			Expect(r.String()).To(Equal("quay.io/org/image:latest@" + digest))
+			Expect(args).To(Equal([]string{"verify", "--output", "json", "--key", "cosign.pub", "quay.io/org/image:latest"}))
+		})
+
+		It("Verifies keyless signatures", func() {
+			stubCosign(cosignOutput(digest), nil)
+			ref, _ := ParseReference("quay.io/org/image")
+			_, err := VerificationPolicy{Policy: PolicyEnforce, CertificateIdentity: "me@example.com", CertificateOidcIssuer: "https://issuer"}.Verify(ref)
+			Expect(err).To(BeNil())
+			Expect(args).To(ContainElements("--certificate-identity", "me@example.com", "--certificate-oidc-issuer", "https://issuer"))
+		})
+
+		It("Fails when enforcing and verification fails", func() {
+			stubCosign("", fmt.Errorf("no matching signatures"))
+			ref, _ := ParseReference("quay.io/org/image")
+			_, err := VerificationPolicy{Policy: PolicyEnforce, Key: "cosign.pub"}.Verify(ref)
+			Expect(err).To(MatchError(ContainSubstring("no matching signatures")))
+		})
+
+		It("Continues unchanged when warning and verification fails", func() {
+			stubCosign("", fmt.Errorf("no matching signatures"))
+			ref, _ := ParseReference("quay.io/org/image")
+			r, err := VerificationPolicy{Policy: PolicyWarn, Key: "cosign.pub"}.Verify(ref)
+			Expect(err).To(BeNil())
+			Expect(r).To(Equal(ref))
+		})
+
+		It("Fails when the signed digest doesn't match the pinned digest", func() {
+			stubCosign(cosignOutput(otherDigest), nil)
+			ref, _ := ParseReference("quay.io/org/image@" + digest)
+			_, err := VerificationPolicy{Policy: PolicyEnforce, Key: "cosign.pub"}.Verify(ref)
+			Expect(err).To(MatchError(ContainSubstring("does not match the pinned digest")))
+		})
+
+		It("Fails when cosign returns no signatures", func() {
+			stubCosign("[]", nil)
+			ref, _ := ParseReference("quay.io/org/image")
+			_, err := VerificationPolicy{Policy: PolicyEnforce, Key: "cosign.pub"}.Verify(ref)
+			Expect(err).To(MatchError(ContainSubstring("no signatures found")))
+		})
+	})
+
+	Context("Tests CheckDrift()", func() {
+		DescribeTable("Compares versions",
+			func(binary, img string, drifted bool) {
+				warning := CheckDrift(binary, img)
+				if drifted {
+					Expect(warning).To(ContainSubstring("different releases"))
+					return
+				}
+				Expect(warning).To(BeEmpty())
+			},
+			Entry("same version", "v1.2.3", "v1.2.3", false),
+			Entry("same minor release", "1.2.0", "v1.2.5", false),
+			Entry("different minor release", "v1.2.3", "v1.4.0", true),
+			Entry("different major release", "v2.0.0", "v1.9.0", true),
+			Entry("unknown binary version", unknownVersion, "v1.4.0", false),
+			Entry("unlabelled image", "v1.2.3", "", false),
+			Entry("non-semver image version", "v1.2.3", "abc1234", false),
+		)
+	})
+})
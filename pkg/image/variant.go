@@ -0,0 +1,111 @@
+package image
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// VariantMicro contains ocm, backplane and oc
+	VariantMicro = "micro"
+	// VariantMinimal adds the SRE backplane tools to the micro image
+	VariantMinimal = "minimal"
+	// VariantFull adds other tools, scripts and environment
+	// configuration to the minimal image
+	VariantFull = "full"
+
+	// VariantLabel is the image label recording the image's variant
+	VariantLabel = "io.openshift.ocm-container.variant"
+
+	// nameLabel is the image name label set by the Makefile, used to
+	// find the variant of images built before VariantLabel was added
+	nameLabel = "name"
+)
+
+// Variants are the supported image variants, smallest first
+var Variants = []string{VariantMicro, VariantMinimal, VariantFull}
+
+// variantImages are the image names of each variant
+var variantImages = map[string]string{
+	VariantMicro:   "ocm-container-micro",
+	VariantMinimal: "ocm-container-minimal",
+	VariantFull:    "ocm-container",
+}
+
+// variantTools are the tools in each variant that features rely on,
+// following the stages of the Containerfile. Each variant contains the
+// tools of the variants before it.
+var variantTools = map[string][]string{
+	VariantMicro: {
+		"dig", "jq", "oc", "ocm", "ocm-backplane",
+	},
+	VariantMinimal: {
+		"aws", "ocm-addons", "osdctl", "rosa", "yq",
+	},
+	VariantFull: {
+		"git", "jira", "oc-nodepp", "omc", "omg", "podman",
+		"rh-aws-saml-login", "session-manager-plugin",
+	},
+}
+
+// VariantTools returns the tools in a variant, or nil if the variant
+// is not known
+func VariantTools(variant string) []string {
+	if _, ok := variantTools[variant]; !ok {
+		return nil
+	}
+	tools := []string{}
+	for _, v := range Variants {
+		tools = append(tools, variantTools[v]...)
+		if v == variant {
+			break
+		}
+	}
+	return tools
+}
+
+// ForVariant returns the reference of the variant's image in the same
+// repository, with the same tag. Only the ocm-container images can be
+// switched to another variant.
+func ForVariant(r Reference, variant string) (Reference, error) {
+	name, ok := variantImages[variant]
+	if !ok {
+		return r, fmt.Errorf("invalid variant %q: must be one of %v", variant, Variants)
+	}
+	if r.Pinned() {
+		return r, fmt.Errorf("image %s is pinned to a digest, and can't be switched to the %s variant", r, variant)
+	}
+
+	dir, base := path.Split(r.Name)
+	if variantFromName(base) == "" {
+		return r, fmt.Errorf("can't select the %s variant of image %s; --variant only applies to the ocm-container images", variant, r)
+	}
+	r.Name = dir + name
+	return r, nil
+}
+
+// VariantOf returns the variant of an ocm-container image from its
+// name, or an empty string for other images
+func VariantOf(r Reference) string {
+	return variantFromName(path.Base(r.Name))
+}
+
+// VariantFromLabels returns the variant of an image from its labels,
+// or an empty string if it is not known
+func VariantFromLabels(labels map[string]string) string {
+	if v := labels[VariantLabel]; v != "" {
+		return v
+	}
+	return variantFromName(path.Base(labels[nameLabel]))
+}
+
+// variantFromName returns the variant of an ocm-container image name
+func variantFromName(name string) string {
+	for v, n := range variantImages {
+		if strings.EqualFold(n, name) {
+			return v
+		}
+	}
+	return ""
+}
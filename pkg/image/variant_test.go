@@ -0,0 +1,80 @@
+package image
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pkg/Image/Variant", func() {
+	Context("Tests VariantTools()", func() {
+		It("Includes the tools of smaller variants", func() {
+			micro := VariantTools(VariantMicro)
+			minimal := VariantTools(VariantMinimal)
+			full := VariantTools(VariantFull)
+			Expect(micro).To(ContainElements("ocm", "oc", "ocm-backplane"))
+			Expect(micro).ToNot(ContainElement("osdctl"))
+			Expect(minimal).To(ContainElements(micro))
+			Expect(minimal).To(ContainElements("osdctl", "aws"))
+			Expect(minimal).ToNot(ContainElement("jira"))
+			Expect(full).To(ContainElements(minimal))
+			Expect(full).To(ContainElements("jira", "podman"))
+		})
+
+		It("Returns nil for unknown variants", func() {
+			Expect(VariantTools("")).To(BeNil())
+			Expect(VariantTools("huge")).To(BeNil())
+		})
+	})
+
+	Context("Tests ForVariant()", func() {
+		DescribeTable("Switches ocm-container images to the variant",
+			func(image, variant, expected string) {
+				r, err := ParseReference(image)
+				Expect(err).To(BeNil())
+				r, err = ForVariant(r, variant)
+				Expect(err).To(BeNil())
+				Expect(r.String()).To(Equal(expected))
+			},
+			Entry("full to micro", "quay.io/redhat-services-prod/openshift/ocm-container:latest", VariantMicro, "quay.io/redhat-services-prod/openshift/ocm-container-micro:latest"),
+			Entry("micro to minimal", "quay.io/org/ocm-container-micro:v1.0.0", VariantMinimal, "quay.io/org/ocm-container-minimal:v1.0.0"),
+			Entry("minimal to full", "ocm-container-minimal", VariantFull, "ocm-container"),
+			Entry("same variant", "quay.io/org/ocm-container:stable", VariantFull, "quay.io/org/ocm-container:stable"),
+		)
+
+		DescribeTable("Rejects images that can't be switched",
+			func(image, variant, errMsg string) {
+				r, err := ParseReference(image)
+				Expect(err).To(BeNil())
+				_, err = ForVariant(r, variant)
+				Expect(err).To(MatchError(ContainSubstring(errMsg)))
+			},
+			Entry("unknown variant", "quay.io/org/ocm-container", "huge", "invalid variant"),
+			Entry("custom image", "quay.io/me/my-tools:latest", VariantMicro, "only applies to the ocm-container images"),
+			Entry("pinned image", "quay.io/org/ocm-container@sha256:"+strings.Repeat("a", 64), VariantMicro, "pinned"),
+		)
+	})
+
+	Context("Tests VariantFromLabels()", func() {
+		DescribeTable("Finds the variant",
+			func(labels map[string]string, expected string) {
+				Expect(VariantFromLabels(labels)).To(Equal(expected))
+			},
+			Entry("variant label", map[string]string{VariantLabel: VariantMinimal, "name": "ocm-container"}, VariantMinimal),
+			Entry("name label", map[string]string{"name": "ocm-container-micro"}, VariantMicro),
+			Entry("name label with a registry", map[string]string{"name": "quay.io/org/ocm-container"}, VariantFull),
+			Entry("other image", map[string]string{"name": "my-tools"}, ""),
+			Entry("no labels", nil, ""),
+		)
+	})
+
+	Context("Tests VariantOf()", func() {
+		It("Finds the variant from the image name", func() {
+			r, _ := ParseReference("quay.io/org/ocm-container-minimal:latest")
+			Expect(VariantOf(r)).To(Equal(VariantMinimal))
+			r, _ = ParseReference("quay.io/me/my-tools:latest")
+			Expect(VariantOf(r)).To(Equal(""))
+		})
+	})
+})
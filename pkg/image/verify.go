@@ -0,0 +1,134 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+
+	"github.com/openshift/ocm-container/pkg/subprocess"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// PolicyWarn verifies signatures and warns if verification fails
+	PolicyWarn = "warn"
+	// PolicyEnforce verifies signatures and refuses to run the image
+	// if verification fails
+	PolicyEnforce = "enforce"
+)
+
+// SupportedPolicies are the values of imageVerification.policy; an
+// empty policy disables verification
+var SupportedPolicies = []string{PolicyWarn, PolicyEnforce}
+
+// runCosign runs the cosign CLI, returning its output. It is a var so
+// tests do not need cosign or a registry.
+var runCosign = func(args ...string) (string, error) {
+	bin, err := exec.LookPath("cosign")
+	if err != nil {
+		return "", fmt.Errorf("cosign not found in $PATH: %v", err)
+	}
+	return subprocess.Run(exec.Command(bin, args...))
+}
+
+// VerificationPolicy configures cosign signature verification of
+// the image
+type VerificationPolicy struct {
+	// Policy is "", warn or enforce
+	Policy string `mapstructure:"policy"`
+	// Key is the path or URL of the public key the image is signed with
+	Key string `mapstructure:"key"`
+	// CertificateIdentity and CertificateOidcIssuer verify keyless
+	// signatures, and are used when Key is not set
+	CertificateIdentity   string `mapstructure:"certificateIdentity"`
+	CertificateOidcIssuer string `mapstructure:"certificateOidcIssuer"`
+}
+
+// Enabled returns true if signatures should be verified
+func (p VerificationPolicy) Enabled() bool {
+	return p.Policy != ""
+}
+
+// Validate checks the policy has a valid mode and a key or identity
+func (p VerificationPolicy) Validate() error {
+	if !p.Enabled() {
+		return nil
+	}
+	if !slices.Contains(SupportedPolicies, p.Policy) {
+		return fmt.Errorf("invalid image verification policy %q: must be one of %v", p.Policy, SupportedPolicies)
+	}
+	if p.Key == "" && (p.CertificateIdentity == "" || p.CertificateOidcIssuer == "") {
+		return fmt.Errorf("image verification requires a key, or a certificateIdentity and certificateOidcIssuer")
+	}
+	return nil
+}
+
+// cosignPayload is an entry in `cosign verify --output json` output
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// Verify checks the image's signature, returning the reference pinned
+// to the verified digest so the image that runs is the one verified.
+// If verification fails with the warn policy, the reference is returned
+// unchanged with a warning.
+func (p VerificationPolicy) Verify(r Reference) (Reference, error) {
+	if !p.Enabled() {
+		return r, nil
+	}
+
+	digest, err := p.verify(r)
+	if err != nil {
+		err = fmt.Errorf("unable to verify the signature of image %s: %v", r, err)
+		if p.Policy == PolicyEnforce {
+			return r, err
+		}
+		log.Warn(err.Error())
+		return r, nil
+	}
+
+	log.Infof("verified the signature of image %s (%s)", r, digest)
+	r.Digest = digest
+	return r, nil
+}
+
+func (p VerificationPolicy) verify(r Reference) (string, error) {
+	args := []string{"verify", "--output", "json"}
+	if p.Key != "" {
+		args = append(args, "--key", p.Key)
+	} else {
+		args = append(args,
+			"--certificate-identity", p.CertificateIdentity,
+			"--certificate-oidc-issuer", p.CertificateOidcIssuer,
+		)
+	}
+	args = append(args, r.String())
+
+	out, err := runCosign(args...)
+	if err != nil {
+		return "", err
+	}
+
+	payloads := []cosignPayload{}
+	err = json.Unmarshal([]byte(out), &payloads)
+	if err != nil {
+		return "", fmt.Errorf("error parsing cosign output: %v", err)
+	}
+	if len(payloads) == 0 {
+		return "", fmt.Errorf("no signatures found")
+	}
+
+	digest := payloads[0].Critical.Image.DockerManifestDigest
+	if !digestRegex.MatchString(digest) {
+		return "", fmt.Errorf("cosign returned an invalid digest %q", digest)
+	}
+	if r.Pinned() && digest != r.Digest {
+		return "", fmt.Errorf("signed digest %s does not match the pinned digest %s", digest, r.Digest)
+	}
+	return digest, nil
+}
@@ -222,22 +222,8 @@ func (f *TextFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 }
 
 func (f *TextFormatter) printColored(b *bytes.Buffer, entry *logrus.Entry, keys []string, timestampFormat string, colorScheme *compiledColorScheme) {
-	var levelColor func(string) string
 	var levelText string
-	switch entry.Level {
-	case logrus.InfoLevel:
-		levelColor = colorScheme.InfoLevelColor
-	case logrus.WarnLevel:
-		levelColor = colorScheme.WarnLevelColor
-	case logrus.ErrorLevel:
-		levelColor = colorScheme.ErrorLevelColor
-	case logrus.FatalLevel:
-		levelColor = colorScheme.FatalLevelColor
-	case logrus.PanicLevel:
-		levelColor = colorScheme.PanicLevelColor
-	default:
-		levelColor = colorScheme.DebugLevelColor
-	}
+	levelColor := colorScheme.levelColor(entry.Level)
 
 	if entry.Level != logrus.WarnLevel {
 		levelText = entry.Level.String()
@@ -287,6 +273,40 @@ func (f *TextFormatter) printColored(b *bytes.Buffer, entry *logrus.Entry, keys
 	}
 }
 
+// levelColor returns the function coloring text for a log level
+func (c *compiledColorScheme) levelColor(level logrus.Level) func(string) string {
+	switch level {
+	case logrus.InfoLevel:
+		return c.InfoLevelColor
+	case logrus.WarnLevel:
+		return c.WarnLevelColor
+	case logrus.ErrorLevel:
+		return c.ErrorLevelColor
+	case logrus.FatalLevel:
+		return c.FatalLevelColor
+	case logrus.PanicLevel:
+		return c.PanicLevelColor
+	default:
+		return c.DebugLevelColor
+	}
+}
+
+// LevelColor returns a function coloring text in the color the logger
+// uses for a level, so output printed outside the log, eg: the cluster
+// banner, matches it. The text is left unchanged if the logger's output
+// is not colored.
+func LevelColor(level logrus.Level) func(string) string {
+	logger := logrus.StandardLogger()
+	f, ok := logger.Formatter.(*TextFormatter)
+	if !ok || f.DisableColors || !(f.ForceColors || f.checkIfTerminal(logger.Out)) {
+		return noColorsColorScheme.levelColor(level)
+	}
+	if f.colorScheme != nil {
+		return f.colorScheme.levelColor(level)
+	}
+	return defaultCompiledColorScheme.levelColor(level)
+}
+
 func (f *TextFormatter) needsQuoting(text string) bool {
 	if f.QuoteEmptyFields && len(text) == 0 {
 		return true
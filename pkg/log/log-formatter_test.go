@@ -401,4 +401,33 @@ var _ = Describe("Pkg/Log/LogFormatter", func() {
 			})
 		})
 	})
+
+	Describe("LevelColor", func() {
+		var original logrus.Formatter
+
+		BeforeEach(func() {
+			original = logrus.StandardLogger().Formatter
+		})
+
+		AfterEach(func() {
+			logrus.SetFormatter(original)
+		})
+
+		It("Colors text when colors are forced", func() {
+			logrus.SetFormatter(&log.TextFormatter{ForceColors: true})
+			colored := log.LevelColor(logrus.WarnLevel)("limited support")
+			Expect(colored).To(ContainSubstring("limited support"))
+			Expect(colored).To(ContainSubstring("\x1b["))
+		})
+
+		It("Leaves text unchanged when colors are disabled", func() {
+			logrus.SetFormatter(&log.TextFormatter{ForceColors: true, DisableColors: true})
+			Expect(log.LevelColor(logrus.ErrorLevel)("error")).To(Equal("error"))
+		})
+
+		It("Leaves text unchanged for other formatters", func() {
+			logrus.SetFormatter(&logrus.JSONFormatter{})
+			Expect(log.LevelColor(logrus.InfoLevel)("ready")).To(Equal("ready"))
+		})
+	})
 })
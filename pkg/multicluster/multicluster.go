@@ -0,0 +1,282 @@
+package multicluster
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openshift/ocm-container/pkg/subprocess"
+)
+
+// The multicluster package launches one ocm-container session per
+// cluster when several clusters are passed with --cluster-id or
+// --clusters-from-file. Each session is a separate ocm-container
+// process for a single cluster, so it has its own cluster environment,
+// persistent history, workspace and ports. The sessions run in tmux on
+// the host, which must be installed there, either as panes of one tmux
+// session or as separate named tmux sessions.
+//
+// tmux is not run in a wrapper container: each session's ocm-container
+// process has to run on the host, since it drives the container engine
+// and serves host-side features, eg: the browser bridge and token relay,
+// for the length of its session.
+
+const (
+	// ModePanes opens every cluster in a pane of one tmux session
+	ModePanes = "panes"
+	// ModeSessions starts a named tmux session for each cluster
+	ModeSessions = "sessions"
+
+	sessionPrefix = "ocm-container"
+)
+
+// Modes are the supported values of --multi-cluster-mode
+var Modes = []string{ModePanes, ModeSessions}
+
+// ValidateMode returns an error if the mode is not one of the Modes
+func ValidateMode(mode string) error {
+	if !slices.Contains(Modes, mode) {
+		return fmt.Errorf("invalid multi-cluster mode %q: must be one of %s", mode, strings.Join(Modes, ", "))
+	}
+	return nil
+}
+
+// clusterFlags select clusters, and are replaced with a single
+// --cluster-id in the arguments of each cluster's session
+var clusterFlags = []string{"--cluster-id", "-C", "--clusters-from-file", "--multi-cluster-mode"}
+
+// invalidSessionChars are not allowed in tmux session names
+var invalidSessionChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// ParseClusters returns the clusters from the --cluster-id flag, which
+// may be a comma-separated list, followed by those in the file, if any.
+// The file lists a cluster per line; blank lines and lines starting with
+// # are ignored. Duplicates are removed.
+func ParseClusters(flag, file string) ([]string, error) {
+	clusters := []string{}
+	add := func(s string) {
+		for _, c := range strings.Split(s, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" && !slices.Contains(clusters, c) {
+				clusters = append(clusters, c)
+			}
+		}
+	}
+
+	add(flag)
+	if file == "" {
+		return clusters, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading clusters file: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(line)
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no clusters found in %s", file)
+	}
+	return clusters, nil
+}
+
+// TakesValue returns true if a flag, eg: --cluster-id or -C, takes a
+// value, so the argument after it is its value rather than a flag
+type TakesValue func(flag string) bool
+
+// SessionArgs returns the arguments for a cluster's session: the
+// arguments ocm-container was run with, with the cluster flags replaced
+// by --cluster-id for the cluster, and the command to run, if any
+func SessionArgs(args []string, takesValue TakesValue, cluster string, command []string) []string {
+	sessionArgs := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, _, hasValue := strings.Cut(arg, "=")
+		valueFollows := !hasValue && strings.HasPrefix(arg, "-") && takesValue(name)
+		switch {
+		case slices.Contains(clusterFlags, name):
+		case strings.HasPrefix(arg, "-C") && !strings.HasPrefix(arg, "--"):
+			// -Cvalue
+			valueFollows = false
+		default:
+			sessionArgs = append(sessionArgs, arg)
+			if valueFollows && i+1 < len(args) {
+				sessionArgs = append(sessionArgs, args[i+1])
+			}
+		}
+		if valueFollows {
+			i++
+		}
+	}
+
+	sessionArgs = append(sessionArgs, "--cluster-id", cluster)
+	if len(command) > 0 {
+		sessionArgs = append(append(sessionArgs, "--"), command...)
+	}
+	return sessionArgs
+}
+
+// Launcher starts the sessions in tmux
+type Launcher struct {
+	// Binary is the ocm-container binary to run for each cluster
+	Binary string
+	// TakesValue identifies the flags in the arguments taking values
+	TakesValue TakesValue
+	// Name is the tmux session name in panes mode
+	Name string
+	// InTmux is true if ocm-container is running inside tmux, so the
+	// client is switched to the new session rather than attached
+	InTmux bool
+
+	tmux   string
+	run    func(*exec.Cmd) (string, error)
+	attach func(*exec.Cmd) error
+	out    io.Writer
+}
+
+// NewLauncher returns a Launcher running the current binary
+func NewLauncher(takesValue TakesValue) (*Launcher, error) {
+	tmux, err := exec.LookPath("tmux")
+	if err != nil {
+		return nil, fmt.Errorf("sessions for multiple clusters run in tmux on the host, which was not found in $PATH: %v", err)
+	}
+	binary, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return &Launcher{
+		Binary:     binary,
+		TakesValue: takesValue,
+		Name:       fmt.Sprintf("%s-%d", sessionPrefix, os.Getpid()),
+		InTmux:     os.Getenv("TMUX") != "",
+		tmux:       tmux,
+		run:        subprocess.Run,
+		attach:     subprocess.RunAttached,
+		out:        os.Stdout,
+	}, nil
+}
+
+// Launch starts a session for each cluster, with the arguments
+// ocm-container was run with and the command to run, if any
+func (l *Launcher) Launch(mode string, clusters []string, args, command []string) error {
+	err := ValidateMode(mode)
+	if err != nil {
+		return err
+	}
+	if mode == ModeSessions {
+		return l.launchSessions(clusters, args, command)
+	}
+	return l.launchPanes(clusters, args, command)
+}
+
+// launchPanes opens the clusters in tiled panes of one tmux session,
+// and attaches to it. The window and panes are targeted by name and
+// pane ID, rather than by index, which depends on the user's
+// base-index and pane-base-index.
+func (l *Launcher) launchPanes(clusters []string, args, command []string) error {
+	window := l.Name + ":" + sessionPrefix
+	for i, cluster := range clusters {
+		cmd := l.sessionCommand(cluster, args, command)
+		var pane string
+		var err error
+		if i == 0 {
+			pane, err = l.tmuxOutput("new-session", "-d", "-P", "-F", "#{pane_id}", "-s", l.Name, "-n", sessionPrefix, cmd)
+		} else {
+			pane, err = l.tmuxOutput("split-window", "-P", "-F", "#{pane_id}", "-t", window, cmd)
+		}
+		if err != nil {
+			return fmt.Errorf("error opening a tmux pane for cluster %s: %v", cluster, err)
+		}
+
+		// Title the pane with its cluster, and re-tile so there is
+		// room for the next pane
+		err = l.tmuxCmd("select-pane", "-t", pane, "-T", cluster)
+		if err != nil {
+			return err
+		}
+		err = l.tmuxCmd("select-layout", "-t", window, "tiled")
+		if err != nil {
+			return err
+		}
+	}
+
+	err := l.tmuxCmd("set-option", "-t", l.Name, "pane-border-status", "top")
+	if err != nil {
+		return err
+	}
+
+	if l.InTmux {
+		return l.attach(exec.Command(l.tmux, "switch-client", "-t", l.Name))
+	}
+	return l.attach(exec.Command(l.tmux, "attach-session", "-t", l.Name))
+}
+
+// launchSessions starts a detached tmux session named after each
+// cluster, and prints how to attach to them
+func (l *Launcher) launchSessions(clusters []string, args, command []string) error {
+	names := []string{}
+	for _, cluster := range clusters {
+		name := SessionName(cluster)
+		err := l.tmuxCmd("new-session", "-d", "-s", name, "-n", cluster, l.sessionCommand(cluster, args, command))
+		if err != nil {
+			return fmt.Errorf("error starting a tmux session for cluster %s: %v", cluster, err)
+		}
+		names = append(names, name)
+	}
+
+	fmt.Fprintf(l.out, "Started %d sessions; attach to them with:\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(l.out, "  tmux attach-session -t %s\n", name)
+	}
+	return nil
+}
+
+// SessionName returns the tmux session name for a cluster in
+// sessions mode
+func SessionName(cluster string) string {
+	return sessionPrefix + "-" + invalidSessionChars.ReplaceAllString(cluster, "_")
+}
+
+// sessionCommand returns the shell command running a cluster's session.
+// If the session fails, the pane is kept open until enter is pressed,
+// so the error can be read.
+func (l *Launcher) sessionCommand(cluster string, args, command []string) string {
+	words := append([]string{l.Binary}, SessionArgs(args, l.TakesValue, cluster, command)...)
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ") +
+		` || { status=$?; echo; echo "ocm-container exited with status $status; press enter to close"; read -r _; }`
+}
+
+func (l *Launcher) tmuxCmd(args ...string) error {
+	_, err := l.tmuxOutput(args...)
+	return err
+}
+
+// tmuxOutput runs tmux and returns its output, eg: the ID of a pane
+// printed with -P
+func (l *Launcher) tmuxOutput(args ...string) (string, error) {
+	out, err := l.run(exec.Command(l.tmux, args...))
+	return strings.TrimSpace(out), err
+}
+
+// shellQuote quotes a word for sh
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,173 @@
+package multicluster
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var takesValue = func(flag string) bool {
+	switch flag {
+	case "--cluster-id", "-C", "--clusters-from-file", "--multi-cluster-mode", "--engine", "-e":
+		return true
+	}
+	return false
+}
+
+var _ = Describe("Pkg/Multicluster", func() {
+	Context("Tests ParseClusters()", func() {
+		It("Splits a comma-separated list", func() {
+			clusters, err := ParseClusters("a, b,,c", "")
+			Expect(err).To(BeNil())
+			Expect(clusters).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("Returns no clusters for an empty flag", func() {
+			clusters, err := ParseClusters("", "")
+			Expect(err).To(BeNil())
+			Expect(clusters).To(BeEmpty())
+		})
+
+		It("Reads clusters from a file, skipping comments and duplicates", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "clusters")
+			Expect(os.WriteFile(file, []byte("# staging\nb\n\n  c  \na\n"), 0600)).To(Succeed())
+			clusters, err := ParseClusters("a,b", file)
+			Expect(err).To(BeNil())
+			Expect(clusters).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("Errors for a file without clusters", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "clusters")
+			Expect(os.WriteFile(file, []byte("# nothing\n"), 0600)).To(Succeed())
+			_, err := ParseClusters("", file)
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("Errors for a missing file", func() {
+			_, err := ParseClusters("", "/nonexistent/clusters")
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	Context("Tests ValidateMode()", func() {
+		It("Accepts the supported modes", func() {
+			for _, mode := range Modes {
+				Expect(ValidateMode(mode)).To(Succeed())
+			}
+		})
+
+		It("Errors for an unknown mode", func() {
+			Expect(ValidateMode("windows")).To(MatchError(ContainSubstring("must be one of panes, sessions")))
+		})
+	})
+
+	Context("Tests SessionArgs()", func() {
+		DescribeTable("Replaces the cluster flags with the session's cluster",
+			func(args, command, expected []string) {
+				Expect(SessionArgs(args, takesValue, "c1", command)).To(Equal(expected))
+			},
+			Entry("long flag", []string{"--cluster-id", "a,b", "--engine", "docker"}, nil,
+				[]string{"--engine", "docker", "--cluster-id", "c1"}),
+			Entry("long flag with =", []string{"--cluster-id=a,b", "--no-login"}, nil,
+				[]string{"--no-login", "--cluster-id", "c1"}),
+			Entry("short flag", []string{"-e", "docker", "-C", "a,b"}, nil,
+				[]string{"-e", "docker", "--cluster-id", "c1"}),
+			Entry("short flag with attached value", []string{"-Ca,b", "--no-login"}, nil,
+				[]string{"--no-login", "--cluster-id", "c1"}),
+			Entry("clusters file and mode", []string{"--clusters-from-file", "clusters", "--multi-cluster-mode", "sessions"}, nil,
+				[]string{"--cluster-id", "c1"}),
+			Entry("values that look like cluster flags", []string{"--engine", "-C"}, nil,
+				[]string{"--engine", "-C", "--cluster-id", "c1"}),
+			Entry("with a command", []string{"-C", "a,b"}, []string{"oc", "get", "nodes"},
+				[]string{"--cluster-id", "c1", "--", "oc", "get", "nodes"}),
+		)
+	})
+
+	Context("Tests Launcher", func() {
+		var (
+			l    *Launcher
+			ran  [][]string
+			out  *bytes.Buffer
+			args = []string{"-C", "a,b"}
+		)
+
+		BeforeEach(func() {
+			ran = [][]string{}
+			out = &bytes.Buffer{}
+			record := func(c *exec.Cmd) error {
+				ran = append(ran, c.Args[1:])
+				return nil
+			}
+			// A fake tmux, printing the ID of each new pane when asked
+			// to with -P, as tmux does
+			run := func(c *exec.Cmd) (string, error) {
+				_ = record(c)
+				if slices.Contains(c.Args, "-P") {
+					return fmt.Sprintf("%%%d\n", len(ran)), nil
+				}
+				return "", nil
+			}
+			l = &Launcher{
+				Binary:     "/usr/bin/ocm-container",
+				TakesValue: takesValue,
+				Name:       "ocm-container-1",
+				tmux:       "tmux",
+				run:        run,
+				attach:     record,
+				out:        out,
+			}
+		})
+
+		It("Opens a pane for each cluster and attaches", func() {
+			Expect(l.Launch(ModePanes, []string{"a", "b"}, args, nil)).To(Succeed())
+			Expect(ran).To(HaveLen(8))
+			Expect(ran[0][:9]).To(Equal([]string{"new-session", "-d", "-P", "-F", "#{pane_id}", "-s", "ocm-container-1", "-n", "ocm-container"}))
+			Expect(ran[0][9]).To(HavePrefix("/usr/bin/ocm-container --cluster-id a ||"))
+			Expect(ran[1]).To(Equal([]string{"select-pane", "-t", "%1", "-T", "a"}))
+			Expect(ran[2]).To(Equal([]string{"select-layout", "-t", "ocm-container-1:ocm-container", "tiled"}))
+			Expect(ran[3][:6]).To(Equal([]string{"split-window", "-P", "-F", "#{pane_id}", "-t", "ocm-container-1:ocm-container"}))
+			Expect(ran[3][6]).To(HavePrefix("/usr/bin/ocm-container --cluster-id b ||"))
+			Expect(ran[4]).To(Equal([]string{"select-pane", "-t", "%4", "-T", "b"}))
+			Expect(ran[5]).To(Equal([]string{"select-layout", "-t", "ocm-container-1:ocm-container", "tiled"}))
+			Expect(ran[7]).To(Equal([]string{"attach-session", "-t", "ocm-container-1"}))
+		})
+
+		It("Switches the client when already in tmux", func() {
+			l.InTmux = true
+			Expect(l.Launch(ModePanes, []string{"a", "b"}, args, nil)).To(Succeed())
+			Expect(ran[len(ran)-1]).To(Equal([]string{"switch-client", "-t", "ocm-container-1"}))
+		})
+
+		It("Starts a named session for each cluster", func() {
+			Expect(l.Launch(ModeSessions, []string{"a", "b.c"}, args, []string{"oc", "whoami"})).To(Succeed())
+			Expect(ran).To(HaveLen(2))
+			Expect(ran[0][:6]).To(Equal([]string{"new-session", "-d", "-s", "ocm-container-a", "-n", "a"}))
+			Expect(ran[0][6]).To(HavePrefix("/usr/bin/ocm-container --cluster-id a -- oc whoami ||"))
+			Expect(ran[1][:6]).To(Equal([]string{"new-session", "-d", "-s", "ocm-container-b_c", "-n", "b.c"}))
+			Expect(out.String()).To(ContainSubstring("tmux attach-session -t ocm-container-b_c"))
+		})
+
+		It("Errors for an unknown mode", func() {
+			Expect(l.Launch("windows", []string{"a", "b"}, args, nil)).ToNot(Succeed())
+			Expect(ran).To(BeEmpty())
+		})
+	})
+
+	Context("Tests shellQuote()", func() {
+		DescribeTable("Quotes words for sh",
+			func(word, expected string) {
+				Expect(shellQuote(word)).To(Equal(expected))
+			},
+			Entry("plain", "--cluster-id", "--cluster-id"),
+			Entry("empty", "", "''"),
+			Entry("spaces", "echo hi", "'echo hi'"),
+			Entry("single quote", "it's", `'it'\''s'`),
+		)
+	})
+})
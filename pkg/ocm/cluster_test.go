@@ -0,0 +1,171 @@
+package ocm
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift/ocm-container/pkg/ocm/ocmtest"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/OCM/Cluster", func() {
+	var (
+		fixture ocmtest.Fixture
+		server  *ocmtest.Server
+	)
+
+	BeforeEach(func() {
+		viper.Reset()
+		client = nil
+		var err error
+		fixture, err = ocmtest.LoadFixture("clusters")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	// login starts the fake OCM with the fixture, and logs into it
+	login := func() {
+		server = ocmtest.NewServer(fixture)
+		DeferCleanup(server.Close)
+		restore, err := server.Arm(GinkgoT().TempDir())
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(restore)
+
+		c, err := New()
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(c.Cleanup)
+		DeferCleanup(CloseClient)
+	}
+
+	Context("New()", func() {
+		It("Connects with the pre-armed token, and saves a copy for the container", func() {
+			server = ocmtest.NewServer(fixture)
+			DeferCleanup(server.Close)
+			restore, err := server.Arm(GinkgoT().TempDir())
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(restore)
+
+			c, err := New()
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(CloseClient)
+			Expect(GetClient()).ToNot(BeNil())
+			Expect(GetClient().URL()).To(Equal(server.URL))
+
+			sessionConfig := c.Env["OCMC_EXTERNAL_OCM_CONFIG"]
+			Expect(sessionConfig).To(BeAnExistingFile())
+			c.Cleanup()
+			_, err = os.Stat(sessionConfig)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Context("GetCluster()", func() {
+		DescribeTable("finds a cluster by any of its identifiers",
+			func(key, id string) {
+				login()
+				cluster, err := GetCluster(GetClient(), key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cluster.ID()).To(Equal(id))
+			},
+			Entry("name", "classic-cluster", "1classic"),
+			Entry("internal ID", "1classic", "1classic"),
+			Entry("external ID", "a1b2c3d4-0000-0000-0000-classic00001", "1classic"),
+			Entry("HyperShift cluster", "hcp-cluster", "2hcp"),
+			Entry("cluster without a subscription", "no-metrics-cluster", "3nometrics"),
+			Entry("external ID of a cluster without a subscription", "a1b2c3d4-0000-0000-0000-nometrics001", "3nometrics"),
+		)
+
+		It("Looks up the cluster of a subscription", func() {
+			login()
+			_, err := GetCluster(GetClient(), "hcp-cluster")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Requests()).To(Equal([]string{
+				"/api/accounts_mgmt/v1/subscriptions?search=(display_name = 'hcp-cluster' or cluster_id = 'hcp-cluster' or external_cluster_id = 'hcp-cluster') and status in ('Reserved', 'Active')",
+				"/api/clusters_mgmt/v1/clusters/2hcp",
+			}))
+		})
+
+		It("Returns HyperShift clusters", func() {
+			login()
+			cluster, err := GetCluster(GetClient(), "hcp-cluster")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cluster.Hypershift().Enabled()).To(BeTrue())
+			Expect(cluster.Product().ID()).To(Equal("rosa"))
+		})
+
+		It("Caches clusters", func() {
+			login()
+			_, err := GetCluster(GetClient(), "classic-cluster")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = GetCluster(GetClient(), "classic-cluster")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Requests()).To(HaveLen(2))
+		})
+
+		It("Returns an error for names of several subscriptions", func() {
+			login()
+			_, err := GetCluster(GetClient(), "duplicate")
+			Expect(err).To(MatchError("there are 2 subscriptions with cluster identifier or name 'duplicate'"))
+		})
+
+		It("Returns an error for names of several clusters", func() {
+			login()
+			_, err := GetCluster(GetClient(), "twin")
+			Expect(err).To(MatchError("there are 2 clusters with identifier or name 'twin'"))
+		})
+
+		It("Ignores deprovisioned subscriptions", func() {
+			login()
+			_, err := GetCluster(GetClient(), "deprovisioned-cluster")
+			Expect(err).To(MatchError("there are no subscriptions or clusters with identifier or name 'deprovisioned-cluster'"))
+		})
+
+		It("Returns an error without permission to search subscriptions", func() {
+			fixture.Errors["/api/accounts_mgmt/v1/subscriptions"] = ocmtest.Error{Status: http.StatusForbidden, Reason: "Forbidden"}
+			login()
+			_, err := GetCluster(GetClient(), "classic-cluster")
+			Expect(err).To(MatchError(ContainSubstring("can't retrieve subscription for key 'classic-cluster'")))
+			Expect(err).To(MatchError(ContainSubstring("403")))
+		})
+
+		It("Returns an error without permission to get the cluster", func() {
+			fixture.Errors["/api/clusters_mgmt/v1/clusters/1classic"] = ocmtest.Error{Status: http.StatusForbidden, Reason: "Forbidden"}
+			login()
+			_, err := GetCluster(GetClient(), "classic-cluster")
+			Expect(err).To(MatchError(ContainSubstring("can't retrieve cluster for key 'classic-cluster'")))
+		})
+	})
+
+	Context("GetClusterId()", func() {
+		It("Returns the internal ID of a cluster", func() {
+			login()
+			Expect(GetClusterId(GetClient(), "a1b2c3d4-0000-0000-0000-hcp000000001")).To(Equal("2hcp"))
+		})
+	})
+
+	Context("SearchClusters()", func() {
+		It("Returns the clusters matching the search", func() {
+			login()
+			clusters, err := SearchClusters(GetClient(), "product.id = 'rosa' and state = 'ready'")
+			Expect(err).ToNot(HaveOccurred())
+			ids := []string{}
+			for _, c := range clusters {
+				ids = append(ids, c.ID())
+			}
+			Expect(ids).To(Equal([]string{"2hcp", "4dup1", "4dup2"}))
+		})
+
+		It("Pages through the results", func() {
+			for i := range searchPageSize + 1 {
+				fixture.Collections["/api/clusters_mgmt/v1/clusters"] = append(fixture.Collections["/api/clusters_mgmt/v1/clusters"],
+					map[string]any{"id": fmt.Sprintf("many-%d", i), "name": "many", "state": "ready"})
+			}
+			login()
+			clusters, err := SearchClusters(GetClient(), "name = 'many'")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(clusters).To(HaveLen(searchPageSize + 1))
+		})
+	})
+})
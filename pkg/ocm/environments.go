@@ -0,0 +1,135 @@
+package ocm
+
+import (
+	"fmt"
+	neturl "net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	"github.com/spf13/viper"
+)
+
+// EnvironmentsConfigKey is the config key of the user-defined OCM
+// environments, keyed by alias
+const EnvironmentsConfigKey = "ocmUrls"
+
+// unsafeFilenameChars matches anything not safe in a cached config's
+// filename
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Environment is an OCM environment. The built-in environments log in
+// with Red Hat SSO; others, eg: gov or private environments, may have
+// their own token URL and client ID.
+type Environment struct {
+	URL      string `mapstructure:"url"`
+	TokenURL string `mapstructure:"tokenUrl"`
+	ClientID string `mapstructure:"clientId"`
+}
+
+// customAuth returns true if the environment does not log in with the
+// default SSO and client
+func (e Environment) customAuth() bool {
+	return e.TokenURL != "" || e.ClientID != ""
+}
+
+// matches returns true if the OCM config's tokens are for the
+// environment's SSO and client
+func (e Environment) matches(cfg *config.Config) bool {
+	tokenURL := cfg.TokenURL
+	if tokenURL == "" {
+		tokenURL = sdk.DefaultTokenURL
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = ocmContainerClientId
+	}
+	return (e.TokenURL == "" || e.TokenURL == tokenURL) &&
+		(e.ClientID == "" || e.ClientID == clientID)
+}
+
+// environments returns the user-defined environments, keyed by their
+// lowercased alias. An environment may be given as just its URL, eg:
+// `dev: https://api.dev.example.com`, or with its token URL and client
+// ID.
+func environments() (map[string]Environment, error) {
+	envs := map[string]Environment{}
+	err := viper.UnmarshalKey(EnvironmentsConfigKey, &envs, viper.DecodeHook(stringToEnvironment))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s config: %v", EnvironmentsConfigKey, err)
+	}
+	for name, env := range envs {
+		u, err := httpsURL(env.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s.%s url: %v", EnvironmentsConfigKey, name, err)
+		}
+		env.URL = u
+		envs[name] = env
+	}
+	return envs, nil
+}
+
+// stringToEnvironment decodes an environment given as just its URL
+func stringToEnvironment(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(Environment{}) {
+		return data, nil
+	}
+	return Environment{URL: data.(string)}, nil
+}
+
+// resolve returns the environment for an alias or URL. User-defined
+// aliases take precedence over the built-in ones, and a URL of a
+// user-defined environment gets its token URL and client ID.
+func resolve(s string) (Environment, error) {
+	envs, err := environments()
+	if err != nil {
+		return Environment{}, err
+	}
+
+	if env, ok := envs[strings.ToLower(s)]; ok {
+		return env, nil
+	}
+	if u, ok := urlAliases[s]; ok {
+		return Environment{URL: u}, nil
+	}
+
+	u, err := httpsURL(s)
+	if err != nil {
+		return Environment{}, fmt.Errorf("%w: %q is not a known alias (%s) or an https URL", errInvalidOcmUrl, s, strings.Join(SupportedUrls, ", "))
+	}
+	for _, env := range envs {
+		if env.URL == u {
+			return env, nil
+		}
+	}
+	return Environment{URL: u}, nil
+}
+
+// httpsURL validates an https URL, and returns it without a trailing
+// slash
+func httpsURL(s string) (string, error) {
+	u, err := neturl.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("%q is not an https URL", s)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// safeFilename derives a name safe to use in a filename from a URL, eg:
+// https://api.example.com:8443/ocm becomes api.example.com_8443_ocm
+func safeFilename(s string) string {
+	name := s
+	if u, err := neturl.Parse(s); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	name = strings.Trim(unsafeFilenameChars.ReplaceAllString(name, "_"), "_.")
+	if name == "" {
+		return "default"
+	}
+	return name
+}
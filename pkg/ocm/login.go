@@ -0,0 +1,247 @@
+package ocm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	auth "github.com/openshift-online/ocm-sdk-go/authentication"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// TokenFileFlag logs in with the offline or access token in a file
+	TokenFileFlag = "ocm-token-file"
+	// NoBrowserFlag fails instead of opening a browser to log in
+	NoBrowserFlag = "no-browser"
+	// LoginMethodFlag chooses how to log in interactively
+	LoginMethodFlag = "ocm-login-method"
+
+	// LoginBrowser logs in with a browser on this machine
+	LoginBrowser = "browser"
+	// LoginDevice logs in with a code entered in a browser anywhere,
+	// eg: over SSH
+	LoginDevice = "device"
+
+	// ClientIDEnv and ClientSecretEnv hold the credentials of a service
+	// account, to log in with the client credentials grant
+	ClientIDEnv     = "OCMC_OCM_CLIENT_ID"
+	ClientSecretEnv = "OCMC_OCM_CLIENT_SECRET" //nolint:gosec // the name of the variable, not a credential
+)
+
+// LoginMethods are the supported interactive login methods
+var LoginMethods = []string{LoginBrowser, LoginDevice}
+
+// LoginFlags are the flags choosing how to log into OCM, which commands
+// starting other ocm-containers, eg: fanout, pass on to them
+var LoginFlags = []string{TokenFileFlag, LoginMethodFlag, NoBrowserFlag}
+
+// loginOptions are how to log in when the OCM config is not armed, or
+// credentials are passed explicitly
+type loginOptions struct {
+	TokenFile    string
+	ClientID     string
+	ClientSecret string
+	Method       string
+	NoBrowser    bool
+}
+
+// loginOptionsFromViper returns the login options from the flags, config
+// and environment
+func loginOptionsFromViper() loginOptions {
+	return loginOptions{
+		TokenFile:    viper.GetString(TokenFileFlag),
+		ClientID:     os.Getenv(ClientIDEnv),
+		ClientSecret: os.Getenv(ClientSecretEnv),
+		Method:       viper.GetString(LoginMethodFlag),
+		NoBrowser:    viper.GetBool(NoBrowserFlag),
+	}
+}
+
+// explicit returns true if credentials were passed, rather than read
+// from the OCM config
+func (o loginOptions) explicit() bool {
+	return o.TokenFile != "" || o.ClientID != "" || o.ClientSecret != ""
+}
+
+// loginExplicitly logs in with the credentials passed. A token file
+// takes precedence over client credentials.
+func loginExplicitly(ctx context.Context, cfg *config.Config, opts loginOptions) error {
+	if opts.TokenFile != "" {
+		log.Debugf("logging into OCM with the token in %s", opts.TokenFile)
+		token, err := readTokenFile(opts.TokenFile)
+		if err != nil {
+			return err
+		}
+		return setToken(cfg, token)
+	}
+
+	if opts.ClientID == "" || opts.ClientSecret == "" {
+		return fmt.Errorf("both %s and %s must be set to log into OCM with client credentials", ClientIDEnv, ClientSecretEnv)
+	}
+	log.Debugf("logging into OCM with the client credentials of %s", opts.ClientID)
+	return clientCredentialsLogin(ctx, cfg, opts.ClientID, opts.ClientSecret)
+}
+
+// readTokenFile reads an offline or access token from a file
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("can't read the OCM token file: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("the OCM token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// clientCredentialsLogin exchanges a service account's client ID and
+// secret for an access token. The credentials are kept in the config,
+// so the token can be renewed when it expires, but the secret is never
+// saved with its copies; it is read from the environment again instead.
+func clientCredentialsLogin(ctx context.Context, cfg *config.Config, clientID, clientSecret string) error {
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOcmScopes
+	}
+	cc := clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL(cfg),
+		Scopes:       scopes,
+	}
+	token, err := cc.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("error logging into OCM with client credentials: %v", err)
+	}
+
+	cfg.ClientID = clientID
+	cfg.ClientSecret = clientSecret
+	cfg.AccessToken = token.AccessToken
+	cfg.RefreshToken = token.RefreshToken
+	return nil
+}
+
+// interactiveLogin logs in with a browser, or a device code, and returns
+// the offline token
+func interactiveLogin(ctx context.Context, cfg *config.Config, env Environment, opts loginOptions, reason string) (string, error) {
+	clientID := ocmContainerClientId
+	if env.ClientID != "" {
+		clientID = env.ClientID
+	}
+
+	if opts.Method == LoginDevice {
+		return deviceCodeLogin(ctx, tokenURL(cfg), clientID, os.Stderr)
+	}
+
+	if opts.NoBrowser {
+		return "", fmt.Errorf("not logged into OCM (%s), and --%s is set: pass --%s, set %s and %s, log in with --%s=%s, or run `ocm login` first",
+			reason, NoBrowserFlag, TokenFileFlag, ClientIDEnv, ClientSecretEnv, LoginMethodFlag, LoginDevice)
+	}
+	// Browser authentication only supports Red Hat SSO
+	if env.TokenURL != "" && env.TokenURL != sdk.DefaultTokenURL {
+		return "", fmt.Errorf("not logged into %s: log in with --%s=%s, or with `ocm login --url %s --token-url %s`, then run ocm-container again",
+			env.URL, LoginMethodFlag, LoginDevice, env.URL, env.TokenURL)
+	}
+
+	fmt.Fprintln(os.Stderr, "Please complete OCM browser authentication...")
+	token, err := auth.InitiateAuthCode(clientID)
+	if err != nil {
+		return "", fmt.Errorf("error initiating auth code: %s", err)
+	}
+	return token, nil
+}
+
+// deviceCodeLogin logs in with the OAuth device authorization grant: the
+// user enters a code at a URL, in a browser on any machine, while this
+// polls for the token. It returns the offline token.
+func deviceCodeLogin(ctx context.Context, tokenURL, clientID string, out io.Writer) (string, error) {
+	conf := &oauth2.Config{
+		ClientID: clientID,
+		Scopes:   defaultOcmScopes,
+		Endpoint: oauth2.Endpoint{
+			DeviceAuthURL: deviceAuthURL(tokenURL),
+			TokenURL:      tokenURL,
+		},
+	}
+
+	verifier := oauth2.GenerateVerifier()
+	resp, err := conf.DeviceAuth(ctx, oauth2.S256ChallengeOption(verifier))
+	if err != nil {
+		return "", fmt.Errorf("error starting OCM device login: %v", err)
+	}
+
+	if resp.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "To log into OCM, open %s and check the code is %s\n", resp.VerificationURIComplete, resp.UserCode)
+	} else {
+		fmt.Fprintf(out, "To log into OCM, open %s and enter the code %s\n", resp.VerificationURI, resp.UserCode)
+	}
+
+	token, err := conf.DeviceAccessToken(ctx, resp, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return "", fmt.Errorf("error completing OCM device login: %v", err)
+	}
+	if token.RefreshToken == "" {
+		return "", errors.New("error completing OCM device login: no offline token was returned")
+	}
+	return token.RefreshToken, nil
+}
+
+// deviceAuthURL returns the device authorization URL of an SSO, from its
+// token URL, eg: .../openid-connect/token becomes
+// .../openid-connect/auth/device
+func deviceAuthURL(tokenURL string) string {
+	return strings.TrimSuffix(tokenURL, "/token") + "/auth/device"
+}
+
+// tokenURL returns the config's token URL, or the default
+func tokenURL(cfg *config.Config) string {
+	if cfg.TokenURL != "" {
+		return cfg.TokenURL
+	}
+	return sdk.DefaultTokenURL
+}
+
+// setToken sets an offline, refresh or access token in the config,
+// depending on its type
+func setToken(cfg *config.Config, token string) error {
+	if config.IsEncryptedToken(token) {
+		log.Debug("OCM token is encrypted; assuming it is a RefreshToken")
+		cfg.AccessToken = ""
+		cfg.RefreshToken = token
+		return nil
+	}
+
+	log.Debug("OCM token is not encrypted; assuming it is an AccessToken")
+	parsedToken, err := config.ParseToken(token)
+	if err != nil {
+		return fmt.Errorf("error parsing token: %s", err)
+	}
+
+	typ, err := config.TokenType(parsedToken)
+	if err != nil {
+		return fmt.Errorf("error determining token type: %s", err)
+	}
+
+	switch typ {
+	case "Bearer", "":
+		log.Debugf("token type is '%s'; assuming it is an AccessToken", typ)
+		cfg.AccessToken = token
+	case "Refresh", "Offline":
+		log.Debugf("token type is '%s'; assuming it is a RefreshToken", typ)
+		cfg.AccessToken = ""
+		cfg.RefreshToken = token
+	default:
+		return fmt.Errorf("unknown token type: %s", typ)
+	}
+	return nil
+}
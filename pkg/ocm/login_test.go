@@ -0,0 +1,213 @@
+package ocm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	"github.com/openshift/ocm-container/pkg/ocm/ocmtest"
+	"github.com/spf13/viper"
+)
+
+// fakeToken returns an unsigned JWT with the given type
+func fakeToken(typ string) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	claims := enc.EncodeToString([]byte(`{"typ":"` + typ + `"}`))
+	return header + "." + claims + ".sig"
+}
+
+// fakeSSO is a fake token endpoint, accepting the client credentials
+// grant for one client, and the device authorization grant, which is
+// pending until the token has been polled for once
+type fakeSSO struct {
+	*httptest.Server
+	polls atomic.Int32
+}
+
+func newFakeSSO() *fakeSSO {
+	sso := &fakeSSO{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/protocol/openid-connect/auth/device", func(w http.ResponseWriter, r *http.Request) {
+		ocmtest.WriteJSON(w, http.StatusOK, map[string]any{
+			"device_code":      "device-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": "https://sso.example.com/device",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.ParseForm()).To(Succeed())
+		switch r.Form.Get("grant_type") {
+		case "client_credentials":
+			id, secret, ok := r.BasicAuth()
+			if !ok {
+				id, secret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+			}
+			if id != "service-account" || secret != "s3cret" {
+				ocmtest.WriteJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+				return
+			}
+			ocmtest.WriteJSON(w, http.StatusOK, map[string]any{"access_token": "access-token", "token_type": "Bearer", "expires_in": 300})
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if sso.polls.Add(1) == 1 {
+				ocmtest.WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "authorization_pending"})
+				return
+			}
+			ocmtest.WriteJSON(w, http.StatusOK, map[string]any{"access_token": "access-token", "refresh_token": fakeToken("Offline"), "token_type": "Bearer"})
+		default:
+			ocmtest.WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "unsupported_grant_type"})
+		}
+	})
+	sso.Server = httptest.NewServer(mux)
+	return sso
+}
+
+func (s *fakeSSO) tokenURL() string {
+	return s.URL + "/protocol/openid-connect/token"
+}
+
+var _ = Describe("Pkg/OCM/Login", func() {
+	var (
+		sso *fakeSSO
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		viper.Reset()
+		sso = newFakeSSO()
+		DeferCleanup(sso.Close)
+		ctx = context.Background()
+	})
+
+	Context("clientCredentialsLogin()", func() {
+		It("Exchanges the client credentials for an access token", func() {
+			cfg := &config.Config{TokenURL: sso.tokenURL()}
+			err := clientCredentialsLogin(ctx, cfg, "service-account", "s3cret")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.AccessToken).To(Equal("access-token"))
+			Expect(cfg.ClientID).To(Equal("service-account"))
+			Expect(cfg.ClientSecret).To(Equal("s3cret"))
+		})
+
+		It("Returns an error for invalid credentials", func() {
+			cfg := &config.Config{TokenURL: sso.tokenURL()}
+			err := clientCredentialsLogin(ctx, cfg, "service-account", "wrong")
+			Expect(err).To(MatchError(ContainSubstring("error logging into OCM with client credentials")))
+			Expect(cfg.AccessToken).To(BeEmpty())
+		})
+	})
+
+	Context("deviceCodeLogin()", func() {
+		It("Prints the code, and polls until the login is completed", func() {
+			out := &bytes.Buffer{}
+			token, err := deviceCodeLogin(ctx, sso.tokenURL(), "ocm-cli", out)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token).To(Equal(fakeToken("Offline")))
+			Expect(out.String()).To(ContainSubstring("https://sso.example.com/device"))
+			Expect(out.String()).To(ContainSubstring("ABCD-EFGH"))
+			Expect(sso.polls.Load()).To(BeEquivalentTo(2))
+		})
+
+		It("Derives the device authorization URL from the token URL", func() {
+			Expect(deviceAuthURL("https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token")).
+				To(Equal("https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/auth/device"))
+		})
+	})
+
+	Context("readTokenFile()", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		It("Returns the token without surrounding whitespace", func() {
+			path := filepath.Join(dir, "token")
+			Expect(os.WriteFile(path, []byte("  my-token\n"), 0600)).To(Succeed())
+			Expect(readTokenFile(path)).To(Equal("my-token"))
+		})
+
+		It("Returns an error for an empty file", func() {
+			path := filepath.Join(dir, "token")
+			Expect(os.WriteFile(path, []byte("\n"), 0600)).To(Succeed())
+			_, err := readTokenFile(path)
+			Expect(err).To(MatchError(ContainSubstring("is empty")))
+		})
+
+		It("Returns an error for a missing file", func() {
+			_, err := readTokenFile(filepath.Join(dir, "missing"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("ensureArmed()", func() {
+		It("Uses the token file instead of the config's tokens", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(path, []byte(fakeToken("Offline")), 0600)).To(Succeed())
+
+			cfg := &config.Config{AccessToken: "old"}
+			err := ensureArmed(cfg, Environment{}, loginOptions{TokenFile: path})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.AccessToken).To(BeEmpty())
+			Expect(cfg.RefreshToken).To(Equal(fakeToken("Offline")))
+		})
+
+		It("Uses client credentials", func() {
+			cfg := &config.Config{TokenURL: sso.tokenURL()}
+			err := ensureArmed(cfg, Environment{}, loginOptions{ClientID: "service-account", ClientSecret: "s3cret"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.AccessToken).To(Equal("access-token"))
+		})
+
+		It("Requires both the client ID and secret", func() {
+			err := ensureArmed(&config.Config{}, Environment{}, loginOptions{ClientID: "service-account"})
+			Expect(err).To(MatchError(ContainSubstring(ClientSecretEnv)))
+		})
+
+		It("Logs in with a device code", func() {
+			cfg := &config.Config{URL: productionURL, TokenURL: sso.tokenURL()}
+			err := ensureArmed(cfg, Environment{}, loginOptions{Method: LoginDevice, NoBrowser: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.RefreshToken).To(Equal(fakeToken("Offline")))
+		})
+
+		It("Fails fast with --no-browser when not logged in", func() {
+			cfg := &config.Config{URL: productionURL, TokenURL: sso.tokenURL()}
+			err := ensureArmed(cfg, Environment{}, loginOptions{Method: LoginBrowser, NoBrowser: true})
+			Expect(err).To(MatchError(ContainSubstring("--no-browser is set")))
+			Expect(err).To(MatchError(ContainSubstring("--ocm-token-file")))
+			Expect(sso.polls.Load()).To(BeZero())
+		})
+	})
+
+	Context("setToken()", func() {
+		It("Sets access tokens", func() {
+			cfg := &config.Config{}
+			Expect(setToken(cfg, fakeToken("Bearer"))).To(Succeed())
+			Expect(cfg.AccessToken).To(Equal(fakeToken("Bearer")))
+		})
+
+		It("Sets refresh and offline tokens", func() {
+			for _, typ := range []string{"Refresh", "Offline"} {
+				cfg := &config.Config{AccessToken: "old"}
+				Expect(setToken(cfg, fakeToken(typ))).To(Succeed())
+				Expect(cfg.AccessToken).To(BeEmpty())
+				Expect(cfg.RefreshToken).To(Equal(fakeToken(typ)))
+			}
+		})
+
+		It("Returns an error for tokens which are not JWTs", func() {
+			Expect(setToken(&config.Config{}, "not-a-token")).ToNot(Succeed())
+		})
+	})
+})
@@ -1,15 +1,16 @@
 package ocm
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/openshift-online/ocm-common/pkg/ocm/config"
 	"github.com/openshift-online/ocm-common/pkg/ocm/connection-builder"
 	sdk "github.com/openshift-online/ocm-sdk-go"
-	auth "github.com/openshift-online/ocm-sdk-go/authentication"
 	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
 	"github.com/openshift/ocm-container/pkg/utils"
 	log "github.com/sirupsen/logrus"
@@ -23,6 +24,10 @@ const (
 	productionGovURL = "https://api.openshiftusgov.com"
 
 	ocmContainerClientId = "ocm-cli"
+
+	// searchPageSize is the number of clusters fetched per request when
+	// searching
+	searchPageSize = 100
 )
 
 // SupportedUrls is a shortened list of the urlAliases, for the help message
@@ -68,15 +73,28 @@ type Error string
 func (e Error) Error() string { return string(e) }
 
 const (
-	errInvalidOcmUrl = Error("the specified ocm-url is invalid: %s")
+	errInvalidOcmUrl = Error("the specified ocm-url is invalid")
 )
 
 type Config struct {
 	Env map[string]string
+	// CleanupFuncs are run when the session ends
+	CleanupFuncs []func()
+}
+
+// Cleanup runs the CleanupFuncs, for callers which only use the
+// connection, without starting a session
+func (c *Config) Cleanup() {
+	for _, f := range c.CleanupFuncs {
+		f()
+	}
 }
 
 var client *sdk.Connection
 
+// sessionConfig is the OCM config copied into the container
+var sessionConfig *config.Config
+
 var clusterCache map[string]*cmv1.Cluster
 
 
@@ -91,14 +109,42 @@ func New() (*Config, error) {
 	// don't want to save anything.
 	saveOriginalConfig := true
 
-	ocmConfig, err := config.Load()
+	env, err := resolve(viper.GetString("ocm-url"))
 	if err != nil {
 		return c, err
 	}
 
+	ocmConfig, err := loadConfig()
+	if err != nil {
+		return c, err
+	}
+
+	opts := loginOptionsFromViper()
+	switch {
+	case opts.explicit():
+		// Credentials passed explicitly are only used for this
+		// session, and never saved into the OCM config
+		saveOriginalConfig = false
+		ocmConfig = new(config.Config)
+	case ocmConfig != nil && !env.matches(ocmConfig):
+		// The OCM config's tokens are no use for an environment with
+		// its own SSO or client, so use the copy saved for the
+		// environment by an earlier session instead, and leave the OCM
+		// config alone.
+		log.Debugf("OCM config is not for the %s SSO; using the ocm-container config for the environment", env.URL)
+		saveOriginalConfig = false
+		ocmConfig, err = loadForEnv(env.URL)
+		if err != nil {
+			return c, err
+		}
+	}
+
 	// If we do not have a loaded config and it doesn't exist, warn
 	// the user and build one so that they can log in.
-	if ocmConfig == nil {
+	if ocmConfig == nil && env.customAuth() {
+		saveOriginalConfig = false
+		ocmConfig = new(config.Config)
+	} else if ocmConfig == nil {
 		if !viper.GetBool("features.ocm.ignore-login-warning") {
 
 			log.Warning("OCM config doesn't exist. You will be prompted to log in every time this is run. To prevent future log-in prompts, run `ocm login`")
@@ -115,16 +161,13 @@ func New() (*Config, error) {
 	}
 
 	log.Debugf("Ensuring ocm config is armed")
-	err = ensureArmed(ocmConfig)
+	err = ensureArmed(ocmConfig, env, opts)
 	if err != nil {
 		return c, err
 	}
 
 	agentString := fmt.Sprintf("ocm-container-%s", utils.Version)
-	ocmurl, err := url(viper.GetString("ocm-url"))
-	if err != nil {
-		return c, err
-	}
+	ocmurl := env.URL
 
 	connectionBuilder := connection.NewConnection().Config(ocmConfig).AsAgent(agentString).WithApiUrl(ocmurl)
 	connection, err := connectionBuilder.Build()
@@ -143,10 +186,15 @@ func New() (*Config, error) {
 	ocmConfig.AccessToken = accessToken
 	ocmConfig.RefreshToken = refreshToken
 
+	if viper.GetBool(ReadOnlyConfigKey) {
+		log.Debug("not saving the refreshed tokens into the read-only OCM config")
+		saveOriginalConfig = false
+	}
+
 	// Save the default config with the refreshed tokens so that we
 	// are not prompted to log in every time this is run again
 	if saveOriginalConfig {
-		if err := config.Save(ocmConfig); err != nil {
+		if err := saveConfig(ocmConfig); err != nil {
 			log.Warningf("error saving OCM config: %s", err)
 		}
 	}
@@ -154,10 +202,19 @@ func New() (*Config, error) {
 	// Now we're saving our own copy of the OCM config here, to prevent overriding inside the container.
 	// and let's ensure that we overwrite the URL for the container's config
 	ocmConfig.URL = ocmurl
-	ocmConfigLocation, err := saveForEnv(ocmConfig)
+	_, err = saveForEnv(ocmConfig)
+	if err != nil {
+		return c, fmt.Errorf("error saving copy of OCM config: %s", err)
+	}
+
+	// The container gets a copy of its own, so concurrent sessions for
+	// the same environment don't share one
+	ocmConfigLocation, removeSessionConfig, err := saveForSession(ocmConfig)
 	if err != nil {
 		return c, fmt.Errorf("error saving copy of OCM config: %s", err)
 	}
+	c.CleanupFuncs = append(c.CleanupFuncs, removeSessionConfig)
+	sessionConfig = ocmConfig
 
 	c.Env["OCMC_EXTERNAL_OCM_CONFIG"] = ocmConfigLocation
 	c.Env["OCMC_INTERNAL_OCM_CONFIG"] = "/root/.config/ocm/ocm.json"
@@ -170,20 +227,102 @@ func New() (*Config, error) {
 	return c, nil
 }
 
-// url takes a string in the form of urlAliases, and returns
-// the actual OCM URL
+// Connect builds a connection to OCM from the existing OCM config,
+// without prompting to log in or saving any config. It is meant for
+// subcommands that only use OCM opportunistically, and returns an
+// error if the user is not logged in.
+func Connect() (*sdk.Connection, error) {
+	if clusterCache == nil {
+		clusterCache = make(map[string]*cmv1.Cluster)
+	}
+
+	ocmConfig, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if viper.IsSet("ocm-url") && ocmConfig != nil {
+		env, err := resolve(viper.GetString("ocm-url"))
+		if err != nil {
+			return nil, err
+		}
+		if !env.matches(ocmConfig) {
+			ocmConfig, err = loadForEnv(env.URL)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ocmConfig == nil {
+		return nil, fmt.Errorf("not logged into OCM: no OCM config found")
+	}
+
+	err = ensureConfigDefaults(ocmConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	armed, reason, err := ocmConfig.Armed()
+	if err != nil {
+		return nil, fmt.Errorf("error checking OCM config arming: %s", err)
+	}
+	if !armed {
+		return nil, fmt.Errorf("not logged into OCM: %s", reason)
+	}
+
+	ocmurl := ocmConfig.URL
+	if viper.IsSet("ocm-url") {
+		ocmurl, err = url(viper.GetString("ocm-url"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	agentString := fmt.Sprintf("ocm-container-%s", utils.Version)
+	conn, err := connection.NewConnection().Config(ocmConfig).AsAgent(agentString).WithApiUrl(ocmurl).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error creating OCM connection: %s", err)
+	}
+	client = conn
+	return conn, nil
+}
+
+// url takes a string in the form of urlAliases, an alias from the
+// ocmUrls config, or an https URL, and returns the actual OCM URL
 func url(s string) (string, error) {
-	u, ok := urlAliases[s]
-	if !ok {
-		return "", errInvalidOcmUrl
+	env, err := resolve(s)
+	if err != nil {
+		return "", err
 	}
-	return u, nil
+	return env.URL, nil
+}
+
+// ResolveURL takes a string in the form of urlAliases and returns the
+// actual OCM URL, for callers outside this package that need to compare
+// user-provided environments against a connection's URL
+func ResolveURL(s string) (string, error) {
+	return url(s)
 }
 
-// alias takes a string in the form of an OCM_URL, and returns
-// a short alias
+// alias takes a string in the form of an OCM_URL, and returns a short
+// alias, safe to use in a filename: the built-in or configured alias of
+// the URL, or else a name derived from the URL itself
 func alias(s string) string {
-	return shortUrl[s]
+	if a, ok := shortUrl[s]; ok {
+		return a
+	}
+	// Prefer the shortest configured alias, so the name is stable
+	// whichever of several aliases for the URL was used
+	envs, _ := environments()
+	a := ""
+	for name, env := range envs {
+		if env.URL == s && (a == "" || len(name) < len(a) || (len(name) == len(a) && name < a)) {
+			a = name
+		}
+	}
+	if a != "" {
+		return safeFilename(a)
+	}
+	return safeFilename(s)
 }
 
 func GetClient() *sdk.Connection {
@@ -191,6 +330,23 @@ func GetClient() *sdk.Connection {
 	return client
 }
 
+// FreshConfig returns a copy of the OCM config copied into the
+// container, with the connection's tokens, renewed first if they expire
+// within minValidity
+func FreshConfig(minValidity time.Duration) (*config.Config, error) {
+	if client == nil || sessionConfig == nil {
+		return nil, errors.New("not logged into OCM")
+	}
+	accessToken, refreshToken, err := client.Tokens(minValidity)
+	if err != nil {
+		return nil, fmt.Errorf("error getting OCM tokens: %s", err)
+	}
+	cfg := *sessionConfig
+	cfg.AccessToken = accessToken
+	cfg.RefreshToken = refreshToken
+	return &cfg, nil
+}
+
 // CloseClient allows the global ocm client to be closed.
 func CloseClient() error {
 	if client == nil {
@@ -296,6 +452,27 @@ func GetCluster(connection *sdk.Connection, key string) (cluster *cmv1.Cluster,
 	return
 }
 
+// SearchClusters returns the clusters matching an OCM search query, eg:
+// "product.id = 'rosa' and state = 'ready'", paging through the results
+func SearchClusters(connection *sdk.Connection, search string) ([]*cmv1.Cluster, error) {
+	clusters := []*cmv1.Cluster{}
+	clustersResource := connection.ClustersMgmt().V1().Clusters()
+	for page := 1; ; page++ {
+		response, err := clustersResource.List().
+			Search(search).
+			Page(page).
+			Size(searchPageSize).
+			Send()
+		if err != nil {
+			return nil, fmt.Errorf("can't search for clusters matching '%s': %v", search, err)
+		}
+		clusters = append(clusters, response.Items().Slice()...)
+		if response.Size() < searchPageSize {
+			return clusters, nil
+		}
+	}
+}
+
 // GetClusterId takes an *sdk.Connection and a cluster identifier string, and returns the cluster ID
 func GetClusterId(ocmClient *sdk.Connection, key string) (string, error) {
 	cluster, err := GetCluster(ocmClient, key)
@@ -310,104 +487,102 @@ func GetClusterId(ocmClient *sdk.Connection, key string) (string, error) {
 // The path is the same as the existing OCM config, but the filename follows the convention:
 // ocm.json.ocm-container.$ocm_env
 func saveForEnv(cfg *config.Config) (string, error) {
-	file, err := config.Location()
+	dir, err := configDir()
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Dir(file)
-	err = os.MkdirAll(dir, os.FileMode(0755))
-	if err != nil {
-		return "", fmt.Errorf("can't create directory %s: %v", dir, err)
-	}
-	data, err := json.MarshalIndent(cfg, "", "  ") //nolint:gosec // marshaling OCM config with tokens is intentional
-	if err != nil {
-		return "", fmt.Errorf("can't marshal config: %v", err)
-	}
+	cachedConfig := cachedConfigLocation(dir, cfg.URL)
+	return cachedConfig, writeConfigFile(cachedConfig, cfg)
+}
 
-	cachedConfig := dir + "/ocm.json.ocm-container." + alias(cfg.URL)
+// cachedConfigLocation returns the path of the copy of the OCM config
+// saved for an environment
+func cachedConfigLocation(dir, ocmurl string) string {
+	return filepath.Join(dir, cachedConfigPrefix+alias(ocmurl))
+}
 
-	err = os.WriteFile(cachedConfig, data, 0600)
+// loadForEnv loads the copy of the OCM config saved for an environment,
+// returning nil if there is none
+func loadForEnv(ocmurl string) (*config.Config, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := readConfigFile(cachedConfigLocation(dir, ocmurl))
 	if err != nil {
-		return "", fmt.Errorf("can't write file '%s': %v", file, err)
+		return nil, err
 	}
-	return cachedConfig, nil
+	return withEnvSecret(cfg), nil
 }
 
 // ensureArmed validates that a given ocmConfig is "armed" and
-// ensures that the credentials are valid and ready to be saved
-// if the credentials are invalid or expired, it will initiate login
-func ensureArmed(ocmConfig *config.Config) error {
+// ensures that the credentials are valid and ready to be saved.
+// Credentials passed explicitly, eg: with --ocm-token-file, are used
+// instead of the config's. Otherwise, if the config's credentials are
+// invalid or expired, it will initiate login.
+func ensureArmed(ocmConfig *config.Config, env Environment, opts loginOptions) error {
+	ctx := context.Background()
+	if opts.explicit() {
+		return loginExplicitly(ctx, ocmConfig, opts)
+	}
+
 	armed, reason, err := ocmConfig.Armed()
 	if err != nil {
 		return fmt.Errorf("error checking OCM config arming: %s", err)
 	}
 
-	var token string
-
-	if !armed {
-		log.Debugf("not logged into OCM: %s", reason)
-		fmt.Fprintln(os.Stderr, "Please complete OCM browser authentication...")
-		token, err = auth.InitiateAuthCode(ocmContainerClientId)
-		if err != nil {
-			return fmt.Errorf("error initiating auth code: %s", err)
-		}
-		fmt.Fprintln(os.Stderr, "OCM authentication successful.")
-	} else {
+	if armed {
 		log.Debug("already logged into OCM")
-		token = ocmConfig.AccessToken
-	}
-
-	if config.IsEncryptedToken(token) {
-		log.Debug("OCM token is encrypted; assuming it is a RefreshToken")
-		ocmConfig.AccessToken = ""
-		ocmConfig.RefreshToken = token
-	} else {
-		log.Debug("OCM token is not encrypted; assuming it is an AccessToken")
-
-		parsedToken, err := config.ParseToken(token)
-		if err != nil {
-			return fmt.Errorf("error parsing token: %s", err)
-		}
-
-		typ, err := config.TokenType(parsedToken)
-		if err != nil {
-			return fmt.Errorf("error determining token type: %s", err)
+		token := ocmConfig.AccessToken
+		if token == "" {
+			token = ocmConfig.RefreshToken
 		}
-
-		switch typ {
-		case "Bearer", "":
-			log.Debugf("token type is '%s'; assuming it is an AccessToken", typ)
-			ocmConfig.AccessToken = token
-		case "Refresh":
-			log.Debugf("token type is '%s'; assuming it is a RefreshToken", typ)
-			ocmConfig.AccessToken = ""
-			ocmConfig.RefreshToken = token
-		default:
-			return fmt.Errorf("unknown token type: %s", typ)
+		// A config armed with client credentials has no token until the
+		// connection requests one
+		if token == "" {
+			return nil
 		}
+		return setToken(ocmConfig, token)
 	}
 
-	return nil
+	log.Debugf("not logged into OCM: %s", reason)
+	token, err := interactiveLogin(ctx, ocmConfig, env, opts, reason)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "OCM authentication successful.")
+	return setToken(ocmConfig, token)
 }
 
 func ensureConfigDefaults(cfg *config.Config) error {
+	env := Environment{}
+	if viper.IsSet("ocm-url") {
+		var err error
+		env, err = resolve(viper.GetString("ocm-url"))
+		if err != nil {
+			return err
+		}
+	}
+
 	if cfg.ClientID == "" {
 		cfg.ClientID = ocmContainerClientId
+		if env.ClientID != "" {
+			cfg.ClientID = env.ClientID
+		}
 	}
 	if cfg.TokenURL == "" {
 		cfg.TokenURL = sdk.DefaultTokenURL
+		if env.TokenURL != "" {
+			cfg.TokenURL = env.TokenURL
+		}
 	}
 	if len(cfg.Scopes) == 0 {
 		cfg.Scopes = defaultOcmScopes
 	}
 	if cfg.URL == "" {
 		cfg.URL = productionURL
-		if viper.IsSet("ocm-url") {
-			sessionUrl, err := url(viper.GetString("ocm-url"))
-			if err != nil {
-				return err
-			}
-			cfg.URL = sessionUrl
+		if env.URL != "" {
+			cfg.URL = env.URL
 		}
 	}
 	return nil
@@ -1,6 +1,10 @@
 package ocm
 
 import (
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/openshift-online/ocm-common/pkg/ocm/config"
 	sdk "github.com/openshift-online/ocm-sdk-go"
 
@@ -91,13 +95,87 @@ var _ = Describe("Pkg/OCM/OCM", func() {
 		It("Returns error for invalid alias", func() {
 			_, err := url("invalid")
 			Expect(err).To(HaveOccurred())
-			Expect(err).To(Equal(errInvalidOcmUrl))
+			Expect(err).To(MatchError(errInvalidOcmUrl))
 		})
 
 		It("Returns error for empty string", func() {
 			_, err := url("")
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("Returns any https URL, without a trailing slash", func() {
+			u, err := url("https://api.ocm.example.com/")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(u).To(Equal("https://api.ocm.example.com"))
+		})
+
+		It("Returns error for a URL which is not https", func() {
+			_, err := url("http://api.ocm.example.com")
+			Expect(err).To(MatchError(errInvalidOcmUrl))
+		})
+
+		It("Returns the URL of a configured alias", func() {
+			viper.Set(EnvironmentsConfigKey, map[string]any{"dev": "https://api.dev.example.com"})
+			u, err := url("dev")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(u).To(Equal("https://api.dev.example.com"))
+		})
+
+		It("Prefers configured aliases over the built-in ones", func() {
+			viper.Set(EnvironmentsConfigKey, map[string]any{"stage": "https://api.stage.example.com"})
+			u, err := url("stage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(u).To(Equal("https://api.stage.example.com"))
+		})
+
+		It("Returns error for a configured alias which is not an https URL", func() {
+			viper.Set(EnvironmentsConfigKey, map[string]any{"dev": "api.dev.example.com"})
+			_, err := url("dev")
+			Expect(err).To(MatchError(ContainSubstring("invalid ocmUrls.dev url")))
+		})
+	})
+
+	Context("resolve()", func() {
+		BeforeEach(func() {
+			viper.Set(EnvironmentsConfigKey, map[string]any{
+				"gov": map[string]any{
+					"url":      "https://api.gov.example.com",
+					"tokenUrl": "https://sso.gov.example.com/token",
+					"clientId": "gov-client",
+				},
+			})
+		})
+
+		It("Returns the token URL and client ID of a configured alias", func() {
+			env, err := resolve("gov")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env).To(Equal(Environment{
+				URL:      "https://api.gov.example.com",
+				TokenURL: "https://sso.gov.example.com/token",
+				ClientID: "gov-client",
+			}))
+			Expect(env.customAuth()).To(BeTrue())
+		})
+
+		It("Returns the configured environment of a URL", func() {
+			env, err := resolve("https://api.gov.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env.ClientID).To(Equal("gov-client"))
+		})
+
+		It("Returns an environment with the default SSO for other URLs", func() {
+			env, err := resolve("https://api.other.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env.customAuth()).To(BeFalse())
+		})
+
+		It("Matches OCM configs for the environment's SSO and client", func() {
+			env, err := resolve("gov")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env.matches(&config.Config{})).To(BeFalse())
+			Expect(env.matches(&config.Config{TokenURL: env.TokenURL, ClientID: env.ClientID})).To(BeTrue())
+			Expect(Environment{URL: productionURL}.matches(&config.Config{})).To(BeTrue())
+		})
 	})
 
 	Context("alias()", func() {
@@ -117,8 +195,22 @@ var _ = Describe("Pkg/OCM/OCM", func() {
 			Expect(alias(productionGovURL)).To(Equal("prodgov"))
 		})
 
-		It("Returns empty string for unknown URL", func() {
-			Expect(alias("https://unknown.example.com")).To(BeEmpty())
+		It("Returns a name derived from an unknown URL", func() {
+			Expect(alias("https://unknown.example.com")).To(Equal("unknown.example.com"))
+			Expect(alias("https://unknown.example.com:8443/ocm/")).To(Equal("unknown.example.com_8443_ocm"))
+		})
+
+		It("Returns a safe name for anything else", func() {
+			Expect(alias("../../etc/passwd")).To(Equal("etc_passwd"))
+			Expect(alias("")).To(Equal("default"))
+		})
+
+		It("Returns the configured alias of a URL", func() {
+			viper.Set(EnvironmentsConfigKey, map[string]any{
+				"development": "https://api.dev.example.com",
+				"dev":         "https://api.dev.example.com",
+			})
+			Expect(alias("https://api.dev.example.com")).To(Equal("dev"))
 		})
 	})
 
@@ -142,6 +234,13 @@ var _ = Describe("Pkg/OCM/OCM", func() {
 		})
 	})
 
+	Context("FreshConfig()", func() {
+		It("Returns an error when not logged in", func() {
+			_, err := FreshConfig(time.Minute)
+			Expect(err).To(MatchError(ContainSubstring("not logged into OCM")))
+		})
+	})
+
 	Context("ensureConfigDefaults()", func() {
 		It("Fills in defaults for empty config", func() {
 			cfg := &config.Config{}
@@ -190,6 +289,23 @@ var _ = Describe("Pkg/OCM/OCM", func() {
 			Expect(cfg.URL).To(Equal(stagingURL))
 		})
 
+		It("Uses the token URL and client ID of the viper ocm-url", func() {
+			viper.Set(EnvironmentsConfigKey, map[string]any{
+				"gov": map[string]any{
+					"url":      "https://api.gov.example.com",
+					"tokenUrl": "https://sso.gov.example.com/token",
+					"clientId": "gov-client",
+				},
+			})
+			viper.Set("ocm-url", "gov")
+			cfg := &config.Config{}
+			err := ensureConfigDefaults(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.URL).To(Equal("https://api.gov.example.com"))
+			Expect(cfg.TokenURL).To(Equal("https://sso.gov.example.com/token"))
+			Expect(cfg.ClientID).To(Equal("gov-client"))
+		})
+
 		It("Returns error for invalid viper ocm-url", func() {
 			viper.Set("ocm-url", "invalid-url")
 			cfg := &config.Config{}
@@ -197,4 +313,66 @@ var _ = Describe("Pkg/OCM/OCM", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("saveForEnv() and loadForEnv()", func() {
+		BeforeEach(func() {
+			GinkgoT().Setenv("OCM_CONFIG", filepath.Join(GinkgoT().TempDir(), "ocm.json"))
+		})
+
+		It("Saves and loads the config for an environment", func() {
+			cfg := &config.Config{URL: "https://api.dev.example.com", ClientID: "dev-client"}
+			location, err := saveForEnv(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filepath.Base(location)).To(Equal("ocm.json.ocm-container.api.dev.example.com"))
+
+			loaded, err := loadForEnv(cfg.URL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded.ClientID).To(Equal("dev-client"))
+		})
+
+		It("Never saves the client secret or password", func() {
+			cfg := &config.Config{URL: "https://api.dev.example.com", ClientID: "service-account", ClientSecret: "s3cret", Password: "hunter2"}
+			location, err := saveForEnv(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.ClientSecret).To(Equal("s3cret"))
+
+			data, err := os.ReadFile(location)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).ToNot(ContainSubstring("s3cret"))
+			Expect(string(data)).ToNot(ContainSubstring("hunter2"))
+
+			sessionLocation, remove, err := saveForSession(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			defer remove()
+			data, err = os.ReadFile(sessionLocation)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).ToNot(ContainSubstring("s3cret"))
+		})
+
+		It("Reads a service account's secret from the environment again", func() {
+			_, err := saveForEnv(&config.Config{URL: "https://api.dev.example.com", ClientID: "service-account", ClientSecret: "s3cret"})
+			Expect(err).ToNot(HaveOccurred())
+
+			loaded, err := loadForEnv("https://api.dev.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded.ClientSecret).To(BeEmpty())
+
+			GinkgoT().Setenv(ClientIDEnv, "service-account")
+			GinkgoT().Setenv(ClientSecretEnv, "s3cret")
+			loaded, err = loadForEnv("https://api.dev.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded.ClientSecret).To(Equal("s3cret"))
+
+			GinkgoT().Setenv(ClientIDEnv, "other-account")
+			loaded, err = loadForEnv("https://api.dev.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded.ClientSecret).To(BeEmpty())
+		})
+
+		It("Returns nil when no config was saved for the environment", func() {
+			loaded, err := loadForEnv(stagingURL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(BeNil())
+		})
+	})
 })
@@ -0,0 +1,349 @@
+// Package ocmtest provides a fake OCM API server for tests, serving the
+// resources of a fixture, so code using an *sdk.Connection, eg:
+// ocm.GetCluster, can be tested without a real OCM.
+package ocmtest
+
+import (
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	"github.com/spf13/viper"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+const (
+	// defaultPageSize is the page size of lists when none is requested,
+	// as in OCM
+	defaultPageSize = 100
+
+	// clientID is the client ID in the armed OCM config
+	clientID = "ocm-cli"
+)
+
+var (
+	// inTerm matches a search term like "status in ('Reserved', 'Active')"
+	inTerm = regexp.MustCompile(`^([\w.]+)\s+in\s+\(([^)]*)\)$`)
+	// comparisonTerm matches a search term like "name = 'my-cluster'"
+	comparisonTerm = regexp.MustCompile(`^([\w.]+)\s*(=|!=|>=|<=|>|<)\s*'([^']*)'$`)
+)
+
+// Fixture is the content of a fake OCM API
+type Fixture struct {
+	// Collections are the items of collections, keyed by path, eg:
+	// /api/clusters_mgmt/v1/clusters. Collections can be listed,
+	// searched and paged, and their items are got by their id, eg:
+	// /api/clusters_mgmt/v1/clusters/2abc
+	Collections map[string][]map[string]any `json:"collections"`
+	// Resources are any other resources, keyed by path, eg:
+	// /api/clusters_mgmt/v1/clusters/2abc/hypershift
+	Resources map[string]map[string]any `json:"resources"`
+	// Errors are returned instead of the resources at their paths, eg:
+	// 403 for paths the user has no permission for
+	Errors map[string]Error `json:"errors"`
+}
+
+// Error is an OCM API error
+type Error struct {
+	Status int    `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// LoadFixture loads a fixture from the fixtures directory by name, eg:
+// "clusters" for fixtures/clusters.json
+func LoadFixture(name string) (Fixture, error) {
+	f := Fixture{}
+	data, err := fixtures.ReadFile("fixtures/" + name + ".json")
+	if err != nil {
+		return f, fmt.Errorf("can't read fixture %s: %v", name, err)
+	}
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return f, fmt.Errorf("can't parse fixture %s: %v", name, err)
+	}
+	if f.Collections == nil {
+		f.Collections = map[string][]map[string]any{}
+	}
+	if f.Resources == nil {
+		f.Resources = map[string]map[string]any{}
+	}
+	if f.Errors == nil {
+		f.Errors = map[string]Error{}
+	}
+	return f, nil
+}
+
+// Server is a fake OCM API server. It only accepts requests with its
+// Token, and records the requests it was sent.
+type Server struct {
+	*httptest.Server
+	// Token is the access token the server accepts
+	Token string
+
+	fixture  Fixture
+	mu       sync.Mutex
+	requests []string
+}
+
+// NewServer starts a fake OCM API server serving the fixture over TLS
+func NewServer(f Fixture) *Server {
+	s := &Server{
+		Token:   fakeAccessToken(time.Now().Add(time.Hour)),
+		fixture: f,
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Requests returns the requests the server was sent, as the path and
+// search, eg: "/api/clusters_mgmt/v1/clusters?search=name = 'x'"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Arm writes an OCM config armed with the server's token into dir, and
+// points OCM_CONFIG and the ocm-url at it, so ocm.New and ocm.Connect use
+// the server without logging in. It returns a function restoring
+// OCM_CONFIG.
+func (s *Server) Arm(dir string) (func(), error) {
+	cfg := &config.Config{
+		URL:         s.URL,
+		TokenURL:    s.URL + "/token",
+		ClientID:    clientID,
+		Scopes:      []string{"openid"},
+		AccessToken: s.Token,
+		Insecure:    true,
+	}
+	data, err := json.Marshal(cfg) //nolint:gosec // marshaling the fake token is intentional
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, "ocm.json")
+	err = os.WriteFile(file, data, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("can't write file '%s': %v", file, err)
+	}
+
+	previous, wasSet := os.LookupEnv("OCM_CONFIG")
+	err = os.Setenv("OCM_CONFIG", file)
+	if err != nil {
+		return nil, err
+	}
+	viper.Set("ocm-url", s.URL)
+
+	return func() {
+		if wasSet {
+			_ = os.Setenv("OCM_CONFIG", previous)
+		} else {
+			_ = os.Unsetenv("OCM_CONFIG")
+		}
+	}, nil
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	search := r.URL.Query().Get("search")
+	s.mu.Lock()
+	if search != "" {
+		s.requests = append(s.requests, r.URL.Path+"?search="+search)
+	} else {
+		s.requests = append(s.requests, r.URL.Path)
+	}
+	s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, r, Error{Status: http.StatusUnauthorized, Reason: "Invalid token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, r, Error{Status: http.StatusMethodNotAllowed, Reason: "Only GET is supported by the fake OCM API"})
+		return
+	}
+
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	if e, ok := s.fixture.Errors[p]; ok {
+		writeError(w, r, e)
+		return
+	}
+
+	if items, ok := s.fixture.Collections[p]; ok {
+		s.list(w, r, items)
+		return
+	}
+
+	dir, id := path.Split(p)
+	if items, ok := s.fixture.Collections[strings.TrimSuffix(dir, "/")]; ok {
+		for _, item := range items {
+			if item["id"] == id {
+				WriteJSON(w, http.StatusOK, item)
+				return
+			}
+		}
+	}
+
+	if resource, ok := s.fixture.Resources[p]; ok {
+		WriteJSON(w, http.StatusOK, resource)
+		return
+	}
+
+	writeError(w, r, Error{Status: http.StatusNotFound, Reason: fmt.Sprintf("Resource '%s' not found", p)})
+}
+
+// list writes the page of the items matching the request's search
+func (s *Server) list(w http.ResponseWriter, r *http.Request, items []map[string]any) {
+	query := r.URL.Query()
+	matched := []map[string]any{}
+	for _, item := range items {
+		ok, err := matches(item, query.Get("search"))
+		if err != nil {
+			writeError(w, r, Error{Status: http.StatusBadRequest, Reason: err.Error()})
+			return
+		}
+		if ok {
+			matched = append(matched, item)
+		}
+	}
+
+	page := intParam(query.Get("page"), 1)
+	size := intParam(query.Get("size"), defaultPageSize)
+	start := min((page-1)*size, len(matched))
+	end := min(start+size, len(matched))
+
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"page":  page,
+		"size":  end - start,
+		"total": len(matched),
+		"items": matched[start:end],
+	})
+}
+
+// matches returns true if the item matches an OCM search. Only the kinds
+// of search ocm-container makes are supported: terms joined with "and",
+// each either "field in ('a', 'b')", or comparisons like
+// "field = 'value'" joined with "or", optionally in parentheses. Values
+// are compared as strings.
+func matches(item map[string]any, search string) (bool, error) {
+	if strings.TrimSpace(search) == "" {
+		return true, nil
+	}
+
+	for _, term := range strings.Split(search, " and ") {
+		term = strings.TrimSpace(term)
+		if m := inTerm.FindStringSubmatch(term); m != nil {
+			value := field(item, m[1])
+			found := false
+			for _, v := range strings.Split(m[2], ",") {
+				if strings.Trim(strings.TrimSpace(v), "'") == value {
+					found = true
+				}
+			}
+			if !found {
+				return false, nil
+			}
+			continue
+		}
+
+		term = strings.TrimSuffix(strings.TrimPrefix(term, "("), ")")
+		found := false
+		for _, comparison := range strings.Split(term, " or ") {
+			m := comparisonTerm.FindStringSubmatch(strings.TrimSpace(comparison))
+			if m == nil {
+				return false, fmt.Errorf("unsupported search term %q", comparison)
+			}
+			if compare(field(item, m[1]), m[2], m[3]) {
+				found = true
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// field returns the value of a field of an item as a string, eg:
+// "product.id" for {"product": {"id": "rosa"}}
+func field(item map[string]any, name string) string {
+	var value any = item
+	for _, key := range strings.Split(name, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value, ok = m[key]
+		if !ok {
+			return ""
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func compare(a, op, b string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	}
+	return false
+}
+
+func intParam(s string, def int) int {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 1 {
+		return def
+	}
+	return i
+}
+
+// fakeAccessToken returns an unsigned JWT access token, which the SDK
+// uses without verifying
+func fakeAccessToken(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	claims := enc.EncodeToString(fmt.Appendf(nil, `{"typ":"Bearer","exp":%d}`, exp.Unix()))
+	return header + "." + claims + ".sig"
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, e Error) {
+	id := strconv.Itoa(e.Status)
+	WriteJSON(w, e.Status, map[string]any{
+		"kind":         "Error",
+		"id":           id,
+		"href":         "/api/errors/" + id,
+		"code":         "OCM-" + id,
+		"reason":       e.Reason,
+		"operation_id": "fake-" + path.Base(r.URL.Path),
+	})
+}
+
+// WriteJSON writes the body as a JSON response with the status, for
+// fake servers in tests, eg: a fake SSO token endpoint
+func WriteJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
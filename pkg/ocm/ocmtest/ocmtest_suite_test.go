@@ -0,0 +1,13 @@
+package ocmtest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestOcmtest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ocmtest Suite")
+}
@@ -0,0 +1,135 @@
+package ocmtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/OCM/OCMTest", func() {
+	Context("matches()", func() {
+		item := map[string]any{
+			"name":      "my-cluster",
+			"status":    "Active",
+			"timestamp": "2026-01-02T00:00:00Z",
+			"product":   map[string]any{"id": "rosa"},
+		}
+
+		DescribeTable("matches items against OCM searches",
+			func(search string, expected bool) {
+				ok, err := matches(item, search)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ok).To(Equal(expected))
+			},
+			Entry("no search", "", true),
+			Entry("equal", "name = 'my-cluster'", true),
+			Entry("not equal", "name = 'other'", false),
+			Entry("or", "(name = 'other' or id = 'my-cluster' or name = 'my-cluster')", true),
+			Entry("nested field", "product.id = 'rosa'", true),
+			Entry("and", "product.id = 'rosa' and name = 'other'", false),
+			Entry("in", "name = 'my-cluster' and status in ('Reserved', 'Active')", true),
+			Entry("not in", "name = 'my-cluster' and status in ('Deprovisioned')", false),
+			Entry("comparison", "timestamp >= '2026-01-01T00:00:00Z'", true),
+			Entry("missing field", "region.id = 'us-east-1'", false),
+		)
+
+		It("Returns an error for unsupported searches", func() {
+			_, err := matches(item, "name like 'my-%'")
+			Expect(err).To(MatchError(ContainSubstring("unsupported search term")))
+		})
+	})
+
+	Context("Server", func() {
+		var s *Server
+
+		BeforeEach(func() {
+			viper.Reset()
+			f, err := LoadFixture("clusters")
+			Expect(err).ToNot(HaveOccurred())
+			f.Errors["/api/clusters_mgmt/v1/clusters/2hcp/hypershift"] = Error{Status: http.StatusForbidden, Reason: "Forbidden"}
+			s = NewServer(f)
+			DeferCleanup(s.Close)
+		})
+
+		get := func(path, token string) (*http.Response, map[string]any) {
+			req, err := http.NewRequest(http.MethodGet, s.URL+path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := s.Client().Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			defer func() { _ = resp.Body.Close() }()
+			body := map[string]any{}
+			Expect(json.NewDecoder(resp.Body).Decode(&body)).To(Succeed())
+			return resp, body
+		}
+
+		It("Lists, searches and pages collections", func() {
+			resp, body := get("/api/clusters_mgmt/v1/clusters?search=name+%3D+'twin'&size=1&page=2", s.Token)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body["total"]).To(BeEquivalentTo(2))
+			Expect(body["size"]).To(BeEquivalentTo(1))
+			Expect(body["items"]).To(HaveLen(1))
+			Expect(body["items"].([]any)[0].(map[string]any)["id"]).To(Equal("6twin2"))
+			Expect(s.Requests()).To(Equal([]string{"/api/clusters_mgmt/v1/clusters?search=name = 'twin'"}))
+		})
+
+		It("Gets collection items and resources", func() {
+			resp, body := get("/api/clusters_mgmt/v1/clusters/1classic", s.Token)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body["name"]).To(Equal("classic-cluster"))
+
+			resp, body = get("/api/clusters_mgmt/v1/clusters/1classic/provision_shard", s.Token)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body["id"]).To(Equal("shard-1"))
+		})
+
+		It("Returns OCM errors", func() {
+			resp, body := get("/api/clusters_mgmt/v1/clusters/missing", s.Token)
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(body["kind"]).To(Equal("Error"))
+
+			resp, _ = get("/api/clusters_mgmt/v1/clusters/2hcp/hypershift", s.Token)
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+			resp, _ = get("/api/clusters_mgmt/v1/clusters/1classic", "wrong")
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Arms an OCM config for the server", func() {
+			restore, err := s.Arm(GinkgoT().TempDir())
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(restore)
+
+			Expect(viper.GetString("ocm-url")).To(Equal(s.URL))
+			cfg, err := config.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.URL).To(Equal(s.URL))
+			Expect(cfg.AccessToken).To(Equal(s.Token))
+			Expect(cfg.Insecure).To(BeTrue())
+			armed, _, err := cfg.Armed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(armed).To(BeTrue())
+		})
+
+		It("Restores OCM_CONFIG", func() {
+			GinkgoT().Setenv("OCM_CONFIG", "/previous/ocm.json")
+			restore, err := s.Arm(GinkgoT().TempDir())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.Getenv("OCM_CONFIG")).ToNot(Equal("/previous/ocm.json"))
+			restore()
+			Expect(os.Getenv("OCM_CONFIG")).To(Equal("/previous/ocm.json"))
+		})
+	})
+
+	Context("LoadFixture()", func() {
+		It("Returns an error for unknown fixtures", func() {
+			_, err := LoadFixture("missing")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,198 @@
+package ocm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Concurrent sessions, eg: for prod and stage, read and write the same
+// OCM config files, so each is only read or written while holding a
+// lock beside it. Each session also gets its own copy of the config to
+// copy into its container, removed when the session ends.
+
+const (
+	cachedConfigPrefix = "ocm.json.ocm-container."
+	sessionSuffix      = ".session-"
+)
+
+// ReadOnlyConfigKey is the config key which stops the refreshed tokens
+// being saved into the OCM config
+const ReadOnlyConfigKey = "features.ocm.read-only-config"
+
+// lockConfig takes the lock guarding an OCM config file
+func lockConfig(path string) (*utils.FileLock, error) {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, os.FileMode(0755))
+	if err != nil {
+		return nil, fmt.Errorf("can't create directory %s: %v", dir, err)
+	}
+	l, err := utils.LockFile(path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("can't lock %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// withConfigLock runs f while holding the lock of the OCM config
+func withConfigLock(f func() error) error {
+	file, err := config.Location()
+	if err != nil {
+		return err
+	}
+	l, err := lockConfig(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = l.Unlock() }()
+	return f()
+}
+
+// loadConfig loads the OCM config
+func loadConfig() (cfg *config.Config, err error) {
+	err = withConfigLock(func() error {
+		cfg, err = config.Load()
+		return err
+	})
+	return cfg, err
+}
+
+// saveConfig saves the OCM config
+func saveConfig(cfg *config.Config) error {
+	return withConfigLock(func() error {
+		return config.Save(cfg)
+	})
+}
+
+// readConfigFile reads an ocm-container copy of the OCM config,
+// returning nil if it does not exist
+func readConfigFile(path string) (*config.Config, error) {
+	l, err := lockConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = l.Unlock() }()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config.Config{}
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// writeConfigFile writes an ocm-container copy of the OCM config,
+// without its secrets
+func writeConfigFile(path string, cfg *config.Config) error {
+	data, err := json.MarshalIndent(WithoutSecrets(cfg), "", "  ") //nolint:gosec // marshaling OCM config with tokens is intentional
+	if err != nil {
+		return fmt.Errorf("can't marshal config: %v", err)
+	}
+
+	l, err := lockConfig(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = l.Unlock() }()
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("can't write file '%s': %v", path, err)
+	}
+	return nil
+}
+
+// WithoutSecrets returns a copy of the OCM config without the client
+// secret and password, for copies saved to disk or passed into the
+// container, which only need the tokens
+func WithoutSecrets(cfg *config.Config) *config.Config {
+	c := *cfg
+	c.ClientSecret = ""
+	c.Password = ""
+	return &c
+}
+
+// withEnvSecret fills in the client secret of a copy of the config
+// saved for a service account from the environment, so its token can be
+// renewed, as the secret is never saved with it
+func withEnvSecret(cfg *config.Config) *config.Config {
+	if cfg != nil && cfg.ClientSecret == "" && cfg.ClientID != "" && cfg.ClientID == os.Getenv(ClientIDEnv) {
+		cfg.ClientSecret = os.Getenv(ClientSecretEnv)
+	}
+	return cfg
+}
+
+// configDir returns the directory of the OCM config, where the
+// ocm-container copies are saved
+func configDir() (string, error) {
+	file, err := config.Location()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(file), nil
+}
+
+// saveForSession saves a copy of the config for this session only, and
+// returns its path and a function removing it
+func saveForSession(cfg *config.Config) (string, func(), error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", nil, err
+	}
+	pruneSessionConfigs(dir)
+
+	path := cachedConfigLocation(dir, cfg.URL) + sessionSuffix + strconv.Itoa(os.Getpid())
+	err = writeConfigFile(path, cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	remove := func() {
+		for _, p := range []string{path, path + ".lock"} {
+			err := os.Remove(p)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Warnf("unable to remove the session's OCM config: %v", err)
+			}
+		}
+	}
+	return path, remove, nil
+}
+
+// pruneSessionConfigs removes the session copies of the config left by
+// sessions which ended without removing them, eg: when killed
+func pruneSessionConfigs(dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, cachedConfigPrefix+"*"+sessionSuffix+"*"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		i := strings.LastIndex(path, sessionSuffix)
+		pid, err := strconv.Atoi(strings.TrimSuffix(path[i+len(sessionSuffix):], ".lock"))
+		if err != nil || processAlive(pid) {
+			continue
+		}
+		log.Debugf("removing the OCM config of an ended session: %s", path)
+		_ = os.Remove(path)
+	}
+}
+
+// processAlive returns true if a process with the pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,83 @@
+package ocm
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift-online/ocm-common/pkg/ocm/config"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Pkg/OCM/Store", func() {
+	var dir string
+
+	BeforeEach(func() {
+		viper.Reset()
+		dir = GinkgoT().TempDir()
+		GinkgoT().Setenv("OCM_CONFIG", filepath.Join(dir, "ocm.json"))
+	})
+
+	Context("saveConfig() and loadConfig()", func() {
+		It("Saves and loads the OCM config", func() {
+			Expect(saveConfig(&config.Config{URL: stagingURL})).To(Succeed())
+			cfg, err := loadConfig()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.URL).To(Equal(stagingURL))
+		})
+
+		It("Serializes concurrent writes", func() {
+			wg := sync.WaitGroup{}
+			for i := range 20 {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(saveConfig(&config.Config{URL: stagingURL, ClientID: strconv.Itoa(i)})).To(Succeed())
+					_, err := saveForEnv(&config.Config{URL: stagingURL, ClientID: strconv.Itoa(i)})
+					Expect(err).ToNot(HaveOccurred())
+				}()
+			}
+			wg.Wait()
+
+			cfg, err := loadConfig()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.URL).To(Equal(stagingURL))
+			cfg, err = loadForEnv(stagingURL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.URL).To(Equal(stagingURL))
+		})
+	})
+
+	Context("saveForSession()", func() {
+		It("Saves a copy for the session, which is removed at the end", func() {
+			path, remove, err := saveForSession(&config.Config{URL: stagingURL})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filepath.Base(path)).To(Equal("ocm.json.ocm-container.stage.session-" + strconv.Itoa(os.Getpid())))
+			Expect(path).To(BeAnExistingFile())
+
+			remove()
+			Expect(path).ToNot(BeAnExistingFile())
+			Expect(path + ".lock").ToNot(BeAnExistingFile())
+		})
+
+		It("Removes the copies of sessions which have ended", func() {
+			// PIDs are never this large, so the session has ended
+			ended := filepath.Join(dir, "ocm.json.ocm-container.prod.session-999999999")
+			running := filepath.Join(dir, "ocm.json.ocm-container.prod.session-"+strconv.Itoa(os.Getppid()))
+			for _, p := range []string{ended, ended + ".lock", running} {
+				Expect(os.WriteFile(p, []byte("{}"), 0600)).To(Succeed())
+			}
+
+			_, remove, err := saveForSession(&config.Config{URL: stagingURL})
+			Expect(err).ToNot(HaveOccurred())
+			defer remove()
+			Expect(ended).ToNot(BeAnExistingFile())
+			Expect(ended + ".lock").ToNot(BeAnExistingFile())
+			Expect(running).To(BeAnExistingFile())
+		})
+	})
+})
@@ -1,9 +1,12 @@
 package ocmcontainer
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strconv"
@@ -11,13 +14,19 @@ import (
 	"sync"
 	"syscall"
 
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/openshift/ocm-container/pkg/clusterstatus"
 	"github.com/openshift/ocm-container/pkg/deprecation"
 	"github.com/openshift/ocm-container/pkg/engine"
 	"github.com/openshift/ocm-container/pkg/features"
+	"github.com/openshift/ocm-container/pkg/image"
 	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/openshift/ocm-container/pkg/profiles"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 type Error string
@@ -25,8 +34,6 @@ type Error string
 func (e Error) Error() string { return string(e) }
 
 const (
-	containerStateRunningTemplate = `{{.State.Running}}`
-
 	errHomeEnvUnset         = Error("environment variable $HOME is not set")
 	errClusterAndDashArgs   = Error("specifying a cluster with --cluster-id and using a `-` in the first argument are mutually exclusive")
 	errContainerNotRunning  = Error("container is not running")
@@ -40,6 +47,10 @@ type Runtime struct {
 	dryRun    bool
 	command   []string
 
+	// backgroundPullImage is pulled in the background once the container
+	// starts, so the next session uses an up to date image
+	backgroundPullImage string
+
 	// PostStartExecHooks are functions that are defined by features in order
 	// to allow features to self-initialize things _after_ the container has
 	// started.
@@ -60,30 +71,191 @@ type Runtime struct {
 	trapped                      bool
 }
 
-func New(cmd *cobra.Command, args []string) (*Runtime, error) {
-	var err error
+// LookUpClusters logs in to OCM and checks every cluster exists before
+// sessions are started for several clusters, so they fail fast and
+// don't each prompt to log in
+func LookUpClusters(clusters []string) error {
+	err := profiles.ApplyNamed(profiles.Names(viper.GetString(profiles.FlagName)))
+	if err != nil {
+		return err
+	}
+
+	ocmConfig, err := ocm.New()
+	if err != nil {
+		return fmt.Errorf("error creating connection to ocm: %v", err)
+	}
+	defer ocmConfig.Cleanup()
+	conn := ocm.GetClient()
+	for _, cluster := range clusters {
+		fmt.Fprintln(os.Stderr, "Looking up cluster: "+cluster+"...")
+		_, err = ocm.GetCluster(conn, cluster)
+		if err != nil {
+			return fmt.Errorf("%v - using ocm-url %s", err, conn.URL())
+		}
+	}
+	return nil
+}
+
+// checkCluster prints a summary of the cluster before the container
+// starts, and enforces the guardrails on logging into it
+func checkCluster(conn *sdk.Connection, cluster *cmv1.Cluster) error {
+	guardrails, err := clusterstatus.ReadGuardrails()
+	if err != nil {
+		return err
+	}
+
+	banner, err := clusterstatus.ReadBannerConfig()
+	if err != nil {
+		log.Warn(err)
+		banner.Enabled = false
+	}
+	opts := clusterstatus.Options{}
+	if banner.Enabled {
+		opts, err = banner.Options()
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+
+	status := clusterstatus.Fetch(conn, cluster, opts)
+	if banner.Enabled {
+		err = status.WriteBanner(os.Stderr)
+		if err != nil {
+			log.Debugf("unable to write the cluster banner: %v", err)
+		}
+	}
+
+	// One reader for every prompt, so an answer typed ahead is not lost
+	// to the buffer of an earlier prompt
+	in := bufio.NewReader(os.Stdin)
+	return guardrails.Enforce(status, viper.GetBool(clusterstatus.ForceFlag), func(prompt string) (bool, error) {
+		return confirm(in, prompt)
+	})
+}
+
+// confirm asks the user a yes or no question
+func confirm(in *bufio.Reader, prompt string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.New("input is not a terminal")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	return readAnswer(in)
+}
+
+// readAnswer reads a yes or no answer
+func readAnswer(in *bufio.Reader) (bool, error) {
+	answer, err := in.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func New(cmd *cobra.Command, args []string) (o *Runtime, err error) {
 	var dryRun = viper.GetBool("dry-run")
 
-	o := &Runtime{
+	o = &Runtime{
 		dryRun:             dryRun,
 		PostStartExecHooks: [](func(features.ContainerRuntime) error){},
 	}
 
-	o.engine, err = engine.New(viper.GetString("engine"), viper.GetString("imagePullPolicy"), dryRun)
+	// If the session can't be started, run the cleanups registered so
+	// far, eg: removing the session's copy of the OCM config, since Run
+	// never will
+	defer func() {
+		if err != nil {
+			o.postExecCleanup()
+		}
+	}()
+
+	// Explicitly selected profiles are applied first, since they may
+	// change anything from the ocm-url to the engine or image
+	err = profiles.ApplyNamed(profiles.Names(viper.GetString(profiles.FlagName)))
 	if err != nil {
 		return o, err
 	}
 
+	home := os.Getenv("HOME")
+	if home == "" {
+		return o, errHomeEnvUnset
+	}
+
+	ocmConfig, err := ocm.New()
+	if err != nil {
+		return o, fmt.Errorf("error creating connection to ocm: %v", err)
+	}
+	for _, f := range ocmConfig.CleanupFuncs {
+		o.RegisterPostExecCleanupFunc(f)
+	}
+
 	cluster := viper.GetString("cluster-id")
 
+	var clusterObj *cmv1.Cluster
+	conn := ocm.GetClient()
+	if cluster != "" {
+		// check if cluster exists to fail fast
+		fmt.Fprintln(os.Stderr, "Looking up cluster: "+cluster+"...")
+		clusterObj, err = ocm.GetCluster(conn, cluster)
+		if err != nil {
+			return o, fmt.Errorf("%v - using ocm-url %s", err, conn.URL())
+		}
+	}
+
+	// Overlay any profiles matching the environment or cluster before
+	// the rest of the config is read
+	err = profiles.ApplyMatching(profiles.Target{OcmURL: conn.URL(), Cluster: clusterObj})
+	if err != nil {
+		return o, err
+	}
+
+	if clusterObj != nil {
+		err = checkCluster(conn, clusterObj)
+		if err != nil {
+			return o, err
+		}
+	}
+
+	imageRef, err := ResolveImage()
+	if err != nil {
+		return o, err
+	}
+
+	pullPolicy := viper.GetString("imagePullPolicy")
+	if imageRef.Pinned() && (pullPolicy == "always" || pullPolicy == "newer") {
+		// A digest always refers to the same image, so there is never
+		// a newer one to pull
+		log.Debugf("image is pinned to a digest; only pulling it if missing")
+		pullPolicy = "missing"
+	}
+
+	o.engine, err = engine.New(viper.GetString("engine"), pullPolicy, dryRun)
+	if err != nil {
+		return o, err
+	}
+
+	// Capabilities are cached, so the engine is only probed occasionally
+	caps, probed, err := o.engine.Capabilities()
+	if err != nil {
+		log.Warnf("unable to detect the capabilities of the container engine: %v", err)
+	}
+	log.Debugf("container engine capabilities: %+v", caps)
+
+	// The image is pulled before the container is created with the
+	// always pull policy, and never changes if it is pinned
+	if viper.GetBool(backgroundPullKey) && pullPolicy != "always" && !imageRef.Pinned() {
+		o.backgroundPullImage = imageRef.String()
+	}
+
 	c := engine.ContainerRef{
-		LocalPorts: map[string]int{},
+		LocalPorts:   map[string]int{},
+		PortBindings: map[string]engine.PortBinding{},
 	}
 	// Hard-coded values
 	c.Privileged = true
 	c.RemoveAfterExit = true
 
-	// image, tag, launchOpts, console, personalization
+	// launchOpts, console, personalization
 	c, err = parseFlags(c)
 	if err != nil {
 		return o, err
@@ -91,8 +263,11 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 
 	log.Debug(fmt.Sprintf("container ref: %+v\n", c))
 
+	c.Image = imageRef.String()
 	c.Volumes = []engine.VolumeMount{}
-	c.Envs = []engine.EnvVar{}
+	c.Envs = []engine.EnvVar{
+		{Key: sessionMetadataEnv, Value: sessionMetadataPath},
+	}
 
 	// these args are already split and checked by the root command
 	if len(args) != 0 {
@@ -104,42 +279,32 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 		}
 	}
 
-	home := os.Getenv("HOME")
-	if home == "" {
-		return o, errHomeEnvUnset
-	}
-
-	ocmConfig, err := ocm.New()
-	if err != nil {
-		return o, fmt.Errorf("error creating connection to ocm: %v", err)
+	// in case we want to skip login, check that here:
+	if cluster != "" && viper.GetBool("no-login") {
+		c.Envs = append(c.Envs, engine.EnvVar{Key: "SKIP_CLUSTER_LOGIN", Value: "true"})
 	}
-	o.RegisterPreExecCleanupFunc(func() { _ = ocm.CloseClient() })
-
-	if cluster != "" {
-		conn := ocm.GetClient()
-		// check if cluster exists to fail fast
-		fmt.Fprintln(os.Stderr, "Looking up cluster: "+cluster+"...")
-		_, err := ocm.GetCluster(conn, cluster)
-		if err != nil {
-			return o, fmt.Errorf("%v - using ocm-url %s", err, conn.URL())
-		}
 
-		// in case we want to skip login, check that here:
-		if viper.GetBool("no-login") {
-			c.Envs = append(c.Envs, engine.EnvVar{Key: "SKIP_CLUSTER_LOGIN", Value: "true"})
-		}
+	// Features needing tools the image variant does not include are
+	// skipped, rather than failing inside the container
+	variant := o.imageVariant(imageRef)
+	if variant != "" {
+		log.Debugf("using the %s image variant", variant)
 	}
+	features.SetImageTools(variant, image.VariantTools(variant))
 
 	// OCM-Container optional features follow:
 	featureOptions, err := features.Initialize()
+	for _, f := range featureOptions.CleanupFuncs {
+		o.RegisterPostExecCleanupFunc(f)
+	}
 	if err != nil {
-		log.Errorf("There was an error initializing a feature: %v", err)
-		os.Exit(2)
+		return o, fmt.Errorf("there was an error initializing a feature: %v", err)
 	}
 
-	c.Volumes = append(c.Volumes, featureOptions.Mounts...)
+	c.Volumes = append(c.Volumes, caps.RelabelMounts(featureOptions.Mounts)...)
 	c.Envs = append(c.Envs, featureOptions.Envs...)
 	maps.Copy(c.LocalPorts, featureOptions.PortMap)
+	maps.Copy(c.PortBindings, featureOptions.PortBindings)
 	o.PostStartExecHooks = append(o.PostStartExecHooks, featureOptions.PostStartExecHooks...)
 
 	// Parse additional mounts from the config file
@@ -148,30 +313,27 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 		var vols []any
 		err := viper.UnmarshalKey("volumeMounts", &vols)
 		if err != nil {
-			log.Errorf("unable to parse volumeMounts config %s", err)
-			os.Exit(10)
+			return o, fmt.Errorf("unable to parse volumeMounts config: %v", err)
 		}
 
-		unsupportedMountTypes := false
+		unsupported := []string{}
 		for _, vol := range vols {
 			if v, ok := vol.(string); ok {
 				log.Debugf("Parsing bind mount '%s' as string", v)
 				mount, err := parseMountString(v)
 				if err != nil {
-					log.Errorf("error parsing configured mount string '%s': %v", v, err)
-					os.Exit(10)
+					return o, fmt.Errorf("error parsing configured mount string '%s': %v", v, err)
 				}
 				mounts = append(mounts, mount)
 				continue
 			}
 			// Here is where we will process additional mounts as a map, if we decide to go that direction:
 			//log.Debugf("Parsing bind mount as map '%+v'", vol)
-			log.Errorf("unsupported mount: %+v", vol)
-			unsupportedMountTypes = true
+			unsupported = append(unsupported, fmt.Sprintf("%+v", vol))
 			continue
 		}
-		if unsupportedMountTypes {
-			os.Exit(10)
+		if len(unsupported) > 0 {
+			return o, fmt.Errorf("unsupported volumeMounts: %s", strings.Join(unsupported, ", "))
 		}
 		c.Volumes = append(c.Volumes, mounts...)
 	}
@@ -183,8 +345,7 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 			log.Debugf("parsing mount string '%s'", mountString)
 			mount, err := parseMountString(mountString)
 			if err != nil {
-				log.Errorf("error parsing additional mount string '%s': %v", mountString, err)
-				os.Exit(10)
+				return o, fmt.Errorf("error parsing additional mount string '%s': %v", mountString, err)
 			}
 			mounts = append(mounts, mount)
 		}
@@ -199,8 +360,7 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 		var rawEnvs []map[string]string
 		err := viper.UnmarshalKey("env", &rawEnvs)
 		if err != nil {
-			log.Errorf("error parsing additional environment vars: %v", err)
-			os.Exit(10)
+			return o, fmt.Errorf("error parsing additional environment vars: %v", err)
 		}
 
 		for _, e := range rawEnvs {
@@ -224,8 +384,7 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 			log.Debugf("parsing string: %s", e)
 			env, err := engine.EnvVarFromString(e)
 			if err != nil {
-				log.Errorf("error parsing flag-defined env var: %v", err)
-				os.Exit(10)
+				return o, fmt.Errorf("error parsing flag-defined env var: %v", err)
 			}
 			log.Debugf("parsed env: %+v", env)
 			envs = append(envs, env)
@@ -233,7 +392,23 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 		c.Envs = append(c.Envs, envs...)
 	}
 
+	// Drop any args the engine can't apply on this host, which would
+	// otherwise fail to create the container
+	c, dropped := caps.Compatible(c)
+	for _, w := range dropped {
+		log.Warn(w)
+	}
+
 	// Create the actual container
+	// Only warn when the engine was probed, rather than on every launch
+	for _, w := range caps.Warnings(c) {
+		if probed {
+			log.Warn(w)
+		} else {
+			log.Debug(w)
+		}
+	}
+
 	err = o.CreateContainer(c)
 	if err != nil {
 		return o, err
@@ -241,6 +416,11 @@ func New(cmd *cobra.Command, args []string) (*Runtime, error) {
 
 	log.Printf("container created with ID: %v\n", o.container.ID)
 
+	err = o.recordSession(imageRef, variant, conn.URL(), cluster)
+	if err != nil {
+		log.Warnf("unable to record session metadata: %v", err)
+	}
+
 	log.Debugf(
 		"copying ocm config into container: %s - %s\n",
 		ocmConfig.Env["OCMC_EXTERNAL_OCM_CONFIG"],
@@ -351,9 +531,6 @@ func parseFlags(c engine.ContainerRef) (engine.ContainerRef, error) {
 	c.Tty = true
 	c.Interactive = true
 
-	image := viper.GetString("image")
-	c.Image = image
-
 	// Best-effort passing of launch options
 	launchOpts := viper.GetString("launch-opts")
 	if launchOpts != "" {
@@ -431,7 +608,22 @@ func (o *Runtime) Run() error {
 		}
 		return err
 	}
-	return o.Attach()
+
+	if len(o.postExecCleanupFuncs) == 0 {
+		return o.Attach()
+	}
+
+	// Attaching replaces this process, so when there is cleanup to do
+	// at the end of the session, attach as a child process instead
+	o.Trap()
+	err := o.engine.AttachAndWait(o.container)
+	o.postExecCleanup()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
 }
 
 func (o *Runtime) Stop(timeout int) error {
@@ -439,7 +631,11 @@ func (o *Runtime) Stop(timeout int) error {
 }
 
 func (o *Runtime) Start(attach bool) error {
-	return o.engine.Start(o.container, false)
+	err := o.engine.Start(o.container, false)
+	if err == nil && o.backgroundPullImage != "" {
+		o.pullInBackground()
+	}
+	return err
 }
 
 func (o *Runtime) StartAndAttach() error {
@@ -476,6 +672,11 @@ func (o *Runtime) Copy(source, destination string) (string, error) {
 	return out, err
 }
 
+// HostPort returns the host port a tcp container port is published on
+func (o *Runtime) HostPort(port int) (string, error) {
+	return o.Inspect(o.engine.HostPortTemplate(port))
+}
+
 func (o *Runtime) Inspect(query string) (string, error) {
 
 	if query == "" {
@@ -518,7 +719,7 @@ func lookUpNegativeName(flag string) string {
 // Running returns a boolean indicating if the container is running in that Point In Time
 // Keep in mind the state could change at any time
 func (o *Runtime) Running() (bool, error) {
-	running, err := o.Inspect(containerStateRunningTemplate)
+	running, err := o.Inspect(o.engine.RunningTemplate())
 	if err != nil {
 		return false, err
 	}
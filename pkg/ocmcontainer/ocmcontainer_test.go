@@ -1,9 +1,13 @@
 package ocmcontainer
 
 import (
+	"bufio"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/ocm/ocmtest"
 	"github.com/spf13/viper"
 )
 
@@ -354,3 +358,53 @@ func TestPostExecCleanup(t *testing.T) {
 		t.Errorf("Expected 3 post-exec cleanup functions to be called, got %d", callCount)
 	}
 }
+
+// The session's copy of the OCM config holds tokens, so it is removed
+// when the session can't be started
+func TestNewCleansUpOnError(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Setenv("HOME", t.TempDir())
+
+	fixture, err := ocmtest.LoadFixture("clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := ocmtest.NewServer(fixture)
+	t.Cleanup(server.Close)
+	dir := t.TempDir()
+	restore, err := server.Arm(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restore)
+	viper.Set("cluster-id", "missing-cluster")
+
+	_, err = New(nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for a missing cluster")
+	}
+
+	sessionConfigs, err := filepath.Glob(filepath.Join(dir, "*.session-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessionConfigs) != 0 {
+		t.Errorf("session copies of the OCM config were left behind: %v", sessionConfigs)
+	}
+}
+
+func TestReadAnswer(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("y\nno\nYES\n"))
+	for i, expected := range []bool{true, false, true} {
+		got, err := readAnswer(in)
+		if err != nil {
+			t.Fatalf("answer %d: unexpected error: %v", i, err)
+		}
+		if got != expected {
+			t.Errorf("answer %d: expected %v, got %v", i, expected, got)
+		}
+	}
+	if _, err := readAnswer(in); err == nil {
+		t.Error("expected an error once the input is exhausted")
+	}
+}
@@ -0,0 +1,157 @@
+package ocmcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openshift/ocm-container/pkg/image"
+	"github.com/openshift/ocm-container/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// sessionMetadataEnv points to the session metadata in the container
+	sessionMetadataEnv  = "OCMC_SESSION_METADATA"
+	sessionMetadataPath = "/tmp/ocm-container-session.json"
+
+	imageTagKey          = "imageTag"
+	imageVerificationKey = "imageVerification"
+	variantKey           = "variant"
+	backgroundPullKey    = "imageBackgroundPull"
+)
+
+// sessionMetadata records how the session was started, so the exact
+// image in use can be found from inside the container
+type sessionMetadata struct {
+	Version      string    `json:"version"`
+	Engine       string    `json:"engine"`
+	Image        string    `json:"image"`
+	ImageDigest  string    `json:"image_digest,omitempty"`
+	ImageVersion string    `json:"image_version,omitempty"`
+	ImageVariant string    `json:"image_variant,omitempty"`
+	OcmURL       string    `json:"ocm_url"`
+	ClusterID    string    `json:"cluster_id,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// ResolveImage returns the image to run, with the configured tag or
+// channel and variant applied, and pinned to the verified digest if signature
+// verification is enabled
+func ResolveImage() (image.Reference, error) {
+	ref, err := image.Resolve(viper.GetString("image"), viper.GetString(imageTagKey), utils.Version)
+	if err != nil {
+		return ref, err
+	}
+
+	if variant := viper.GetString(variantKey); variant != "" {
+		ref, err = image.ForVariant(ref, variant)
+		if err != nil {
+			return ref, err
+		}
+	}
+
+	policy := image.VerificationPolicy{}
+	if viper.IsSet(imageVerificationKey) {
+		err = viper.UnmarshalKey(imageVerificationKey, &policy)
+		if err != nil {
+			return ref, fmt.Errorf("error parsing %s: %v", imageVerificationKey, err)
+		}
+	}
+	err = policy.Validate()
+	if err != nil {
+		return ref, err
+	}
+
+	if viper.GetBool("dry-run") {
+		log.Debugf("dry-run; skipping image signature verification")
+		return ref, nil
+	}
+	return policy.Verify(ref)
+}
+
+// pullInBackground starts refreshing the image for the next session
+func (o *Runtime) pullInBackground() {
+	log.Debugf("pulling a newer %s image in the background", o.backgroundPullImage)
+	err := o.engine.PullInBackground(o.backgroundPullImage)
+	if err != nil {
+		log.Warnf("unable to pull the image in the background: %v", err)
+	}
+}
+
+// imageVariant returns the variant of the image to run: the one
+// selected with --variant, else the one recorded in the image's labels
+// if the image has been pulled, else the one matching its name
+func (o *Runtime) imageVariant(ref image.Reference) string {
+	if variant := viper.GetString(variantKey); variant != "" {
+		return variant
+	}
+	labels, err := o.engine.ImageLabels(ref.String())
+	if err != nil {
+		log.Debugf("unable to look up image labels: %v", err)
+	}
+	if variant := image.VariantFromLabels(labels); variant != "" {
+		return variant
+	}
+	return image.VariantOf(ref)
+}
+
+// recordSession looks up the digest and version of the image the
+// container was created from, warns if the image and binary versions
+// have drifted apart, and copies the session metadata into the container
+func (o *Runtime) recordSession(ref image.Reference, variant, ocmURL, cluster string) error {
+	m := sessionMetadata{
+		Version:      utils.Version,
+		Engine:       viper.GetString("engine"),
+		Image:        ref.String(),
+		ImageDigest:  ref.Digest,
+		ImageVariant: variant,
+		OcmURL:       ocmURL,
+		ClusterID:    cluster,
+		StartedAt:    time.Now().UTC(),
+	}
+
+	if !ref.Pinned() {
+		digest, err := o.engine.ImageDigest(ref.String())
+		if err != nil {
+			log.Debugf("unable to look up image digest: %v", err)
+		}
+		m.ImageDigest = digest
+	}
+	log.Infof("using image %s (%s)", m.Image, m.ImageDigest)
+
+	labels, err := o.engine.ImageLabels(ref.String())
+	if err != nil {
+		log.Debugf("unable to look up image labels: %v", err)
+	}
+	m.ImageVersion = labels[image.VersionLabel]
+	if warning := image.CheckDrift(utils.Version, m.ImageVersion); warning != "" {
+		log.Warn(warning)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "ocm-container-session-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	out, err := o.Copy(f.Name(), o.container.ID+":"+sessionMetadataPath)
+	log.Debug(out)
+	return err
+}
@@ -0,0 +1,110 @@
+package percluster
+
+import (
+	"fmt"
+	"strings"
+
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	log "github.com/sirupsen/logrus"
+)
+
+// The percluster package resolves the cluster names, IDs and external
+// IDs users pass to subcommands to the cluster IDs that per-cluster data
+// (persistent histories, workspaces) is stored under on the host.
+
+// ClusterNameFile records the cluster's name alongside its data, so
+// clusters can be found by name without connecting to OCM
+const ClusterNameFile = ".cluster_name"
+
+// ValidClusterID returns true if the cluster ID can be stored under,
+// as a single directory name: it must not be empty, contain path
+// separators or start with a dot
+func ValidClusterID(clusterID string) bool {
+	return clusterID != "" && !strings.ContainsAny(clusterID, `/\`) && !strings.HasPrefix(clusterID, ".")
+}
+
+// Index is implemented by stores of per-cluster data
+type Index interface {
+	// Clusters returns the IDs of all clusters with stored data
+	Clusters() ([]string, error)
+	// Has returns true if data is stored for the cluster ID
+	Has(clusterID string) bool
+	// ClusterName returns the recorded name of the cluster, or ""
+	ClusterName(clusterID string) string
+}
+
+// Resolver resolves cluster identifiers and names, using the names
+// recorded in the index and OCM when the user is logged in
+type Resolver struct {
+	index Index
+	conn  *sdk.Connection
+	names map[string]string
+}
+
+// NewResolver returns a Resolver for the index. It connects to OCM if
+// the user is already logged in, but never prompts to log in.
+func NewResolver(index Index) *Resolver {
+	r := &Resolver{index: index, names: map[string]string{}}
+	conn, err := ocm.Connect()
+	if err != nil {
+		log.Debugf("not resolving cluster names with OCM: %v", err)
+	} else {
+		r.conn = conn
+	}
+	return r
+}
+
+// ClusterID returns the ID that data is stored under for a cluster
+// name, ID or external ID
+func (r *Resolver) ClusterID(key string) (string, error) {
+	if r.index.Has(key) {
+		return key, nil
+	}
+
+	ids, err := r.index.Clusters()
+	if err != nil {
+		return "", err
+	}
+	for _, id := range ids {
+		if r.index.ClusterName(id) == key {
+			return id, nil
+		}
+	}
+
+	if r.conn == nil {
+		return "", fmt.Errorf("no data found for cluster %s (not logged into OCM, so names and external IDs can't be resolved)", key)
+	}
+
+	cluster, err := ocm.GetCluster(r.conn, key)
+	if err != nil {
+		return "", err
+	}
+	if !r.index.Has(cluster.ID()) {
+		return "", fmt.Errorf("no data found for cluster %s (%s)", key, cluster.ID())
+	}
+	r.names[cluster.ID()] = cluster.Name()
+	return cluster.ID(), nil
+}
+
+// Name returns a display name for a cluster ID, falling back to the ID
+func (r *Resolver) Name(id string) string {
+	if name, ok := r.names[id]; ok {
+		return name
+	}
+
+	name := r.index.ClusterName(id)
+	if name == "" && r.conn != nil {
+		cluster, err := ocm.GetCluster(r.conn, id)
+		if err != nil {
+			log.Debugf("unable to look up cluster %s: %v", id, err)
+		} else {
+			name = cluster.Name()
+		}
+	}
+	if name == "" {
+		name = id
+	}
+	r.names[id] = name
+	return name
+}
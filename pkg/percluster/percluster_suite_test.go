@@ -0,0 +1,13 @@
+package percluster_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPercluster(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Percluster Suite")
+}
@@ -0,0 +1,76 @@
+package percluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeIndex is an in-memory Index of cluster IDs to names
+type fakeIndex map[string]string
+
+func (f fakeIndex) Clusters() ([]string, error) {
+	ids := []string{}
+	for id := range f {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (f fakeIndex) Has(id string) bool {
+	_, ok := f[id]
+	return ok
+}
+
+func (f fakeIndex) ClusterName(id string) string {
+	return f[id]
+}
+
+var _ = Describe("Pkg/Percluster", func() {
+	var r *Resolver
+
+	BeforeEach(func() {
+		// No OCM connection, as when the user is not logged in
+		r = &Resolver{
+			index: fakeIndex{"abc123": "my-cluster", "def456": ""},
+			names: map[string]string{},
+		}
+	})
+
+	Context("ValidClusterID()", func() {
+		It("Accepts cluster IDs", func() {
+			Expect(ValidClusterID("2abc123")).To(BeTrue())
+		})
+
+		It("Rejects IDs which are not a single directory name", func() {
+			for _, id := range []string{"", ".", "..", ".hidden", "../abc", "abc/def", `abc\def`} {
+				Expect(ValidClusterID(id)).To(BeFalse(), id)
+			}
+		})
+	})
+
+	Context("Resolver.ClusterID()", func() {
+		It("Resolves cluster IDs", func() {
+			Expect(r.ClusterID("def456")).To(Equal("def456"))
+		})
+
+		It("Resolves recorded cluster names", func() {
+			Expect(r.ClusterID("my-cluster")).To(Equal("abc123"))
+		})
+
+		It("Returns an error for unknown clusters when offline", func() {
+			_, err := r.ClusterID("other-cluster")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not logged into OCM"))
+		})
+	})
+
+	Context("Resolver.Name()", func() {
+		It("Returns the recorded name", func() {
+			Expect(r.Name("abc123")).To(Equal("my-cluster"))
+		})
+
+		It("Falls back to the ID", func() {
+			Expect(r.Name("def456")).To(Equal("def456"))
+		})
+	})
+})
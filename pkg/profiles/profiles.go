@@ -0,0 +1,285 @@
+package profiles
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The profiles package allows users to define named sets of configuration
+// in the `profiles:` section of the config file. A profile is applied on top
+// of the base config either explicitly with `--profile` or automatically when
+// its `match` block matches the OCM environment or the target cluster.
+// Profiles are applied before features are configured, so any feature config
+// can be overridden by a profile.
+
+const (
+	// ConfigKey is the top-level config file key holding the profiles
+	ConfigKey = "profiles"
+	// FlagName is the CLI flag (and config key) used to select profiles
+	FlagName = "profile"
+)
+
+// Matcher describes when a profile should be applied automatically.
+// All non-empty fields must match for the profile to be applied.
+type Matcher struct {
+	// OcmURL is compared against the OCM environment in use. Any of the
+	// supported aliases (prod, stage, prodgov, etc.) may be used.
+	OcmURL string `mapstructure:"ocm-url"`
+	// Product is compared against the cluster's product ID (osd, rosa, etc.)
+	Product string `mapstructure:"product"`
+	// CloudProvider is compared against the cluster's cloud provider ID (aws, gcp, etc.)
+	CloudProvider string `mapstructure:"cloud_provider"`
+	// Name is a regular expression matched against the cluster's name
+	Name string `mapstructure:"name"`
+	// HyperShift, when set, matches only HCP clusters (true) or only
+	// classic clusters (false)
+	HyperShift *bool `mapstructure:"hypershift"`
+
+	nameRegex *regexp.Regexp
+}
+
+type Profile struct {
+	Match  *Matcher       `mapstructure:"match"`
+	Config map[string]any `mapstructure:"config"`
+}
+
+// Target holds the information profile matchers are evaluated against
+type Target struct {
+	OcmURL  string
+	Cluster *cmv1.Cluster
+}
+
+var applied []string
+
+// preMatchKeys are the config keys read to log into OCM and look up the
+// cluster, which happens before matching profiles are applied, so they
+// can only be set by profiles selected with --profile
+var preMatchKeys = append([]string{
+	"ocm-url",
+	ocm.EnvironmentsConfigKey,
+	"features.ocm",
+	"cluster-id",
+	FlagName,
+}, ocm.LoginFlags...)
+
+// Load reads and validates the profiles defined in the config file
+func Load() (map[string]*Profile, error) {
+	profiles := map[string]*Profile{}
+	if !viper.IsSet(ConfigKey) {
+		return profiles, nil
+	}
+
+	err := viper.UnmarshalKey(ConfigKey, &profiles)
+	if err != nil {
+		return profiles, fmt.Errorf("error parsing profiles config: %v", err)
+	}
+
+	for name, p := range profiles {
+		if p == nil {
+			return profiles, fmt.Errorf("profile %s is empty", name)
+		}
+		if p.Match != nil && p.Match.Name != "" {
+			p.Match.nameRegex, err = regexp.Compile(p.Match.Name)
+			if err != nil {
+				return profiles, fmt.Errorf("profile %s has an invalid name matcher: %v", name, err)
+			}
+		}
+		if p.Match != nil {
+			if key := preMatchKey(p.Config, ""); key != "" {
+				return profiles, fmt.Errorf("profile %s has a match block, so it can't set %s, which is read before matching profiles are applied; select the profile with --profile instead", name, key)
+			}
+		}
+	}
+
+	return profiles, nil
+}
+
+// Names parses a comma-separated list of profile names, such as
+// the value of the `--profile` flag
+func Names(s string) []string {
+	names := []string{}
+	for _, n := range strings.Split(s, ",") {
+		n = strings.TrimSpace(n)
+		if n != "" && !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// ApplyNamed overlays the given profiles onto the config in the order
+// provided, returning an error if any of them are not defined
+func ApplyNamed(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	profiles, err := Load()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		p, ok := profiles[name]
+		if !ok {
+			return fmt.Errorf("profile %s is not defined in the config file", name)
+		}
+		err = apply(name, p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyMatching overlays every profile whose matcher matches the target
+// and which has not already been applied. Profiles are applied in
+// alphabetical order so the result is deterministic.
+func ApplyMatching(t Target) error {
+	profiles, err := Load()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := profiles[name]
+		if slices.Contains(applied, name) || p.Match == nil {
+			continue
+		}
+		if !p.Match.Matches(t) {
+			log.Debugf("profile %s does not match", name)
+			continue
+		}
+		err = apply(name, p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Applied returns the names of the profiles applied so far, in order
+func Applied() []string {
+	return applied
+}
+
+// Reset clears the list of applied profiles
+func Reset() {
+	applied = []string{}
+}
+
+// Matches returns true if every configured field of the matcher
+// matches the target. An empty matcher never matches.
+func (m *Matcher) Matches(t Target) bool {
+	if m.empty() {
+		return false
+	}
+
+	if m.OcmURL != "" && !sameOcmURL(m.OcmURL, t.OcmURL) {
+		return false
+	}
+
+	if m.Product == "" && m.CloudProvider == "" && m.Name == "" && m.HyperShift == nil {
+		return true
+	}
+
+	// Everything below requires a cluster
+	if t.Cluster == nil {
+		return false
+	}
+
+	if m.Product != "" && !strings.EqualFold(m.Product, t.Cluster.Product().ID()) {
+		return false
+	}
+
+	if m.CloudProvider != "" && !strings.EqualFold(m.CloudProvider, t.Cluster.CloudProvider().ID()) {
+		return false
+	}
+
+	if m.Name != "" {
+		r := m.nameRegex
+		if r == nil {
+			var err error
+			r, err = regexp.Compile(m.Name)
+			if err != nil {
+				return false
+			}
+		}
+		if !r.MatchString(t.Cluster.Name()) {
+			return false
+		}
+	}
+
+	if m.HyperShift != nil && *m.HyperShift != t.Cluster.Hypershift().Enabled() {
+		return false
+	}
+
+	return true
+}
+
+func (m *Matcher) empty() bool {
+	return m.OcmURL == "" && m.Product == "" && m.CloudProvider == "" && m.Name == "" && m.HyperShift == nil
+}
+
+// sameOcmURL compares an OCM environment from a profile with the URL
+// of the current connection, resolving any aliases
+func sameOcmURL(want, got string) bool {
+	if u, err := ocm.ResolveURL(want); err == nil {
+		want = u
+	}
+	if u, err := ocm.ResolveURL(got); err == nil {
+		got = u
+	}
+	return strings.TrimSuffix(want, "/") == strings.TrimSuffix(got, "/")
+}
+
+// preMatchKey returns the first key of the config, prefixed with prefix,
+// which is or is set within one of the preMatchKeys, or "" if none is
+func preMatchKey(config map[string]any, prefix string) string {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := prefix + k
+		for _, pre := range preMatchKeys {
+			if strings.EqualFold(key, pre) || strings.HasPrefix(strings.ToLower(key), strings.ToLower(pre)+".") {
+				return key
+			}
+		}
+		if nested, ok := config[k].(map[string]any); ok {
+			if found := preMatchKey(nested, key+"."); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+func apply(name string, p *Profile) error {
+	log.Infof("applying config profile: %s", name)
+	if p.Config != nil {
+		err := viper.MergeConfigMap(p.Config)
+		if err != nil {
+			return fmt.Errorf("error applying profile %s: %v", name, err)
+		}
+	}
+	applied = append(applied, name)
+	return nil
+}
@@ -0,0 +1,209 @@
+package profiles
+
+import (
+	"strings"
+
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+)
+
+func buildCluster(name, product, provider string, hypershift bool) *cmv1.Cluster {
+	cluster, err := cmv1.NewCluster().
+		Name(name).
+		Product(cmv1.NewProduct().ID(product)).
+		CloudProvider(cmv1.NewCloudProvider().ID(provider)).
+		Hypershift(cmv1.NewHypershift().Enabled(hypershift)).
+		Build()
+	Expect(err).ToNot(HaveOccurred())
+	return cluster
+}
+
+var _ = Describe("Pkg/Profiles/Profiles", func() {
+	BeforeEach(func() {
+		viper.Reset()
+		Reset()
+	})
+
+	Context("Names()", func() {
+		It("Splits a comma-separated list", func() {
+			Expect(Names("stage, fedramp")).To(Equal([]string{"stage", "fedramp"}))
+		})
+
+		It("Drops empty entries and duplicates", func() {
+			Expect(Names("stage,,stage,")).To(Equal([]string{"stage"}))
+		})
+
+		It("Returns an empty list for an empty string", func() {
+			Expect(Names("")).To(BeEmpty())
+		})
+	})
+
+	Context("Load()", func() {
+		It("Returns no profiles when none are configured", func() {
+			p, err := Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).To(BeEmpty())
+		})
+
+		It("Parses profiles with matchers and config", func() {
+			viper.Set(ConfigKey, map[string]any{
+				"stage": map[string]any{
+					"match":  map[string]any{"ocm-url": "stage"},
+					"config": map[string]any{"image": "stage-image"},
+				},
+			})
+			p, err := Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).To(HaveKey("stage"))
+			Expect(p["stage"].Match.OcmURL).To(Equal("stage"))
+			Expect(p["stage"].Config).To(HaveKeyWithValue("image", "stage-image"))
+		})
+
+		It("Returns an error for an invalid name regex", func() {
+			viper.Set(ConfigKey, map[string]any{
+				"bad": map[string]any{
+					"match": map[string]any{"name": "(["},
+				},
+			})
+			_, err := Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid name matcher"))
+		})
+
+		It("Returns an error for matching profiles setting keys read before matching", func() {
+			for key, value := range map[string]any{
+				"ocm-url":        "stage",
+				"ocmUrls":        map[string]any{"dev": "https://api.dev.example.com"},
+				"ocm-token-file": "/tmp/token",
+				"profile":        "other",
+				"features":       map[string]any{"ocm": map[string]any{"read-only-config": true}},
+			} {
+				viper.Reset()
+				viper.Set(ConfigKey, map[string]any{
+					"late": map[string]any{
+						"match":  map[string]any{"name": "^hs-mc-"},
+						"config": map[string]any{key: value},
+					},
+				})
+				_, err := Load()
+				Expect(err).To(MatchError(ContainSubstring("select the profile with --profile")), key)
+				Expect(err.Error()).To(ContainSubstring(strings.ToLower(key)))
+			}
+		})
+
+		It("Allows profiles without a match block to set keys read before matching", func() {
+			viper.Set(ConfigKey, map[string]any{
+				"stage": map[string]any{
+					"config": map[string]any{
+						"ocm-url":  "stage",
+						"features": map[string]any{"ocm": map[string]any{"read-only-config": true}},
+					},
+				},
+			})
+			_, err := Load()
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("ApplyNamed()", func() {
+		It("Overlays the profile config onto the base config", func() {
+			viper.Set(ConfigKey, map[string]any{
+				"stage": map[string]any{
+					"config": map[string]any{
+						"features": map[string]any{
+							"backplane": map[string]any{"config_file": "stage.json"},
+						},
+					},
+				},
+			})
+			err := ApplyNamed([]string{"stage"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(viper.GetString("features.backplane.config_file")).To(Equal("stage.json"))
+			Expect(Applied()).To(Equal([]string{"stage"}))
+		})
+
+		It("Returns an error for an unknown profile", func() {
+			err := ApplyNamed([]string{"missing"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not defined"))
+		})
+	})
+
+	Context("ApplyMatching()", func() {
+		It("Applies matching profiles and skips the rest", func() {
+			viper.Set(ConfigKey, map[string]any{
+				"gov": map[string]any{
+					"match":  map[string]any{"ocm-url": "prodgov"},
+					"config": map[string]any{"image": "gov-image"},
+				},
+				"hcp": map[string]any{
+					"match":  map[string]any{"hypershift": true},
+					"config": map[string]any{"engine": "docker"},
+				},
+			})
+			err := ApplyMatching(Target{
+				OcmURL:  "https://api.openshift.com",
+				Cluster: buildCluster("my-cluster", "rosa", "aws", true),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(Applied()).To(Equal([]string{"hcp"}))
+			Expect(viper.GetString("engine")).To(Equal("docker"))
+			Expect(viper.IsSet("image")).To(BeFalse())
+		})
+
+		It("Does not re-apply explicitly selected profiles", func() {
+			viper.Set(ConfigKey, map[string]any{
+				"stage": map[string]any{
+					"match": map[string]any{"ocm-url": "stage"},
+				},
+			})
+			Expect(ApplyNamed([]string{"stage"})).To(Succeed())
+			Expect(ApplyMatching(Target{OcmURL: "https://api.stage.openshift.com"})).To(Succeed())
+			Expect(Applied()).To(Equal([]string{"stage"}))
+		})
+	})
+
+	Context("Matcher.Matches()", func() {
+		var cluster *cmv1.Cluster
+
+		BeforeEach(func() {
+			cluster = buildCluster("hs-mc-abc123", "osd", "gcp", false)
+		})
+
+		It("Never matches when empty", func() {
+			m := Matcher{}
+			Expect(m.Matches(Target{OcmURL: "https://api.openshift.com", Cluster: cluster})).To(BeFalse())
+		})
+
+		It("Matches ocm-url aliases against the full URL", func() {
+			m := Matcher{OcmURL: "prod"}
+			Expect(m.Matches(Target{OcmURL: "https://api.openshift.com"})).To(BeTrue())
+			Expect(m.Matches(Target{OcmURL: "https://api.stage.openshift.com"})).To(BeFalse())
+		})
+
+		It("Matches product and cloud provider case-insensitively", func() {
+			m := Matcher{Product: "OSD", CloudProvider: "GCP"}
+			Expect(m.Matches(Target{Cluster: cluster})).To(BeTrue())
+		})
+
+		It("Matches the cluster name as a regex", func() {
+			m := Matcher{Name: "^hs-mc-"}
+			Expect(m.Matches(Target{Cluster: cluster})).To(BeTrue())
+			m = Matcher{Name: "^hs-sc-"}
+			Expect(m.Matches(Target{Cluster: cluster})).To(BeFalse())
+		})
+
+		It("Does not match cluster fields without a cluster", func() {
+			m := Matcher{Product: "osd"}
+			Expect(m.Matches(Target{OcmURL: "https://api.openshift.com"})).To(BeFalse())
+		})
+
+		It("Requires all configured fields to match", func() {
+			m := Matcher{OcmURL: "stage", Product: "osd"}
+			Expect(m.Matches(Target{OcmURL: "https://api.openshift.com", Cluster: cluster})).To(BeFalse())
+		})
+	})
+})
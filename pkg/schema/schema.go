@@ -0,0 +1,468 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/openshift/ocm-container/pkg/clusterstatus"
+	"github.com/openshift/ocm-container/pkg/engine"
+	"github.com/openshift/ocm-container/pkg/features"
+	"github.com/openshift/ocm-container/pkg/features/registrar"
+	"github.com/openshift/ocm-container/pkg/image"
+	"github.com/openshift/ocm-container/pkg/multicluster"
+	"github.com/openshift/ocm-container/pkg/ocm"
+	"github.com/openshift/ocm-container/pkg/profiles"
+	"github.com/spf13/viper"
+)
+
+// The schema package generates a JSON Schema for the ocm-container config
+// file from the root options below and the config structs of every
+// registered feature, and validates config files against it.
+
+const (
+	draft = "https://json-schema.org/draft/2020-12/schema"
+	id    = "https://github.com/openshift/ocm-container/ocm-container.schema.json"
+)
+
+// Schema is the subset of JSON Schema used to describe the config file
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+}
+
+// rootConfig describes the top-level options that are not owned by
+// a feature. Most of these can also be passed as CLI flags.
+type rootConfig struct {
+	Engine              string                   `mapstructure:"engine"`
+	Image               string                   `mapstructure:"image"`
+	ImagePullPolicy     string                   `mapstructure:"imagePullPolicy"`
+	ImageTag            string                   `mapstructure:"imageTag"`
+	ImageBackgroundPull bool                     `mapstructure:"imageBackgroundPull"`
+	ImageVerification   image.VerificationPolicy `mapstructure:"imageVerification"`
+	Variant             string                   `mapstructure:"variant"`
+	OcmURL              string                   `mapstructure:"ocm-url"`
+	OcmTokenFile        string                   `mapstructure:"ocm-token-file"`
+	OcmLoginMethod      string                   `mapstructure:"ocm-login-method"`
+	NoBrowser           bool                     `mapstructure:"no-browser"`
+	// OcmURLs values are either a URL, or an object with the URL, token
+	// URL and client ID
+	OcmURLs           map[string]any             `mapstructure:"ocmUrls"`
+	ClustersFromFile  string                     `mapstructure:"clusters-from-file"`
+	MultiClusterMode  string                     `mapstructure:"multiClusterMode"`
+	ClusterID         string                     `mapstructure:"cluster-id"`
+	Headless          string                     `mapstructure:"headless"`
+	LaunchOpts        string                     `mapstructure:"launch-opts"`
+	PublishAllPorts   bool                       `mapstructure:"publish-all-ports"`
+	NoLogin           bool                       `mapstructure:"no-login"`
+	NoClusterBanner   bool                       `mapstructure:"no-cluster-banner"`
+	ClusterBanner     clusterstatus.BannerConfig `mapstructure:"clusterBanner"`
+	ClusterGuardrails clusterstatus.Guardrails   `mapstructure:"clusterGuardrails"`
+	Force             bool                       `mapstructure:"force"`
+	NoColor           bool                       `mapstructure:"no-color"`
+	DryRun            bool                       `mapstructure:"dry-run"`
+	LogLevel          string                     `mapstructure:"log-level"`
+	Profile           string                     `mapstructure:"profile"`
+	Log               logConfig                  `mapstructure:"log"`
+	Env               []envConfig                `mapstructure:"env"`
+	VolumeMounts      []string                   `mapstructure:"volumeMounts"`
+	Features          struct {
+		Ocm ocmConfig `mapstructure:"ocm"`
+	} `mapstructure:"features"`
+}
+
+type logConfig struct {
+	Level string `mapstructure:"level"`
+	Color bool   `mapstructure:"color"`
+}
+
+type envConfig struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+}
+
+type ocmConfig struct {
+	IgnoreLoginWarning bool `mapstructure:"ignore-login-warning"`
+	ReadOnlyConfig     bool `mapstructure:"read-only-config"`
+}
+
+func rootDefaults() *rootConfig {
+	r := rootConfig{}
+	r.Engine = "podman"
+	r.ImagePullPolicy = "always"
+	r.OcmURL = "prod"
+	r.OcmLoginMethod = ocm.LoginBrowser
+	r.Log.Level = "warning"
+	r.Log.Color = true
+	r.ClusterBanner = clusterstatus.DefaultBannerConfig()
+	r.ClusterGuardrails = clusterstatus.DefaultGuardrails()
+	return &r
+}
+
+// enums holds the allowed values for root options, keyed by path
+var enums = map[string][]string{
+	"engine":          engine.SupportedEngines,
+	"imagePullPolicy": engine.SupportedPullImagePolicies,
+	// An empty policy disables image verification
+	"imageVerification.policy":           append([]string{""}, image.SupportedPolicies...),
+	"variant":                            append([]string{""}, image.Variants...),
+	"multiClusterMode":                   multicluster.Modes,
+	"clusterGuardrails.uninstalling":     clusterstatus.Actions,
+	"clusterGuardrails.error":            clusterstatus.Actions,
+	"clusterGuardrails.hibernating":      clusterstatus.Actions,
+	"clusterGuardrails.notReady":         clusterstatus.Actions,
+	"clusterGuardrails.limitedSupport":   clusterstatus.Actions,
+	"clusterGuardrails.accessProtection": clusterstatus.Actions,
+	"log.level":                          {"debug", "info", "warn", "warning", "err", "error"},
+}
+
+// Generate builds the config file schema from the root options and all
+// registered features
+func Generate() *Schema {
+	root := FromStruct(rootDefaults())
+	root.Schema = draft
+	root.ID = id
+	root.Title = "ocm-container configuration"
+
+	for path, values := range enums {
+		s := root.lookup(path)
+		if s == nil {
+			continue
+		}
+		for _, v := range values {
+			s.Enum = append(s.Enum, v)
+		}
+	}
+
+	// The `--no-[feature]` flags may also be set in the config file
+	for _, flag := range registrar.FeatureFlags() {
+		root.Properties[flag.Name] = &Schema{Type: "boolean"}
+	}
+
+	configurables := features.Configurables()
+	names := make([]string, 0, len(configurables))
+	for name := range configurables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := configurables[name]
+		root.set(f.ConfigKey(), FromStruct(f.DefaultConfig()))
+	}
+
+	// Each profile's config block is itself a full config file
+	profile := FromStruct(&profiles.Profile{})
+	profile.Properties["config"] = &Schema{Ref: "#"}
+	root.Properties[profiles.ConfigKey] = &Schema{
+		Type:                 "object",
+		AdditionalProperties: profile,
+	}
+
+	return root
+}
+
+// FromStruct reflects over a (pointer to a) struct with mapstructure tags
+// and returns its schema, using the field values as defaults
+func FromStruct(v any) *Schema {
+	return fromValue(reflect.ValueOf(v))
+}
+
+func fromValue(v reflect.Value) *Schema {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fromType(v.Type())
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		s := &Schema{
+			Type:                 "object",
+			Properties:           map[string]*Schema{},
+			AdditionalProperties: false,
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, squash := fieldName(field)
+			if name == "-" {
+				continue
+			}
+			child := fromValue(v.Field(i))
+			if squash && child.Properties != nil {
+				for k, p := range child.Properties {
+					s.Properties[k] = p
+				}
+				continue
+			}
+			s.Properties[name] = child
+		}
+		return s
+	case reflect.Slice, reflect.Array, reflect.Map:
+		s := fromType(v.Type())
+		if v.Len() > 0 {
+			s.Default = v.Interface()
+		}
+		return s
+	default:
+		s := fromType(v.Type())
+		if !v.IsZero() || v.Kind() == reflect.Bool {
+			s.Default = v.Interface()
+		}
+		return s
+	}
+}
+
+func fromType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: fromType(t.Elem())}
+	case reflect.Map:
+		s := &Schema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s.AdditionalProperties = fromType(t.Elem())
+		}
+		return s
+	case reflect.Struct:
+		return fromValue(reflect.New(t).Elem())
+	default:
+		// interface{} and anything else may hold any value
+		return &Schema{}
+	}
+}
+
+// fieldName returns the mapstructure name of a struct field and
+// whether it is squashed into its parent
+func fieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("mapstructure")
+	if tag == "" {
+		return f.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	squash := false
+	for _, opt := range parts[1:] {
+		if opt == "squash" {
+			squash = true
+		}
+	}
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name, squash
+}
+
+// lookup returns the schema at the given dot-separated path, or nil
+func (s *Schema) lookup(path string) *Schema {
+	current := s
+	for _, key := range strings.Split(path, ".") {
+		if current == nil || current.Properties == nil {
+			return nil
+		}
+		current = current.property(key)
+	}
+	return current
+}
+
+// set places a schema at the given dot-separated path, creating
+// intermediate objects as needed
+func (s *Schema) set(path string, child *Schema) {
+	keys := strings.Split(path, ".")
+	current := s
+	for _, key := range keys[:len(keys)-1] {
+		next := current.property(key)
+		if next == nil {
+			next = &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+			current.Properties[key] = next
+		}
+		if next.Properties == nil {
+			next.Properties = map[string]*Schema{}
+		}
+		current = next
+	}
+	current.Properties[keys[len(keys)-1]] = child
+}
+
+// property looks up a property by name. Viper lower-cases all config
+// keys, so the comparison is case-insensitive.
+func (s *Schema) property(key string) *Schema {
+	if p, ok := s.Properties[key]; ok {
+		return p
+	}
+	for k, p := range s.Properties {
+		if strings.EqualFold(k, key) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Problem is a single issue found while validating a config file
+type Problem struct {
+	Path    string
+	Message string
+	// Unknown is true when the problem is an unrecognized key, which
+	// may be a typo but does not prevent ocm-container from running
+	Unknown bool
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// Validate checks config values, as read by viper, against the schema
+func (s *Schema) Validate(cfg map[string]any) []Problem {
+	problems := []Problem{}
+	s.validate(s, "", cfg, &problems)
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems
+}
+
+// ValidateFile reads the given config file and validates it against
+// the schema. Only the file's contents are checked; flags and
+// environment variables are not included.
+func (s *Schema) ValidateFile(path string) ([]Problem, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+	return s.Validate(v.AllSettings()), nil
+}
+
+func (s *Schema) validate(root *Schema, path string, value any, problems *[]Problem) {
+	if s.Ref == "#" {
+		s = root
+	}
+
+	if value == nil {
+		return
+	}
+
+	add := func(msg string, args ...any) {
+		*problems = append(*problems, Problem{Path: displayPath(path), Message: fmt.Sprintf(msg, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := toMap(value)
+		if !ok {
+			add("expected an object, got %T", value)
+			return
+		}
+		for k, v := range m {
+			childPath := joinPath(path, k)
+			if child := s.property(k); child != nil {
+				child.validate(root, childPath, v, problems)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case *Schema:
+				extra.validate(root, childPath, v, problems)
+			case bool:
+				if !extra {
+					*problems = append(*problems, Problem{Path: displayPath(childPath), Message: "unknown key", Unknown: true})
+				}
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			add("expected a list, got %T", value)
+			return
+		}
+		if s.Items == nil {
+			return
+		}
+		for i, item := range items {
+			s.Items.validate(root, fmt.Sprintf("%s[%d]", path, i), item, problems)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			add("expected a boolean, got %v", value)
+		}
+	case "integer":
+		switch n := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		case float64:
+			if n != float64(int64(n)) {
+				add("expected an integer, got %v", value)
+			}
+		default:
+			add("expected an integer, got %v", value)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			add("expected a string, got %v", value)
+			return
+		}
+		if len(s.Enum) > 0 && !enumContains(s.Enum, str) {
+			add("invalid value %q, must be one of %v", str, s.Enum)
+		}
+	}
+}
+
+func toMap(value any) (map[string]any, bool) {
+	switch m := value.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		out := map[string]any{}
+		for k, v := range m {
+			out[fmt.Sprint(k)] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func enumContains(enum []any, value string) bool {
+	for _, e := range enum {
+		if s, ok := e.(string); ok && s == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
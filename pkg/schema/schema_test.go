@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testConfig struct {
+	Enabled bool              `mapstructure:"enabled"`
+	Port    int               `mapstructure:"port"`
+	Path    string            `mapstructure:"config_file"`
+	Tags    []string          `mapstructure:"tags"`
+	Labels  map[string]string `mapstructure:"labels"`
+	Nested  struct {
+		Name string `mapstructure:"name"`
+	} `mapstructure:"nested"`
+	unexported string
+}
+
+var _ = Describe("Pkg/Schema/Schema", func() {
+	Context("FromStruct()", func() {
+		It("Maps mapstructure fields to typed properties with defaults", func() {
+			s := FromStruct(&testConfig{Enabled: true, Port: 9999, Path: ".config/test"})
+			Expect(s.Type).To(Equal("object"))
+			Expect(s.AdditionalProperties).To(Equal(false))
+			Expect(s.Properties).To(HaveLen(6))
+
+			Expect(s.Properties["enabled"].Type).To(Equal("boolean"))
+			Expect(s.Properties["enabled"].Default).To(Equal(true))
+			Expect(s.Properties["port"].Type).To(Equal("integer"))
+			Expect(s.Properties["port"].Default).To(Equal(9999))
+			Expect(s.Properties["config_file"].Default).To(Equal(".config/test"))
+			Expect(s.Properties["tags"].Type).To(Equal("array"))
+			Expect(s.Properties["tags"].Items.Type).To(Equal("string"))
+			Expect(s.Properties["labels"].Type).To(Equal("object"))
+			Expect(s.Properties["labels"].AdditionalProperties).To(Equal(&Schema{Type: "string"}))
+			Expect(s.Properties["nested"].Properties).To(HaveKey("name"))
+		})
+
+		It("Omits defaults for empty strings", func() {
+			s := FromStruct(&testConfig{})
+			Expect(s.Properties["config_file"].Default).To(BeNil())
+		})
+	})
+
+	Context("Generate()", func() {
+		It("Includes the root options and registered features", func() {
+			s := Generate()
+			Expect(s.Properties).To(HaveKey("engine"))
+			Expect(s.Properties["engine"].Enum).To(ContainElement("podman"))
+			Expect(s.Properties).To(HaveKey("ports"))
+			Expect(s.lookup("ports.console.port").Default).To(Equal(9999))
+			Expect(s.lookup("features.backplane.config_file")).ToNot(BeNil())
+			Expect(s.lookup("features.ocm.ignore-login-warning")).ToNot(BeNil())
+			Expect(s.Properties).To(HaveKey("no-backplane"))
+		})
+
+		It("References the root schema from profile configs", func() {
+			s := Generate()
+			profile, ok := s.Properties["profiles"].AdditionalProperties.(*Schema)
+			Expect(ok).To(BeTrue())
+			Expect(profile.Properties["config"].Ref).To(Equal("#"))
+		})
+
+		It("Marshals to JSON", func() {
+			_, err := json.Marshal(Generate())
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("Validate()", func() {
+		var s *Schema
+
+		BeforeEach(func() {
+			s = Generate()
+		})
+
+		It("Accepts a valid config", func() {
+			problems := s.Validate(map[string]any{
+				"engine":          "docker",
+				"imagepullpolicy": "missing",
+				"volumemounts":    []any{"/a:/b"},
+				"env":             []any{map[string]any{"name": "FOO", "value": "bar"}},
+				"ports":           map[string]any{"console": map[string]any{"port": 8888}},
+			})
+			Expect(problems).To(BeEmpty())
+		})
+
+		It("Reports unknown keys", func() {
+			problems := s.Validate(map[string]any{
+				"features": map[string]any{
+					"gcloud": map[string]any{"config_mnt": "ro"},
+				},
+			})
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].Path).To(Equal("features.gcloud.config_mnt"))
+			Expect(problems[0].Unknown).To(BeTrue())
+		})
+
+		It("Reports type mismatches and invalid enum values", func() {
+			problems := s.Validate(map[string]any{
+				"engine": "podmn",
+				"ports":  map[string]any{"enabled": "yes"},
+			})
+			Expect(problems).To(HaveLen(2))
+			Expect(problems[0].Path).To(Equal("engine"))
+			Expect(problems[1].Path).To(Equal("ports.enabled"))
+			Expect(problems[1].Unknown).To(BeFalse())
+		})
+
+		It("Validates profile configs against the root schema", func() {
+			problems := s.Validate(map[string]any{
+				"profiles": map[string]any{
+					"stage": map[string]any{
+						"match":  map[string]any{"ocm-url": "stage"},
+						"config": map[string]any{"imag": "foo"},
+					},
+				},
+			})
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].Path).To(Equal("profiles.stage.config.imag"))
+		})
+
+		It("Accepts the example config file", func() {
+			problems, err := s.ValidateFile("../../docs/example_config.yaml")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(problems).To(BeEmpty())
+		})
+	})
+})
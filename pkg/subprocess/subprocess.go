@@ -44,6 +44,54 @@ func RunAndReplace(command string, args, env []string) error {
 	return syscall.Exec(command, args, env)
 }
 
+// RunAttached runs a command connected to this process's stdin, stdout
+// and stderr, and waits for it to exit
+func RunAttached(c *exec.Cmd) error {
+	printCmd(fmt.Sprint(c))
+	if dryRun() {
+		return nil
+	}
+
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// RunProgress runs a command with its output written to this process's
+// stderr as it is produced, so progress is shown without mixing it into
+// the output of ocm-container
+func RunProgress(c *exec.Cmd) error {
+	printCmd(fmt.Sprint(c))
+	if dryRun() {
+		return nil
+	}
+
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// RunDetached starts a command in a new session with no input or output,
+// and does not wait for it, so it outlives this process and is not
+// interrupted by signals sent to the terminal
+func RunDetached(c *exec.Cmd) error {
+	printCmd(fmt.Sprint(c))
+	if dryRun() {
+		return nil
+	}
+
+	c.Stdin = nil
+	c.Stdout = nil
+	c.Stderr = nil
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+	return c.Process.Release()
+}
+
 func RunLive(c *exec.Cmd) (string, error) {
 	var stdoutBuf, stderrBuf bytes.Buffer
 	c.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "Hours",
+			input:    "12h",
+			expected: 12 * time.Hour,
+		},
+		{
+			name:     "Days",
+			input:    "7d",
+			expected: 7 * 24 * time.Hour,
+		},
+		{
+			name:     "Weeks",
+			input:    "2w",
+			expected: 14 * 24 * time.Hour,
+		},
+		{
+			name:    "Fractional days",
+			input:   "1.5d",
+			wantErr: true,
+		},
+		{
+			name:    "Negative",
+			input:   "-1h",
+			wantErr: true,
+		},
+		{
+			name:    "Garbage",
+			input:   "soon",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDuration(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseDuration(%q) expected an error, got %v", tt.input, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDuration(%q) unexpected error: %v", tt.input, err)
+			}
+			if d != tt.expected {
+				t.Errorf("ParseDuration(%q) = %v, expected %v", tt.input, d, tt.expected)
+			}
+		})
+	}
+}
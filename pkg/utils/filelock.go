@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// ErrLocked is returned by TryLockFile when another process holds the lock
+var ErrLocked = errors.New("file is locked by another process")
+
+// FileLock is an advisory lock on a file, shared with other processes
+// on the same host. Locks are released when the process exits.
+type FileLock struct {
+	f *os.File
+}
+
+// LockFile takes an exclusive lock on the file at path, creating it if
+// needed, and waits until any other process has released it
+func LockFile(path string) (*FileLock, error) {
+	return lockFile(path, syscall.LOCK_EX)
+}
+
+// TryLockFile takes an exclusive lock on the file at path, creating it
+// if needed. It returns ErrLocked rather than waiting if another process
+// holds the lock.
+func TryLockFile(path string) (*FileLock, error) {
+	l, err := lockFile(path, syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, ErrLocked
+	}
+	return l, err
+}
+
+func lockFile(path string, how int) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), how)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	closeErr := l.f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTryLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	l, err := TryLockFile(path)
+	if err != nil {
+		t.Fatalf("TryLockFile() unexpected error: %v", err)
+	}
+
+	// flock locks are per open file, so a second open conflicts even
+	// within the same process
+	_, err = TryLockFile(path)
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLockFile() on a locked file = %v, expected ErrLocked", err)
+	}
+
+	err = l.Unlock()
+	if err != nil {
+		t.Fatalf("Unlock() unexpected error: %v", err)
+	}
+
+	l, err = LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() after Unlock() unexpected error: %v", err)
+	}
+	_ = l.Unlock()
+}
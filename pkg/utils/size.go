@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = map[string]int64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+}
+
+// ParseSize parses a size in bytes with an optional K, M or G suffix,
+// eg: 512K or 50M. KB/KiB style suffixes are also accepted.
+func ParseSize(s string) (int64, error) {
+	u := strings.ToUpper(strings.TrimSpace(s))
+	u = strings.TrimSuffix(u, "B")
+	u = strings.TrimSuffix(u, "I")
+
+	unit := ""
+	if u != "" && strings.ContainsAny(u[len(u)-1:], "KMG") {
+		unit = u[len(u)-1:]
+		u = u[:len(u)-1]
+	}
+
+	n, err := strconv.ParseInt(u, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: use a size such as 512K, 50M or 1G", s)
+	}
+	return n * sizeUnits[unit], nil
+}
+
+// FormatSize formats a size in bytes for display, eg: 1.5M
+func FormatSize(n int64) string {
+	switch {
+	case n >= sizeUnits["G"]:
+		return fmt.Sprintf("%.1fG", float64(n)/float64(sizeUnits["G"]))
+	case n >= sizeUnits["M"]:
+		return fmt.Sprintf("%.1fM", float64(n)/float64(sizeUnits["M"]))
+	case n >= sizeUnits["K"]:
+		return fmt.Sprintf("%.1fK", float64(n)/float64(sizeUnits["K"]))
+	}
+	return fmt.Sprintf("%dB", n)
+}
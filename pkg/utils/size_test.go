@@ -0,0 +1,54 @@
+package utils
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{name: "Bytes", input: "1024", expected: 1024},
+		{name: "Kilobytes", input: "512K", expected: 512 * 1024},
+		{name: "Megabytes", input: "50M", expected: 50 * 1024 * 1024},
+		{name: "Mebibytes", input: "50MiB", expected: 50 * 1024 * 1024},
+		{name: "Lower case gigabytes", input: "1g", expected: 1024 * 1024 * 1024},
+		{name: "Empty", input: "", wantErr: true},
+		{name: "Unit only", input: "M", wantErr: true},
+		{name: "Negative", input: "-1K", wantErr: true},
+		{name: "Unknown unit", input: "1T", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := ParseSize(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSize(%q) expected an error, got %v", tt.input, n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSize(%q) unexpected error: %v", tt.input, err)
+			}
+			if n != tt.expected {
+				t.Errorf("ParseSize(%q) = %v, expected %v", tt.input, n, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512B",
+		1536:            "1.5K",
+		5 * 1024 * 1024: "5.0M",
+		3 << 30:         "3.0G",
+	}
+	for n, expected := range tests {
+		if got := FormatSize(n); got != expected {
+			t.Errorf("FormatSize(%d) = %s, expected %s", n, got, expected)
+		}
+	}
+}
@@ -32,6 +32,8 @@ var (
 // This function can be imported by other Go programs (such as osdctl) to detect
 // when they are running inside ocm-container and adjust their behavior accordingly
 // (e.g., skipping browser auto-launch, adjusting network binding addresses).
+// When the browser bridge feature is enabled, $BROWSER is set in the
+// container and URLs can be opened on the host as usual.
 //
 // Example usage:
 //